@@ -0,0 +1,59 @@
+package elefas
+
+import "sync"
+
+// bufferPool keeps buffers for the outputs of layers, by size; as the model's type is fixed, the size is all that
+// tells buffers apart, whatever their shape. Like in sync.Pool, the buffers it keeps may be freed by the garbage
+// collector. A nil pool allocates a new buffer every time, and drops the buffers put in it.
+type bufferPool[T SizedNumber] struct {
+	mu    sync.RWMutex
+	pools map[int]*sync.Pool
+}
+
+// get returns a DataFrame of shape dims, whose data is taken from the pool, and the buffer to put back once it is
+// no longer needed. The data is not cleared.
+func (p *bufferPool[T]) get(dims []int) (DataFrame[T], *[]T) {
+	size := dimsSize(dims)
+	if p != nil {
+		p.mu.RLock()
+		pool := p.pools[size]
+		p.mu.RUnlock()
+		if pool != nil {
+			if buffer, ok := pool.Get().(*[]T); ok {
+				return DataFrame[T]{Dims: dims, Data: *buffer}, buffer
+			}
+		}
+	}
+	buffer := make([]T, size)
+	return DataFrame[T]{Dims: dims, Data: buffer}, &buffer
+}
+
+func (p *bufferPool[T]) put(buffer *[]T) {
+	if p == nil {
+		return
+	}
+	size := len(*buffer)
+	p.mu.RLock()
+	pool := p.pools[size]
+	p.mu.RUnlock()
+	if pool == nil {
+		p.mu.Lock()
+		if pool = p.pools[size]; pool == nil {
+			if p.pools == nil {
+				p.pools = map[int]*sync.Pool{}
+			}
+			pool = &sync.Pool{}
+			p.pools[size] = pool
+		}
+		p.mu.Unlock()
+	}
+	pool.Put(buffer)
+}
+
+func dimsSize(dims []int) int {
+	size := 1
+	for _, d := range dims {
+		size *= d
+	}
+	return size
+}
@@ -1,80 +1,430 @@
 package elefas
 
+import (
+	"context"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"sync"
+)
+
 type Layer[T SizedNumber] interface {
 	Apply(input DataFrame[T]) DataFrame[T]
 }
 
+// MultiInputLayer is a layer that takes several inputs, such as the merge layers.
+type MultiInputLayer[T SizedNumber] interface {
+	ApplyMulti(inputs []DataFrame[T]) DataFrame[T]
+}
+
+// LayerE is a layer that returns an error for inputs it cannot take, where Apply would panic. PredictE uses ApplyE
+// for the layers that implement it.
+type LayerE[T SizedNumber] interface {
+	Layer[T]
+	ApplyE(input DataFrame[T]) (DataFrame[T], error)
+}
+
+// ContextLayer is a layer that can stop early when a context is done, returning its error. PredictContext uses
+// ApplyContext for the layers that implement it.
+type ContextLayer[T SizedNumber] interface {
+	LayerE[T]
+	ApplyContext(ctx context.Context, input DataFrame[T]) (DataFrame[T], error)
+}
+
+// MultiInputLayerE is like LayerE, for layers that take several inputs.
+type MultiInputLayerE[T SizedNumber] interface {
+	MultiInputLayer[T]
+	ApplyMultiE(inputs []DataFrame[T]) (DataFrame[T], error)
+}
+
+// ShapeLayer is a layer that can tell the shape of its output from the shape of its input, without running. In
+// shapes, -1 stands for a dimension whose size is not known in advance, such as the batch dimension. Model.Build
+// uses OutputShape for the layers that implement it.
+type ShapeLayer interface {
+	OutputShape(inputShape []int) ([]int, error)
+}
+
+// MultiInputShapeLayer is like ShapeLayer, for layers that take several inputs.
+type MultiInputShapeLayer interface {
+	OutputShapeMulti(inputShapes [][]int) ([]int, error)
+}
+
+// IntoLayer is a layer that can write its output into a DataFrame it is given, instead of allocating a new one.
+// Models use ApplyInto for the layers that implement it, with buffers they reuse across layers and calls to Predict.
+// dst has the shape OutputShape returns for the shape of input, which has been checked with it; ApplyInto must set
+// all of dst's elements, and must not keep dst or input after it returns.
+type IntoLayer[T SizedNumber] interface {
+	Layer[T]
+	ShapeLayer
+	ApplyInto(dst, input DataFrame[T]) error
+}
+
+// ContextIntoLayer is like ContextLayer, for IntoLayers.
+type ContextIntoLayer[T SizedNumber] interface {
+	IntoLayer[T]
+	ApplyIntoContext(ctx context.Context, dst, input DataFrame[T]) error
+}
+
+// InPlaceLayer is an IntoLayer whose ApplyInto can be given the same DataFrame as dst and input, if SupportsInPlace
+// returns true, such as the element-wise activations. Models run such layers in place when they are the only layer
+// that takes their input, unless the input is one of the model's inputs or outputs.
+type InPlaceLayer[T SizedNumber] interface {
+	IntoLayer[T]
+	SupportsInPlace() bool
+}
+
+// MultiInputIntoLayer is like IntoLayer, for layers that take several inputs.
+type MultiInputIntoLayer[T SizedNumber] interface {
+	MultiInputLayer[T]
+	MultiInputShapeLayer
+	ApplyMultiInto(dst DataFrame[T], inputs []DataFrame[T]) error
+}
+
 type (
 	LayerData[T SizedNumber] struct {
 		model *Model[T]
+		name  string
+		// namePrefix is what the node's name was generated from, if it was not given one
+		namePrefix string
 
-		layer   Layer[T]
-		input   *LayerData[T]
-		outputs []*LayerData[T]
-	}
+		// exactly one of layer and multiLayer is set, except for the model's inputs
+		layer      Layer[T]
+		multiLayer MultiInputLayer[T]
+		inputs     []*LayerData[T]
 
-	outputLayer[T SizedNumber] struct{ df DataFrame[T] }
+		// shape is the node's output shape, as inferred by the last Build
+		shape []int
+	}
 
 	Model[T SizedNumber] struct {
-		input      *LayerData[T]
+		inputs     []*LayerData[T]
 		layersData []*LayerData[T]
 		outputs    []*LayerData[T]
+
+		// names maps the names of all nodes to them, and nameCounts counts the names generated from each prefix
+		names      map[string]*LayerData[T]
+		nameCounts map[string]int
+
+		options modelOptions
+
+		// plan is compiled on first use, and reset whenever the graph changes; hooks is replaced rather than
+		// appended to, so that running models can keep using the old slice
+		planMu sync.Mutex
+		plan   *plan[T]
+		hooks  []Hook[T]
+
+		// buffers keeps the outputs of the layers that implement IntoLayer or MultiInputIntoLayer, once no layer
+		// needs them anymore, for reuse by later layers and calls to Predict
+		buffers bufferPool[T]
+	}
+
+	// ModelOption configures a model in NewModel.
+	ModelOption func(*modelOptions)
+
+	modelOptions struct {
+		parallelism int
 	}
 )
 
-func (ol *outputLayer[T]) Apply(input DataFrame[T]) DataFrame[T] {
-	ol.df = input
-	return DataFrame[T]{}
+// WithParallelism lets Predict run up to n layers at once, where the model's graph has independent branches. The
+// results are the same as when running the layers one by one.
+func WithParallelism(n int) ModelOption {
+	return func(o *modelOptions) {
+		o.parallelism = n
+	}
 }
 
-func NewModel[T SizedNumber](outputs int) *Model[T] {
-	return &Model[T]{
-		input:   &LayerData[T]{layer: nil, input: nil, outputs: nil},
-		outputs: make([]*LayerData[T], outputs),
+func NewModel[T SizedNumber](inputs, outputs int, options ...ModelOption) *Model[T] {
+	m := &Model[T]{
+		inputs:     make([]*LayerData[T], inputs),
+		outputs:    make([]*LayerData[T], outputs),
+		names:      map[string]*LayerData[T]{},
+		nameCounts: map[string]int{},
+	}
+	for _, option := range options {
+		option(&m.options)
 	}
+	for i := range m.inputs {
+		m.inputs[i] = &LayerData[T]{model: m}
+		m.setGeneratedName(m.inputs[i], "input_layer")
+	}
+	return m
+}
+
+// Input returns the node of the model's i-th input, to attach layers to.
+func (m *Model[T]) Input(i int) *LayerData[T] {
+	return m.inputs[i]
 }
 
+// AddLayer adds layer after input. A nil input stands for the model's first input. The layer is named after its type
+// the way Keras names layers (dense, dense_1, ...), unless it is given a name with LayerData.Named.
 func (m *Model[T]) AddLayer(layer Layer[T], input *LayerData[T]) *LayerData[T] {
-	if input == nil {
-		input = m.input
+	return m.addNode(&LayerData[T]{layer: layer}, []*LayerData[T]{input})
+}
+
+// AddMultiInputLayer adds layer after inputs, which it receives in the given order. As in AddLayer, a nil input
+// stands for the model's first input.
+func (m *Model[T]) AddMultiInputLayer(layer MultiInputLayer[T], inputs ...*LayerData[T]) *LayerData[T] {
+	if len(inputs) == 0 {
+		panic("multi-input layer added with no inputs")
+	}
+	return m.addNode(&LayerData[T]{multiLayer: layer}, inputs)
+}
+
+func (m *Model[T]) addNode(node *LayerData[T], inputs []*LayerData[T]) *LayerData[T] {
+	for i, input := range inputs {
+		if input == nil {
+			inputs[i] = m.inputs[0]
+		}
+	}
+	node.model = m
+	node.inputs = inputs
+	m.setGeneratedName(node, kerasSnakeCase(node.typeName()))
+	m.layersData = append(m.layersData, node)
+	m.resetPlan()
+	return node
+}
+
+// setGeneratedName names node prefix, prefix_1, prefix_2, ..., skipping the names that are taken.
+func (m *Model[T]) setGeneratedName(node *LayerData[T], prefix string) {
+	for {
+		name := prefix
+		if count := m.nameCounts[prefix]; count > 0 {
+			name += "_" + strconv.Itoa(count)
+		}
+		m.nameCounts[prefix]++
+		if _, ok := m.names[name]; !ok {
+			node.name, node.namePrefix = name, prefix
+			m.names[name] = node
+			return
+		}
+	}
+}
+
+// typeName returns the name of the node's layer type, without its package and type arguments.
+func (d *LayerData[T]) typeName() string {
+	var layer any = d.layer
+	if d.multiLayer != nil {
+		layer = d.multiLayer
 	}
-	current := &LayerData[T]{
-		model: m,
-		layer: layer,
-		input: input,
+	name := strings.TrimPrefix(fmt.Sprintf("%T", layer), "*")
+	if i := strings.IndexByte(name, '['); i >= 0 {
+		name = name[:i]
 	}
-	m.layersData = append(m.layersData, current)
-	input.outputs = append(input.outputs, current)
-	return current
+	return name[strings.LastIndexByte(name, '.')+1:]
+}
+
+// Layer returns the node named name, which may also be one of the model's inputs, or nil if there is none.
+func (m *Model[T]) Layer(name string) *LayerData[T] {
+	return m.names[name]
+}
+
+// Layers returns the model's layer nodes in the order they were added, without its inputs.
+func (m *Model[T]) Layers() []*LayerData[T] {
+	return append([]*LayerData[T]{}, m.layersData...)
 }
 
 func (d *LayerData[T]) AddLayer(layer Layer[T]) *LayerData[T] {
 	return d.model.AddLayer(layer, d)
 }
 
-func (m *Model[T]) SetOutput(input *LayerData[T], index int) {
-	m.outputs[index] = &LayerData[T]{
-		layer: &outputLayer[T]{},
-		input: input,
+// Named renames the node to name and returns it, so that it can follow AddLayer. If another node has name as its
+// generated name, that node gets a new one; Named panics if another node was given name with Named.
+func (d *LayerData[T]) Named(name string) *LayerData[T] {
+	m := d.model
+	if other, ok := m.names[name]; ok && other != d {
+		if other.namePrefix == "" {
+			panic(fmt.Sprintf("a layer named %s already exists", name))
+		}
+		m.setGeneratedName(other, other.namePrefix)
+	}
+	if m.names[d.name] == d {
+		delete(m.names, d.name)
 	}
-	input.outputs = append(input.outputs, m.outputs[index])
+	d.name, d.namePrefix = name, ""
+	m.names[name] = d
+	return d
+}
+
+func (d *LayerData[T]) Name() string {
+	return d.name
+}
+
+// Layer returns the node's layer, or nil if the node is one of the model's inputs or a multi-input layer.
+func (d *LayerData[T]) Layer() Layer[T] {
+	return d.layer
+}
+
+// MultiInputLayer returns the node's layer if it is a multi-input layer, and nil otherwise.
+func (d *LayerData[T]) MultiInputLayer() MultiInputLayer[T] {
+	return d.multiLayer
+}
+
+// Inputs returns the nodes the node takes its inputs from, in order.
+func (d *LayerData[T]) Inputs() []*LayerData[T] {
+	return append([]*LayerData[T]{}, d.inputs...)
+}
+
+// Shape returns the shape of the node's output, as inferred by the last call to Model.Build. It is nil if Build was
+// not called, or if the shape could not be inferred because a layer before the node does not implement ShapeLayer
+// or MultiInputShapeLayer.
+func (d *LayerData[T]) Shape() []int {
+	return d.shape
+}
+
+func (m *Model[T]) SetOutput(input *LayerData[T], index int) {
+	m.outputs[index] = input
+	m.resetPlan()
 }
 
-func (ld *LayerData[T]) runLayer(input DataFrame[T]) {
-	output := ld.layer.Apply(input)
-	for _, l := range ld.outputs {
-		l.runLayer(output)
+func (m *Model[T]) resetPlan() {
+	m.planMu.Lock()
+	defer m.planMu.Unlock()
+	m.plan = nil
+}
+
+// Compile checks the model's graph and prepares it for Predict, which otherwise does so on its first call.
+func (m *Model[T]) Compile() error {
+	_, err := m.compiledPlan()
+	return err
+}
+
+// Build infers the shape of every node's output from the shapes of the model's inputs, and records them for
+// LayerData.Shape. It returns a *LayerError for the first layer, in the order the model runs them, that cannot take
+// the shape of its input.
+func (m *Model[T]) Build(inputShapes ...[]int) error {
+	if len(inputShapes) != len(m.inputs) {
+		return fmt.Errorf("%w: model has %d inputs, but %d shapes were given", ErrInvalidInput, len(m.inputs),
+			len(inputShapes))
+	}
+	p, err := m.compiledPlan()
+	if err != nil {
+		return err
+	}
+	shapes := make([][]int, p.slots)
+	for i, input := range m.inputs {
+		shapes[i] = append([]int{}, inputShapes[i]...)
+		input.shape = shapes[i]
 	}
+	for _, node := range m.layersData {
+		node.shape = nil
+	}
+	for _, s := range p.steps {
+		if shapes[s.output], err = s.outputShape(shapes); err != nil {
+			return err
+		}
+		s.node.shape = shapes[s.output]
+	}
+	return nil
 }
 
-func (m *Model[T]) Predict(input DataFrame[T]) []DataFrame[T] {
-	for _, l := range m.input.outputs {
-		l.runLayer(input)
+func (m *Model[T]) compiledPlan() (*plan[T], error) {
+	m.planMu.Lock()
+	defer m.planMu.Unlock()
+	if m.plan == nil {
+		p, err := compilePlan(m)
+		if err != nil {
+			return nil, err
+		}
+		m.plan = p
 	}
+	return m.plan, nil
+}
 
-	outputs := make([]DataFrame[T], len(m.outputs))
-	for i := 0; i < len(outputs); i++ {
-		outputs[i] = (m.outputs[i].layer).(*outputLayer[T]).df
+// Predict runs the model on inputs. It is safe to call from several goroutines at once. Predict panics where
+// PredictE would return an error.
+//
+// The outputs of the layers that implement IntoLayer or MultiInputIntoLayer are written into buffers the model
+// reuses once no later layer needs them, so that a model run again on inputs of the same shapes hardly allocates.
+// The model's outputs are never reused, and belong to the caller.
+func (m *Model[T]) Predict(inputs ...DataFrame[T]) []DataFrame[T] {
+	outputs, err := m.PredictE(inputs...)
+	if err != nil {
+		panic(err)
 	}
 	return outputs
 }
+
+// PredictE is like Predict, but returns an error for an invalid model or invalid inputs. The errors of layers are
+// wrapped in a *LayerError; only the layers that implement LayerE or MultiInputLayerE return errors, and the
+// others still panic.
+func (m *Model[T]) PredictE(inputs ...DataFrame[T]) ([]DataFrame[T], error) {
+	return m.PredictContext(context.Background(), inputs...)
+}
+
+// PredictContext is like PredictE, but stops when ctx is done, and returns ctx.Err(). It checks ctx between
+// layers, and layers that implement ContextLayer, such as Dense, check it as they run.
+func (m *Model[T]) PredictContext(ctx context.Context, inputs ...DataFrame[T]) ([]DataFrame[T], error) {
+	p, values, err := m.run(runOptions[T]{ctx: ctx}, inputs)
+	if err != nil {
+		return nil, err
+	}
+	return p.outputValues(values), nil
+}
+
+// PredictWithActivations is like PredictE, but returns the outputs of all the model's nodes, including its inputs,
+// to compare them with the outputs of the layers of the Keras model the model was loaded from, for example. All the
+// outputs are kept until it returns, so it takes more memory than PredictE.
+func (m *Model[T]) PredictWithActivations(inputs ...DataFrame[T]) (map[*LayerData[T]]DataFrame[T], error) {
+	p, values, err := m.run(runOptions[T]{ctx: context.Background(), keep: true}, inputs)
+	if err != nil {
+		return nil, err
+	}
+	activations := make(map[*LayerData[T]]DataFrame[T], len(values))
+	for i, input := range m.inputs {
+		activations[input] = values[i]
+	}
+	for _, s := range p.steps {
+		activations[s.node] = values[s.output]
+	}
+	return activations, nil
+}
+
+// checkDims checks that dims are the dimensions of size elements, which the layers rely on when they run: unlike
+// the shapes of Build, they cannot be -1.
+func checkDims(dims []int, size int) error {
+	elements := 1
+	for _, d := range dims {
+		if d < 0 {
+			return fmt.Errorf("negative dimension in %v", dims)
+		}
+		if d != 0 && elements > math.MaxInt/d {
+			return fmt.Errorf("dimensions %v are too large", dims)
+		}
+		elements *= d
+	}
+	if elements != size {
+		return fmt.Errorf("dimensions %v hold %d elements, but there are %d", dims, elements, size)
+	}
+	return nil
+}
+
+// run runs the model's plan on inputs, and returns the plan with the values of its slots. The hooks are taken from
+// the model.
+func (m *Model[T]) run(o runOptions[T], inputs []DataFrame[T]) (*plan[T], []DataFrame[T], error) {
+	if len(inputs) != len(m.inputs) {
+		return nil, nil, fmt.Errorf("%w: model has %d inputs, but %d were given", ErrInvalidInput, len(m.inputs),
+			len(inputs))
+	}
+	for i, input := range inputs {
+		if err := checkDims(input.Dims, len(input.Data)); err != nil {
+			return nil, nil, fmt.Errorf("%w: input %d: %v", ErrInvalidInput, i, err)
+		}
+	}
+	p, err := m.compiledPlan()
+	if err != nil {
+		return nil, nil, err
+	}
+	m.planMu.Lock()
+	o.hooks = m.hooks
+	m.planMu.Unlock()
+	o.pool = &m.buffers
+	var values []DataFrame[T]
+	if m.options.parallelism > 1 {
+		values, err = p.runParallel(inputs, m.options.parallelism, o)
+	} else {
+		values, err = p.run(inputs, o)
+	}
+	return p, values, err
+}
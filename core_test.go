@@ -0,0 +1,589 @@
+package elefas_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"runtime"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/YohayAiTe/elefas"
+	"github.com/YohayAiTe/elefas/layer"
+)
+
+type scaleLayer struct{ factor float32 }
+
+func (l scaleLayer) Apply(input elefas.DataFrame[float32]) elefas.DataFrame[float32] {
+	output := elefas.MakeDataFrame[float32](input.Dims)
+	for i, v := range input.Data {
+		output.Data[i] = v * l.factor
+	}
+	return output
+}
+
+func TestMultiInputModel(t *testing.T) {
+	model := elefas.NewModel[float32](2, 2)
+	model.SetOutput(model.Input(0).AddLayer(scaleLayer{2}), 0)
+	model.SetOutput(model.Input(1).AddLayer(scaleLayer{-1}).AddLayer(scaleLayer{3}), 1)
+
+	outputs := model.Predict(
+		elefas.DataFrame[float32]{Dims: []int{1, 2}, Data: []float32{1, 2}},
+		elefas.DataFrame[float32]{Dims: []int{1, 3}, Data: []float32{1, 0, -1}},
+	)
+	expected := []elefas.DataFrame[float32]{
+		{Dims: []int{1, 2}, Data: []float32{2, 4}},
+		{Dims: []int{1, 3}, Data: []float32{-3, 0, 3}},
+	}
+	if !reflect.DeepEqual(outputs, expected) {
+		t.Errorf("predicted %v, expected %v", outputs, expected)
+	}
+}
+
+func TestPredictInputCount(t *testing.T) {
+	model := elefas.NewModel[float32](2, 1)
+	model.SetOutput(model.AddLayer(scaleLayer{1}, nil), 0)
+	defer func() {
+		if recover() == nil {
+			t.Error("Predict with 1 input on a model with 2 inputs did not panic")
+		}
+	}()
+	model.Predict(elefas.DataFrame[float32]{Dims: []int{1}, Data: []float32{1}})
+}
+
+type sumLayer struct{}
+
+func (sumLayer) ApplyMulti(inputs []elefas.DataFrame[float32]) elefas.DataFrame[float32] {
+	output := elefas.MakeDataFrame[float32](inputs[0].Dims)
+	for _, input := range inputs {
+		for i, v := range input.Data {
+			output.Data[i] += v
+		}
+	}
+	return output
+}
+
+func TestMultiInputLayer(t *testing.T) {
+	model := elefas.NewModel[float32](2, 2)
+	scaled := model.Input(0).AddLayer(scaleLayer{2})
+	residual := model.AddMultiInputLayer(sumLayer{}, model.Input(0), scaled)
+	model.SetOutput(model.AddMultiInputLayer(sumLayer{}, residual, model.Input(1), residual), 0)
+	model.SetOutput(model.AddMultiInputLayer(sumLayer{}, model.Input(1), model.Input(1)), 1)
+
+	outputs := model.Predict(
+		elefas.DataFrame[float32]{Dims: []int{1, 2}, Data: []float32{1, 2}},
+		elefas.DataFrame[float32]{Dims: []int{1, 2}, Data: []float32{10, 20}},
+	)
+	expected := []elefas.DataFrame[float32]{
+		{Dims: []int{1, 2}, Data: []float32{16, 32}},
+		{Dims: []int{1, 2}, Data: []float32{20, 40}},
+	}
+	if !reflect.DeepEqual(outputs, expected) {
+		t.Errorf("predicted %v, expected %v", outputs, expected)
+	}
+}
+
+// TestPredictConcurrent should be run with the race detector.
+func TestPredictConcurrent(t *testing.T) {
+	model := elefas.NewModel[float32](1, 2)
+	scaled := model.Input(0).AddLayer(scaleLayer{2})
+	model.SetOutput(model.AddMultiInputLayer(sumLayer{}, model.Input(0), scaled), 0)
+	model.SetOutput(scaled.AddLayer(scaleLayer{-1}), 1)
+
+	const goroutines, iterations = 8, 100
+	errs := make(chan string, goroutines)
+	var wg sync.WaitGroup
+	for g := 0; g < goroutines; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			for i := 0; i < iterations; i++ {
+				v := float32(g*iterations + i)
+				outputs := model.Predict(elefas.DataFrame[float32]{Dims: []int{1, 1}, Data: []float32{v}})
+				if outputs[0].Data[0] != 3*v || outputs[1].Data[0] != -2*v {
+					errs <- fmt.Sprintf("predicted %v and %v for %v", outputs[0].Data, outputs[1].Data, v)
+					return
+				}
+			}
+		}(g)
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Error(err)
+	}
+}
+
+func TestCompileErrors(t *testing.T) {
+	unset := elefas.NewModel[float32](1, 2)
+	unset.SetOutput(unset.AddLayer(scaleLayer{1}, nil), 0)
+
+	dangling := elefas.NewModel[float32](1, 1)
+	dangling.SetOutput(dangling.AddLayer(scaleLayer{1}, nil), 0)
+	dangling.AddLayer(scaleLayer{2}, nil)
+
+	other := elefas.NewModel[float32](1, 1)
+	foreign := elefas.NewModel[float32](1, 1)
+	foreign.SetOutput(foreign.AddLayer(scaleLayer{1}, other.AddLayer(scaleLayer{1}, nil)), 0)
+
+	for name, model := range map[string]*elefas.Model[float32]{
+		"unset output":  unset,
+		"dangling":      dangling,
+		"another model": foreign,
+	} {
+		if err := model.Compile(); !errors.Is(err, elefas.ErrInvalidModel) {
+			t.Errorf("%s: Compile returned %v, expected %v", name, err, elefas.ErrInvalidModel)
+		}
+	}
+}
+
+type panicLayer struct{}
+
+func (panicLayer) Apply(elefas.DataFrame[float32]) elefas.DataFrame[float32] {
+	panic("panicLayer")
+}
+
+// branchedModel builds a model with several independent branches, merged into two outputs.
+func branchedModel(options ...elefas.ModelOption) *elefas.Model[float32] {
+	model := elefas.NewModel[float32](2, 2, options...)
+	var branches []*elefas.LayerData[float32]
+	for i := 0; i < 6; i++ {
+		branch := model.Input(i % 2)
+		for j := 0; j <= i; j++ {
+			branch = branch.AddLayer(scaleLayer{float32(i+1) / float32(j+2)})
+		}
+		branches = append(branches, branch)
+	}
+	model.SetOutput(model.AddMultiInputLayer(sumLayer{}, branches[:4]...), 0)
+	model.SetOutput(model.AddMultiInputLayer(sumLayer{}, branches[3:]...), 1)
+	return model
+}
+
+func TestPredictParallel(t *testing.T) {
+	inputs := []elefas.DataFrame[float32]{
+		{Dims: []int{1, 3}, Data: []float32{0.1, -2.3, 4.5}},
+		{Dims: []int{1, 3}, Data: []float32{7.1, 0.3, -1.9}},
+	}
+	expected := branchedModel().Predict(inputs...)
+	for _, parallelism := range []int{1, 2, 4, 16} {
+		outputs := branchedModel(elefas.WithParallelism(parallelism)).Predict(inputs...)
+		if !reflect.DeepEqual(outputs, expected) {
+			t.Errorf("parallelism %d: predicted %v, expected %v", parallelism, outputs, expected)
+		}
+	}
+
+	// should be run with the race detector
+	model := branchedModel(elefas.WithParallelism(4))
+	var wg sync.WaitGroup
+	for g := 0; g < 4; g++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 50; i++ {
+				if outputs := model.Predict(inputs...); !reflect.DeepEqual(outputs, expected) {
+					t.Errorf("predicted %v concurrently, expected %v", outputs, expected)
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+func TestPredictParallelPanic(t *testing.T) {
+	model := elefas.NewModel[float32](1, 2, elefas.WithParallelism(2))
+	model.SetOutput(model.Input(0).AddLayer(scaleLayer{2}).AddLayer(scaleLayer{3}), 0)
+	model.SetOutput(model.Input(0).AddLayer(panicLayer{}).AddLayer(scaleLayer{3}), 1)
+	defer func() {
+		if r := recover(); r != "panicLayer" {
+			t.Errorf("Predict recovered %v, expected the layer's panic", r)
+		}
+	}()
+	model.Predict(elefas.DataFrame[float32]{Dims: []int{1, 1}, Data: []float32{1}})
+}
+
+func TestPredictE(t *testing.T) {
+	for _, parallelism := range []int{1, 2} {
+		model := elefas.NewModel[float32](1, 1, elefas.WithParallelism(parallelism))
+		kernel := elefas.MakeDataFrame[float32]([]int{3, 2})
+		bias := elefas.MakeDataFrame[float32]([]int{2})
+		model.SetOutput(model.Input(0).AddLayer(scaleLayer{2}).AddLayer(layer.NewDense(kernel, bias)), 0)
+
+		if _, err := model.PredictE(); !errors.Is(err, elefas.ErrInvalidInput) {
+			t.Errorf("PredictE with no inputs returned %v, expected %v", err, elefas.ErrInvalidInput)
+		}
+		if _, err := model.PredictE(elefas.MakeDataFrame[float32]([]int{4, 3})); err != nil {
+			t.Errorf("PredictE returned %v", err)
+		}
+
+		_, err := model.PredictE(elefas.MakeDataFrame[float32]([]int{4, 2}))
+		var layerErr *elefas.LayerError
+		var shapeErr *elefas.ShapeError
+		if !errors.As(err, &layerErr) || !errors.As(err, &shapeErr) || !errors.Is(err, elefas.ErrShapeMismatch) {
+			t.Fatalf("PredictE returned %v, expected a shape mismatch", err)
+		}
+		if layerErr.Index != 1 || layerErr.Name != "dense" {
+			t.Errorf("error is for layer %s (%d), expected dense (1)", layerErr.Name, layerErr.Index)
+		}
+		if !reflect.DeepEqual(shapeErr.Expected, []int{-1, 3}) || !reflect.DeepEqual(shapeErr.Actual, []int{4, 2}) {
+			t.Errorf("error expects shape %v and got %v", shapeErr.Expected, shapeErr.Actual)
+		}
+
+		func() {
+			defer func() {
+				if r, ok := recover().(error); !ok || !errors.Is(r, elefas.ErrShapeMismatch) {
+					t.Errorf("Predict recovered %v, expected a shape mismatch", r)
+				}
+			}()
+			model.Predict(elefas.MakeDataFrame[float32]([]int{4, 2}))
+		}()
+	}
+}
+
+func TestPredictMalformedInput(t *testing.T) {
+	for _, parallelism := range []int{1, 2} {
+		dense := elefas.NewModel[float32](1, 1, elefas.WithParallelism(parallelism))
+		kernel := elefas.MakeDataFrame[float32]([]int{3, 2})
+		bias := elefas.MakeDataFrame[float32]([]int{2})
+		dense.SetOutput(dense.Input(0).AddLayer(layer.NewDense(kernel, bias)), 0)
+		pooling := elefas.NewModel[float32](1, 1, elefas.WithParallelism(parallelism))
+		pooling.SetOutput(pooling.Input(0).AddLayer(layer.NewMaxPooling2D[float32](layer.PoolingOptions{})), 0)
+
+		for _, test := range []struct {
+			name  string
+			model *elefas.Model[float32]
+			input elefas.DataFrame[float32]
+		}{
+			{"dense short data", dense, elefas.DataFrame[float32]{Dims: []int{2, 3}, Data: make([]float32, 4)}},
+			{"dense long data", dense, elefas.DataFrame[float32]{Dims: []int{2, 3}, Data: make([]float32, 8)}},
+			// -1 stands for an unknown dimension only in the shapes of Build
+			{"dense negative", dense, elefas.DataFrame[float32]{Dims: []int{-1, 3}, Data: make([]float32, 6)}},
+			{"pooling short data", pooling, elefas.DataFrame[float32]{Dims: []int{1, 4, 4, 1},
+				Data: make([]float32, 3)}},
+			{"pooling negative", pooling, elefas.DataFrame[float32]{Dims: []int{1, -1, 4, 1},
+				Data: make([]float32, 16)}},
+		} {
+			if _, err := test.model.PredictE(test.input); !errors.Is(err, elefas.ErrInvalidInput) {
+				t.Errorf("%s: PredictE returned %v, expected %v", test.name, err, elefas.ErrInvalidInput)
+			}
+		}
+	}
+}
+
+func TestModelBuild(t *testing.T) {
+	dense := func(in, out int) layer.Dense[float32] {
+		return layer.NewDense(elefas.MakeDataFrame[float32]([]int{in, out}), elefas.MakeDataFrame[float32]([]int{out}))
+	}
+	model := elefas.NewModel[float32](2, 1)
+	left := model.Input(0).AddLayer(dense(3, 4))
+	right := model.Input(1).AddLayer(scaleLayer{1})
+	merged := model.AddMultiInputLayer(layer.Concatenate[float32]{Axis: -1}, left, model.Input(1))
+	output := merged.AddLayer(dense(6, 5))
+	model.SetOutput(model.AddMultiInputLayer(sumLayer{}, output, right), 0)
+
+	if err := model.Build([]int{-1, 3}, []int{-1, 2}); err != nil {
+		t.Fatalf("Build returned %v", err)
+	}
+	for _, test := range []struct {
+		node     *elefas.LayerData[float32]
+		expected []int
+	}{
+		{model.Input(1), []int{-1, 2}},
+		{left, []int{-1, 4}},
+		{right, nil}, // scaleLayer does not report its shape
+		{merged, []int{-1, 6}},
+		{output, []int{-1, 5}},
+	} {
+		if !reflect.DeepEqual(test.node.Shape(), test.expected) {
+			t.Errorf("node has shape %v, expected %v", test.node.Shape(), test.expected)
+		}
+	}
+
+	err := model.Build([]int{-1, 3}, []int{-1, 3})
+	var layerErr *elefas.LayerError
+	if !errors.As(err, &layerErr) || !errors.Is(err, elefas.ErrShapeMismatch) {
+		t.Fatalf("Build returned %v, expected a shape mismatch", err)
+	}
+	if layerErr.Index != 3 {
+		t.Errorf("error is for layer %d, expected 3", layerErr.Index)
+	}
+	if err := model.Build([]int{-1, 3}); !errors.Is(err, elefas.ErrInvalidInput) {
+		t.Errorf("Build with 1 shape returned %v, expected %v", err, elefas.ErrInvalidInput)
+	}
+}
+
+func TestModelSummary(t *testing.T) {
+	model := elefas.NewModel[float32](2, 1)
+	dense := model.Input(0).AddLayer(
+		layer.NewDense(elefas.MakeDataFrame[float32]([]int{3, 4}), elefas.MakeDataFrame[float32]([]int{4})))
+	merged := model.AddMultiInputLayer(layer.Concatenate[float32]{Axis: -1}, dense, model.Input(1))
+	model.SetOutput(merged.AddLayer(&layer.SoftmaxActivation[float32]{Axis: -1}).Named("probabilities"), 0)
+	if err := model.Build([]int{-1, 3}, []int{-1, 2}); err != nil {
+		t.Fatal(err)
+	}
+
+	var b strings.Builder
+	model.Summary(&b)
+	expected := `=================================================================================
+Layer (type)                        Output Shape   Param #   Connected to
+=================================================================================
+input_layer (Input)                 (None, 3)      0
+input_layer_1 (Input)               (None, 2)      0
+dense (Dense)                       (None, 4)      16        input_layer
+concatenate (Concatenate)           (None, 6)      0         dense, input_layer_1
+probabilities (SoftmaxActivation)   (None, 6)      0         concatenate
+=================================================================================
+Total params: 16 (64 B)
+Memory by dtype: float32: 64 B
+`
+	if b.String() != expected {
+		t.Errorf("summary is\n%s\nexpected\n%s", b.String(), expected)
+	}
+}
+
+func TestLayerNames(t *testing.T) {
+	model := elefas.NewModel[float32](1, 1)
+	first := model.Input(0).AddLayer(scaleLayer{1})
+	second := first.AddLayer(scaleLayer{2})
+	third := second.AddLayer(scaleLayer{3}).Named("scale_layer_2")
+	// naming a node scale_layer_1 takes the name from second, which only has a generated name
+	fourth := third.AddLayer(scaleLayer{4}).Named("scale_layer_1")
+	model.SetOutput(fourth, 0)
+
+	names := map[*elefas.LayerData[float32]]string{
+		model.Input(0): "input_layer",
+		first:          "scale_layer",
+		second:         "scale_layer_4",
+		third:          "scale_layer_2",
+		fourth:         "scale_layer_1",
+	}
+	for node, name := range names {
+		if node.Name() != name {
+			t.Errorf("node is named %s, expected %s", node.Name(), name)
+		}
+		if model.Layer(name) != node {
+			t.Errorf("Layer(%q) returned %v", name, model.Layer(name))
+		}
+	}
+	if model.Layer("scale") != nil {
+		t.Errorf("Layer returned a node for an unknown name")
+	}
+	expected := []*elefas.LayerData[float32]{first, second, third, fourth}
+	if layers := model.Layers(); !reflect.DeepEqual(layers, expected) {
+		t.Errorf("Layers returned %v", layers)
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Error("Named did not panic for a name given with Named")
+		}
+	}()
+	first.Named("scale_layer_2")
+}
+
+func TestPredictWithActivations(t *testing.T) {
+	for _, parallelism := range []int{1, 2} {
+		model := elefas.NewModel[float32](1, 1, elefas.WithParallelism(parallelism))
+		scaled := model.Input(0).AddLayer(scaleLayer{2})
+		negated := scaled.AddLayer(scaleLayer{-1})
+		sum := model.AddMultiInputLayer(sumLayer{}, scaled, negated, model.Input(0))
+		model.SetOutput(sum, 0)
+
+		input := elefas.DataFrame[float32]{Dims: []int{1, 2}, Data: []float32{1, 2}}
+		activations, err := model.PredictWithActivations(input)
+		if err != nil {
+			t.Fatal(err)
+		}
+		expected := map[*elefas.LayerData[float32]]elefas.DataFrame[float32]{
+			model.Input(0): input,
+			scaled:         {Dims: []int{1, 2}, Data: []float32{2, 4}},
+			negated:        {Dims: []int{1, 2}, Data: []float32{-2, -4}},
+			sum:            {Dims: []int{1, 2}, Data: []float32{1, 2}},
+		}
+		if !reflect.DeepEqual(activations, expected) {
+			t.Errorf("parallelism %d: activations are %v, expected %v", parallelism, activations, expected)
+		}
+
+		var b bytes.Buffer
+		if err := model.SaveActivations(activations, &b); err != nil {
+			t.Fatal(err)
+		}
+		saved, names, err := elefas.LoadNumpyDataFrameMap[float32](bytes.NewReader(b.Bytes()))
+		if err != nil {
+			t.Fatal(err)
+		}
+		expectedNames := []string{"input_layer", "scale_layer", "scale_layer_1", "sum_layer"}
+		if !reflect.DeepEqual(names, expectedNames) {
+			t.Errorf("saved %v, expected %v", names, expectedNames)
+		}
+		for node, df := range expected {
+			if !reflect.DeepEqual(saved[node.Name()], df) {
+				t.Errorf("saved %v for %s, expected %v", saved[node.Name()], node.Name(), df)
+			}
+		}
+	}
+}
+
+// cancelLayer cancels a context when it runs, and counts how many times it ran.
+type cancelLayer struct {
+	cancel context.CancelFunc
+	calls  *int32
+}
+
+func (l cancelLayer) Apply(input elefas.DataFrame[float32]) elefas.DataFrame[float32] {
+	atomic.AddInt32(l.calls, 1)
+	l.cancel()
+	return input
+}
+
+func TestPredictContext(t *testing.T) {
+	for _, parallelism := range []int{1, 2} {
+		ctx, cancel := context.WithCancel(context.Background())
+		var calls int32
+		model := elefas.NewModel[float32](1, 1, elefas.WithParallelism(parallelism))
+		node := model.Input(0).AddLayer(cancelLayer{cancel, &calls})
+		model.SetOutput(node.AddLayer(cancelLayer{cancel, &calls}), 0)
+
+		input := elefas.DataFrame[float32]{Dims: []int{1, 1}, Data: []float32{1}}
+		if _, err := model.PredictContext(ctx, input); err != context.Canceled {
+			t.Errorf("parallelism %d: PredictContext returned %v, expected %v", parallelism, err, context.Canceled)
+		}
+		if calls != 1 {
+			t.Errorf("parallelism %d: %d layers ran after the context was canceled", parallelism, calls-1)
+		}
+		if _, err := model.PredictContext(ctx, input); err != context.Canceled {
+			t.Errorf("parallelism %d: PredictContext returned %v for a canceled context", parallelism, err)
+		}
+	}
+}
+
+func TestPredictReusesBuffers(t *testing.T) {
+	dense := func(in, out int) layer.Dense[float32] {
+		kernel, bias := elefas.MakeDataFrame[float32]([]int{in, out}), elefas.MakeDataFrame[float32]([]int{out})
+		for i := range kernel.Data {
+			kernel.Data[i] = float32(i%7)/7 - 0.4
+		}
+		for i := range bias.Data {
+			bias.Data[i] = float32(i%3) / 3
+		}
+		return layer.NewDense(kernel, bias)
+	}
+	dense1, dense2, dense3 := dense(4, 8), dense(8, 8), dense(8, 3)
+	relu, sigmoid := layer.NewReLUActivation[float32](), &layer.SigmoidActivation[float32]{}
+
+	// scaleLayer does not implement IntoLayer, so the buffer of its input must not be reused while it runs
+	for _, parallelism := range []int{1, 4} {
+		model := elefas.NewModel[float32](1, 2, elefas.WithParallelism(parallelism))
+		hidden := model.AddLayer(dense1, nil).AddLayer(relu).AddLayer(dense2)
+		sum := model.AddMultiInputLayer(layer.Add[float32]{}, hidden.AddLayer(sigmoid), hidden.AddLayer(scaleLayer{2}))
+		model.SetOutput(sum.AddLayer(dense3), 0)
+		model.SetOutput(hidden, 1)
+
+		for call := 0; call < 5; call++ {
+			input := elefas.MakeDataFrame[float32]([]int{3, 4})
+			for i := range input.Data {
+				input.Data[i] = float32((i+call)%5) - 2
+			}
+			h := dense2.Apply(relu.Apply(dense1.Apply(input)))
+			expected := []elefas.DataFrame[float32]{
+				dense3.Apply(layer.Add[float32]{}.ApplyMulti(
+					[]elefas.DataFrame[float32]{sigmoid.Apply(h), scaleLayer{2}.Apply(h)})),
+				h,
+			}
+			if outputs := model.Predict(input); !reflect.DeepEqual(outputs, expected) {
+				t.Errorf("parallelism %d, call %d: predicted %v, expected %v", parallelism, call, outputs, expected)
+			}
+		}
+	}
+}
+
+// raceEnabled is set when testing with the race detector.
+var raceEnabled bool
+
+func TestPredictAllocations(t *testing.T) {
+	if raceEnabled {
+		t.Skip("buffers are not always reused with the race detector")
+	}
+	const batch, units, layers = 32, 64, 6
+	model := elefas.NewModel[float32](1, 1)
+	node := model.Input(0)
+	for i := 0; i < layers; i++ {
+		node = node.AddLayer(layer.NewDense(elefas.MakeDataFrame[float32]([]int{units, units}),
+			elefas.MakeDataFrame[float32]([]int{units}))).AddLayer(layer.NewReLUActivation[float32]())
+	}
+	model.SetOutput(node, 0)
+	input := elefas.MakeDataFrame[float32]([]int{batch, units})
+	model.Predict(input)
+
+	// only the output should need a new buffer
+	const calls = 50
+	var before, after runtime.MemStats
+	runtime.ReadMemStats(&before)
+	for i := 0; i < calls; i++ {
+		model.Predict(input)
+	}
+	runtime.ReadMemStats(&after)
+	perCall := (after.TotalAlloc - before.TotalAlloc) / calls
+	if bufferSize := uint64(batch * units * 4); perCall > 2*bufferSize {
+		t.Errorf("Predict allocated %d bytes per call, expected about %d for the output", perCall, bufferSize)
+	}
+}
+
+func TestPredictAllocationsSeparable(t *testing.T) {
+	if raceEnabled {
+		t.Skip("buffers are not always reused with the race detector")
+	}
+	// the depthwise convolution has 4 times as many channels as the input and the output, so that allocating its
+	// output on every call would be 4 times the output
+	const batch, size, channels, multiplier = 4, 16, 8, 4
+	model := elefas.NewModel[float32](1, 1)
+	model.SetOutput(model.Input(0).AddLayer(layer.NewSeparableConv2D(
+		elefas.MakeDataFrame[float32]([]int{3, 3, channels, multiplier}),
+		elefas.MakeDataFrame[float32]([]int{1, 1, channels * multiplier, channels}),
+		elefas.MakeDataFrame[float32]([]int{channels}), layer.ConvOptions{Padding: layer.PaddingSame})), 0)
+	input := elefas.MakeDataFrame[float32]([]int{batch, size, size, channels})
+	model.Predict(input)
+
+	const calls = 50
+	var before, after runtime.MemStats
+	runtime.ReadMemStats(&before)
+	for i := 0; i < calls; i++ {
+		model.Predict(input)
+	}
+	runtime.ReadMemStats(&after)
+	perCall := (after.TotalAlloc - before.TotalAlloc) / calls
+	if bufferSize := uint64(batch * size * size * channels * 4); perCall > 2*bufferSize {
+		t.Errorf("Predict allocated %d bytes per call, expected about %d for the output", perCall, bufferSize)
+	}
+}
+
+func TestPredictInPlace(t *testing.T) {
+	identity := elefas.DataFrame[float32]{Dims: []int{2, 2}, Data: []float32{1, 0, 0, 1}}
+	dense := layer.NewDense(identity, elefas.MakeDataFrame[float32]([]int{2}))
+	relu, sigmoid, tanh := layer.NewReLUActivation[float32](), &layer.SigmoidActivation[float32]{},
+		&layer.TanhActivation[float32]{}
+
+	// the sigmoid is the only layer that takes the output of dense, so it runs in place; relu must not overwrite
+	// the model's input, nor tanh the output of dense_1, which is also an output of the model
+	model := elefas.NewModel[float32](1, 4)
+	model.SetOutput(model.AddLayer(relu, nil), 0)
+	model.SetOutput(model.AddLayer(dense, nil).AddLayer(sigmoid), 1)
+	hidden := model.AddLayer(dense, nil)
+	model.SetOutput(hidden.AddLayer(tanh), 2)
+	model.SetOutput(hidden, 3)
+
+	input := elefas.DataFrame[float32]{Dims: []int{1, 2}, Data: []float32{-1, 2}}
+	expected := []elefas.DataFrame[float32]{relu.Apply(input), sigmoid.Apply(input), tanh.Apply(input), input}
+	for call := 0; call < 3; call++ {
+		if outputs := model.Predict(input); !reflect.DeepEqual(outputs, expected) {
+			t.Errorf("call %d: predicted %v, expected %v", call, outputs, expected)
+		}
+		if !reflect.DeepEqual(input.Data, []float32{-1, 2}) {
+			t.Fatalf("call %d: the input was changed to %v", call, input.Data)
+		}
+	}
+}
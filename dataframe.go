@@ -36,12 +36,22 @@ func (df DataFrame[T]) FlatAt(i int) T       { return df.Data[i] }
 func (df DataFrame[T]) SetFlatAt(v T, i int) { df.Data[i] = v }
 
 func (df DataFrame[T]) Index(indices ...int) int {
+	idx, err := df.IndexE(indices...)
+	if err != nil {
+		panic(err)
+	}
+	return idx
+}
+
+// IndexE is like Index, but returns an error instead of panicking when the indices do not fit df.
+func (df DataFrame[T]) IndexE(indices ...int) (int, error) {
 	if len(indices) != len(df.Dims) {
-		panic("number of indices does not match number of dims")
+		return 0, fmt.Errorf("%w: %d indices for %d dims", ErrIndexOutOfRange, len(indices), len(df.Dims))
 	}
 	for i := 0; i < len(df.Dims); i++ {
-		if indices[i] >= df.Dims[i] {
-			panic(fmt.Sprintf("index %d is too big for dimension %d which is %d", indices[i], i, df.Dims[i]))
+		if indices[i] < 0 || indices[i] >= df.Dims[i] {
+			return 0, fmt.Errorf("%w: index %d is out of range for dimension %d which is %d", ErrIndexOutOfRange,
+				indices[i], i, df.Dims[i])
 		}
 	}
 
@@ -49,7 +59,7 @@ func (df DataFrame[T]) Index(indices ...int) int {
 	for i := 1; i < len(df.Dims); i++ {
 		idx = df.Dims[i]*idx + indices[i]
 	}
-	return idx
+	return idx, nil
 }
 
 func (df DataFrame[T]) At(indices ...int) T       { return df.Data[df.Index(indices...)] }
@@ -63,17 +73,36 @@ func (df DataFrame[T]) Sub(index int) DataFrame[T] {
 	}
 }
 func (df DataFrame[T]) Slice(start, end int) DataFrame[T] {
+	sliced, err := df.SliceE(start, end)
+	if err != nil {
+		panic(err)
+	}
+	return sliced
+}
+
+// SliceE is like Slice, but returns an error instead of panicking when start and end do not fit df.
+func (df DataFrame[T]) SliceE(start, end int) (DataFrame[T], error) {
+	if len(df.Dims) == 0 {
+		return DataFrame[T]{}, fmt.Errorf("%w: cannot slice a dataframe with no dimensions", ErrIndexOutOfRange)
+	}
 	if start > end {
-		panic("start must be less than end")
+		return DataFrame[T]{}, fmt.Errorf("%w: start %d is greater than end %d", ErrIndexOutOfRange, start, end)
+	}
+	if start < 0 || end > df.Dims[0] {
+		return DataFrame[T]{}, fmt.Errorf("%w: slice [%d:%d] is out of range for dimension 0 which is %d",
+			ErrIndexOutOfRange, start, end, df.Dims[0])
 	}
 	ndims := make([]int, len(df.Dims))
 	copy(ndims, df.Dims)
 	ndims[0] = end - start
+	if df.Dims[0] == 0 {
+		return DataFrame[T]{Dims: ndims, Data: df.Data[:0]}, nil
+	}
 	subSize := df.TotalSize() / df.Dims[0]
 	return DataFrame[T]{
 		Dims: ndims,
 		Data: df.Data[subSize*start : subSize*end],
-	}
+	}, nil
 }
 
 func CastDf[T, U SizedNumber](df DataFrame[T]) DataFrame[U] {
@@ -86,3 +115,59 @@ func CastDf[T, U SizedNumber](df DataFrame[T]) DataFrame[U] {
 	}
 	return res
 }
+
+// AnyDataFrame is a DataFrame whose element type is only known at runtime. Dtype is the numpy name of the type
+// ("f4", "i8", ...) and Data is a slice of the matching Go type.
+type AnyDataFrame struct {
+	Dtype string
+	Dims  []int
+	Data  any
+}
+
+func ToAnyDf[T SizedNumber](df DataFrame[T]) AnyDataFrame {
+	return AnyDataFrame{
+		Dtype: sizedNumberToDtype[T](),
+		Dims:  df.Dims,
+		Data:  df.Data,
+	}
+}
+
+func (df AnyDataFrame) DimCount() int { return len(df.Dims) }
+func (df AnyDataFrame) Dim(i int) int { return df.Dims[i] }
+
+// As returns the DataFrame stored in df without copying it, if its type is T.
+func As[T SizedNumber](df AnyDataFrame) (DataFrame[T], error) {
+	data, ok := df.Data.([]T)
+	if !ok {
+		return DataFrame[T]{}, ErrDifferentDataType
+	}
+	return DataFrame[T]{Dims: df.Dims, Data: data}, nil
+}
+
+// CastAnyDf converts df to a DataFrame[U], like CastDf.
+func CastAnyDf[U SizedNumber](df AnyDataFrame) DataFrame[U] {
+	switch data := df.Data.(type) {
+	case []int8:
+		return CastDf[int8, U](DataFrame[int8]{Dims: df.Dims, Data: data})
+	case []int16:
+		return CastDf[int16, U](DataFrame[int16]{Dims: df.Dims, Data: data})
+	case []int32:
+		return CastDf[int32, U](DataFrame[int32]{Dims: df.Dims, Data: data})
+	case []int64:
+		return CastDf[int64, U](DataFrame[int64]{Dims: df.Dims, Data: data})
+	case []uint8:
+		return CastDf[uint8, U](DataFrame[uint8]{Dims: df.Dims, Data: data})
+	case []uint16:
+		return CastDf[uint16, U](DataFrame[uint16]{Dims: df.Dims, Data: data})
+	case []uint32:
+		return CastDf[uint32, U](DataFrame[uint32]{Dims: df.Dims, Data: data})
+	case []uint64:
+		return CastDf[uint64, U](DataFrame[uint64]{Dims: df.Dims, Data: data})
+	case []float32:
+		return CastDf[float32, U](DataFrame[float32]{Dims: df.Dims, Data: data})
+	case []float64:
+		return CastDf[float64, U](DataFrame[float64]{Dims: df.Dims, Data: data})
+	default:
+		panic("unknown number type")
+	}
+}
@@ -0,0 +1,35 @@
+package elefas_test
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/YohayAiTe/elefas"
+)
+
+func TestDataFrameIndexE(t *testing.T) {
+	df := elefas.MakeDataFrame[float32]([]int{2, 3})
+	if i, err := df.IndexE(1, 2); err != nil || i != 5 {
+		t.Errorf("IndexE(1, 2) returned %d, %v, expected 5", i, err)
+	}
+	for _, indices := range [][]int{{1}, {1, 2, 0}, {2, 0}, {0, 3}, {-1, 0}} {
+		if _, err := df.IndexE(indices...); !errors.Is(err, elefas.ErrIndexOutOfRange) {
+			t.Errorf("IndexE(%v) returned %v, expected %v", indices, err, elefas.ErrIndexOutOfRange)
+		}
+	}
+}
+
+func TestDataFrameSliceE(t *testing.T) {
+	df := elefas.DataFrame[float32]{Dims: []int{3, 2}, Data: []float32{0, 1, 2, 3, 4, 5}}
+	sliced, err := df.SliceE(1, 3)
+	expected := elefas.DataFrame[float32]{Dims: []int{2, 2}, Data: []float32{2, 3, 4, 5}}
+	if err != nil || !reflect.DeepEqual(sliced, expected) {
+		t.Errorf("SliceE(1, 3) returned %v, %v, expected %v", sliced, err, expected)
+	}
+	for _, bounds := range [][2]int{{2, 1}, {-1, 1}, {0, 4}} {
+		if _, err := df.SliceE(bounds[0], bounds[1]); !errors.Is(err, elefas.ErrIndexOutOfRange) {
+			t.Errorf("SliceE(%d, %d) returned %v, expected %v", bounds[0], bounds[1], err, elefas.ErrIndexOutOfRange)
+		}
+	}
+}
@@ -2,9 +2,49 @@ package elefas
 
 import (
 	"errors"
+	"fmt"
 )
 
 var (
 	ErrUnsupportedType   = errors.New("unsupported type")
 	ErrDifferentDataType = errors.New("different data type")
+	ErrUnsupportedLayer  = errors.New("unsupported layer")
+	ErrUnsupportedModel  = errors.New("unsupported model")
+	ErrInvalidModel      = errors.New("invalid model")
+	ErrInvalidKerasModel = errors.New("invalid keras model")
+	ErrInvalidNumpyFile  = errors.New("invalid numpy file")
+	ErrArrayNotFound     = errors.New("array not found")
+	ErrInvalidInput      = errors.New("invalid input")
+	ErrShapeMismatch     = errors.New("shape mismatch")
+	ErrIndexOutOfRange   = errors.New("index out of range")
 )
+
+// ShapeError reports an input of a shape a layer cannot take. In Expected, -1 stands for a dimension of any size;
+// Expected is nil when there is no single shape to expect, such as when two inputs do not match each other.
+type ShapeError struct {
+	Expected, Actual []int
+	Reason           string
+}
+
+func (e *ShapeError) Error() string {
+	if e.Expected == nil {
+		return fmt.Sprintf("%v: %s, got shape %v", ErrShapeMismatch, e.Reason, e.Actual)
+	}
+	return fmt.Sprintf("%v: %s, expected shape %v, got %v", ErrShapeMismatch, e.Reason, e.Expected, e.Actual)
+}
+
+func (e *ShapeError) Unwrap() error { return ErrShapeMismatch }
+
+// LayerError reports the failure of a layer while a model runs. Name is the name of the layer's node, and Index is
+// its index, in the order the layers were added to the model.
+type LayerError struct {
+	Index int
+	Name  string
+	Err   error
+}
+
+func (e *LayerError) Error() string {
+	return fmt.Sprintf("layer %s (node %d): %v", e.Name, e.Index, e.Err)
+}
+
+func (e *LayerError) Unwrap() error { return e.Err }
@@ -0,0 +1,84 @@
+package elefas
+
+import (
+	"io"
+
+	"github.com/YohayAiTe/elefas/internal/hdf5"
+)
+
+// LoadHDF5DataFrames loads every numeric dataset of an HDF5 file, such as the weight files written by Keras, keyed
+// by its full path (e.g. "layers/dense/vars/0"). The datasets are cast to T, and string datasets are skipped.
+func LoadHDF5DataFrames[T SizedNumber](r io.ReaderAt) (map[string]DataFrame[T], error) {
+	anyDfs, err := LoadHDF5AnyDataFrames(r)
+	if err != nil {
+		return nil, err
+	}
+	dfs := make(map[string]DataFrame[T], len(anyDfs))
+	for path, df := range anyDfs {
+		dfs[path] = CastAnyDf[T](df)
+	}
+	return dfs, nil
+}
+
+// LoadHDF5AnyDataFrames is like LoadHDF5DataFrames, but keeps the datasets' own types.
+func LoadHDF5AnyDataFrames(r io.ReaderAt) (map[string]AnyDataFrame, error) {
+	f, err := hdf5.Open(r)
+	if err != nil {
+		return nil, err
+	}
+	dfs := map[string]AnyDataFrame{}
+	err = f.Walk(func(path string, ds *hdf5.Dataset) error {
+		if ds.Type.IsString() {
+			return nil
+		}
+		df, err := loadHDF5AnyDataFrame(ds)
+		if err != nil {
+			return err
+		}
+		dfs[path] = df
+		return nil
+	})
+	return dfs, err
+}
+
+// LoadHDF5Attributes loads the attributes of the group or dataset at path. Each value is a slice of the Go type
+// matching the attribute's type, or a []string for strings.
+func LoadHDF5Attributes(r io.ReaderAt, path string) (map[string]any, error) {
+	f, err := hdf5.Open(r)
+	if err != nil {
+		return nil, err
+	}
+	var attrs []hdf5.Attribute
+	if g, err := f.OpenGroup(path); err == nil {
+		attrs, err = g.Attributes()
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		ds, err := f.OpenDataset(path)
+		if err != nil {
+			return nil, err
+		}
+		if attrs, err = ds.Attributes(); err != nil {
+			return nil, err
+		}
+	}
+
+	values := make(map[string]any, len(attrs))
+	for _, attr := range attrs {
+		values[attr.Name] = attr.Value
+	}
+	return values, nil
+}
+
+func loadHDF5AnyDataFrame(ds *hdf5.Dataset) (AnyDataFrame, error) {
+	shape := ds.Shape
+	if len(shape) == 0 {
+		shape = []int{1}
+	}
+	data, err := ds.Read()
+	if err != nil {
+		return AnyDataFrame{}, err
+	}
+	return AnyDataFrame{Dtype: ds.Type.Dtype(), Dims: shape, Data: data}, nil
+}
@@ -0,0 +1,160 @@
+package elefas_test
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"os"
+	"reflect"
+	"testing"
+
+	"github.com/YohayAiTe/elefas"
+	"github.com/YohayAiTe/elefas/internal/hdf5"
+)
+
+// The files in testdata/hdf5 are written by testdata/make_hdf5.py. v0.h5 has a version 0 superblock, version 1
+// object headers and symbol table groups, like the files h5py writes by default, and v2.h5 and v3.h5 have version 2
+// and 3 superblocks, version 2 object headers and groups made of link messages, like with libver="latest". The root
+// groups continue into a second object header block, which holds some of their links and attributes.
+
+func hdf5Datasets(version string) map[string]elefas.AnyDataFrame {
+	chunked := make([]float64, 5*7)
+	for i := range chunked {
+		chunked[i] = float64(i) / 2
+	}
+	dfs := map[string]elefas.AnyDataFrame{
+		"layers/dense/vars/0": elefas.ToAnyDf(elefas.DataFrame[float32]{Dims: []int{2, 3},
+			Data: []float32{1, 2, 3, 4, 5, 6}}),
+		"layers/dense/vars/1": elefas.ToAnyDf(elefas.DataFrame[float32]{Dims: []int{3},
+			Data: []float32{0.5, -0.25, 0.125}}),
+		// compact layout
+		"compact": elefas.ToAnyDf(elefas.DataFrame[int32]{Dims: []int{2, 2}, Data: []int32{-1, 2, -3, 4}}),
+		// chunks of 2x3 indexed by a B-tree, with the shuffle, deflate and fletcher32 filters
+		"chunked": elefas.ToAnyDf(elefas.DataFrame[float64]{Dims: []int{5, 7}, Data: chunked}),
+	}
+	if version == "v0" {
+		dfs["big_endian"] = elefas.ToAnyDf(elefas.DataFrame[int16]{Dims: []int{3}, Data: []int16{1, -2, 300}})
+	} else {
+		// a single chunk with the deflate and fletcher32 filters, and chunks of 2 with implicit indexing
+		dfs["single"] = elefas.ToAnyDf(elefas.DataFrame[float32]{Dims: []int{3, 4},
+			Data: []float32{-5, -4, -3, -2, -1, 0, 1, 2, 3, 4, 5, 6}})
+		dfs["implicit"] = elefas.ToAnyDf(elefas.DataFrame[uint8]{Dims: []int{5}, Data: []uint8{1, 2, 3, 4, 5}})
+	}
+	return dfs
+}
+
+func TestLoadHDF5AnyDataFrames(t *testing.T) {
+	for _, version := range []string{"v0", "v2", "v3"} {
+		t.Run(version, func(t *testing.T) {
+			f, err := os.Open("testdata/hdf5/" + version + ".h5")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer f.Close()
+			// string datasets, such as "strings" in v0.h5, are skipped
+			dfs, err := elefas.LoadHDF5AnyDataFrames(f)
+			if err != nil {
+				t.Fatalf("error loading: %v", err)
+			}
+			if expected := hdf5Datasets(version); !reflect.DeepEqual(dfs, expected) {
+				t.Errorf("loaded %v, expected %v", dfs, expected)
+			}
+		})
+	}
+}
+
+func TestLoadHDF5DataFrames(t *testing.T) {
+	data, err := os.ReadFile("testdata/hdf5/v2.h5")
+	if err != nil {
+		t.Fatal(err)
+	}
+	dfs, err := elefas.LoadHDF5DataFrames[float64](bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("error loading: %v", err)
+	}
+	for path, df := range hdf5Datasets("v2") {
+		if expected := elefas.CastAnyDf[float64](df); !reflect.DeepEqual(dfs[path], expected) {
+			t.Errorf("%s is %v, expected %v", path, dfs[path], expected)
+		}
+	}
+}
+
+func TestLoadHDF5Attributes(t *testing.T) {
+	for _, version := range []string{"v0", "v2", "v3"} {
+		t.Run(version, func(t *testing.T) {
+			data, err := os.ReadFile("testdata/hdf5/" + version + ".h5")
+			if err != nil {
+				t.Fatal(err)
+			}
+			configVersion := version
+			if version == "v3" {
+				configVersion = "v2"
+			}
+			for _, test := range []struct {
+				path     string
+				expected map[string]any
+			}{
+				// a scalar, a fixed-length string and variable-length strings, the last two in the continuation
+				{"/", map[string]any{
+					"version":      []int32{3},
+					"model_config": []string{`{"name": "` + configVersion + `"}`},
+					"names":        []string{"dense", "dense_1"},
+				}},
+				// a fixed-length string padded with spaces, on a dataset
+				{"compact", map[string]any{"unit": []string{"m"}}},
+				{"layers/dense", map[string]any{}},
+			} {
+				attrs, err := elefas.LoadHDF5Attributes(bytes.NewReader(data), test.path)
+				if err != nil {
+					t.Errorf("error loading the attributes of %s: %v", test.path, err)
+				} else if !reflect.DeepEqual(attrs, test.expected) {
+					t.Errorf("attributes of %s are %v, expected %v", test.path, attrs, test.expected)
+				}
+			}
+
+			if _, err := elefas.LoadHDF5Attributes(bytes.NewReader(data), "layers/conv"); !errors.Is(err,
+				hdf5.ErrNotFound) {
+				t.Errorf("loading the attributes of a missing group returned %v, expected %v", err, hdf5.ErrNotFound)
+			}
+		})
+	}
+}
+
+func TestLoadHDF5Corrupted(t *testing.T) {
+	for _, test := range []struct {
+		file     string
+		expected error
+	}{
+		// the root group's object header continues into itself
+		{"loop.h5", hdf5.ErrCorrupted},
+		// a dataset is larger than the file
+		{"huge.h5", hdf5.ErrCorrupted},
+		// a chunked dataset, whose chunks were never allocated, is too large to read
+		{"huge_chunked.h5", hdf5.ErrUnsupported},
+		// the chunks of a dataset have a size of 0
+		{"zero_chunk.h5", hdf5.ErrCorrupted},
+		// a chunk decompresses to much more than its size
+		{"bomb.h5", hdf5.ErrCorrupted},
+	} {
+		data, err := os.ReadFile("testdata/hdf5/" + test.file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := elefas.LoadHDF5AnyDataFrames(bytes.NewReader(data)); !errors.Is(err, test.expected) {
+			t.Errorf("loading %s returned %v, expected %v", test.file, err, test.expected)
+		}
+	}
+
+	data, err := os.ReadFile("testdata/hdf5/v0.h5")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for size := 0; size < len(data); size += 61 {
+		// the reader hides its Size method, so that the size of the file has to be searched for
+		_, err := elefas.LoadHDF5AnyDataFrames(struct{ io.ReaderAt }{bytes.NewReader(data[:size])})
+		if !errors.Is(err, hdf5.ErrNotHDF5) && !errors.Is(err, hdf5.ErrTruncated) {
+			t.Errorf("loading the first %d bytes of v0.h5 returned %v, expected %v or %v", size, err, hdf5.ErrNotHDF5,
+				hdf5.ErrTruncated)
+		}
+	}
+}
@@ -0,0 +1,124 @@
+package elefas
+
+import (
+	"fmt"
+	"io"
+	"runtime/metrics"
+	"sort"
+	"sync"
+	"text/tabwriter"
+	"time"
+)
+
+// LayerEvent describes a layer a model runs, for a Hook. OutputShape, Elapsed, AllocatedBytes and Err are only set
+// after the layer ran.
+type LayerEvent[T SizedNumber] struct {
+	Node        *LayerData[T]
+	InputShapes [][]int
+	OutputShape []int
+	Elapsed     time.Duration
+
+	// AllocatedBytes is roughly how many bytes were allocated on the heap while the layer ran. It counts the
+	// allocations of the whole program, so it includes those of other layers running at the same time with
+	// WithParallelism, and of other goroutines.
+	AllocatedBytes uint64
+
+	// Err is the error the layer returned, if it implements LayerE or MultiInputLayerE
+	Err error
+}
+
+// Hook is called before and after each layer a model runs. Hooks are called from the goroutine that runs the
+// layer, so with WithParallelism or concurrent calls to Predict, they may be called from several goroutines at once.
+type Hook[T SizedNumber] interface {
+	BeforeLayer(event LayerEvent[T])
+	AfterLayer(event LayerEvent[T])
+}
+
+// AddHook makes the model call hook around each layer it runs from now on.
+func (m *Model[T]) AddHook(hook Hook[T]) {
+	m.planMu.Lock()
+	defer m.planMu.Unlock()
+	m.hooks = append(m.hooks[:len(m.hooks):len(m.hooks)], hook)
+}
+
+func heapAllocatedBytes() uint64 {
+	sample := []metrics.Sample{{Name: "/gc/heap/allocs:bytes"}}
+	metrics.Read(sample)
+	return sample[0].Value.Uint64()
+}
+
+// LayerProfile is the time a layer took and the memory it allocated, summed over the times it ran.
+type LayerProfile struct {
+	Name, Type     string
+	Calls          int
+	Time           time.Duration
+	AllocatedBytes uint64
+}
+
+// Profiler is a Hook that sums up the time each layer takes and the memory it allocates over many calls to Predict.
+type Profiler[T SizedNumber] struct {
+	mu       sync.Mutex
+	profiles map[*LayerData[T]]*LayerProfile
+}
+
+func NewProfiler[T SizedNumber]() *Profiler[T] {
+	return &Profiler[T]{profiles: map[*LayerData[T]]*LayerProfile{}}
+}
+
+func (p *Profiler[T]) BeforeLayer(LayerEvent[T]) {}
+
+func (p *Profiler[T]) AfterLayer(event LayerEvent[T]) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	profile, ok := p.profiles[event.Node]
+	if !ok {
+		profile = &LayerProfile{Name: event.Node.name, Type: event.Node.typeName()}
+		p.profiles[event.Node] = profile
+	}
+	profile.Calls++
+	profile.Time += event.Elapsed
+	profile.AllocatedBytes += event.AllocatedBytes
+}
+
+// Profiles returns the profiles of the layers that ran, the slowest first.
+func (p *Profiler[T]) Profiles() []LayerProfile {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	profiles := make([]LayerProfile, 0, len(p.profiles))
+	for _, profile := range p.profiles {
+		profiles = append(profiles, *profile)
+	}
+	sort.Slice(profiles, func(i, j int) bool {
+		if profiles[i].Time != profiles[j].Time {
+			return profiles[i].Time > profiles[j].Time
+		}
+		return profiles[i].Name < profiles[j].Name
+	})
+	return profiles
+}
+
+func (p *Profiler[T]) Reset() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.profiles = map[*LayerData[T]]*LayerProfile{}
+}
+
+// Report writes a table of the profiles to w, the slowest layer first, with the share of the total time each took.
+func (p *Profiler[T]) Report(w io.Writer) {
+	profiles := p.Profiles()
+	var total time.Duration
+	for _, profile := range profiles {
+		total += profile.Time
+	}
+	tw := tabwriter.NewWriter(w, 0, 0, 3, ' ', 0)
+	fmt.Fprintln(tw, "Layer (type)\tCalls\tTotal\tMean\tShare\tAllocated")
+	for _, profile := range profiles {
+		share := 0.
+		if total > 0 {
+			share = 100 * float64(profile.Time) / float64(total)
+		}
+		fmt.Fprintf(tw, "%s (%s)\t%d\t%v\t%v\t%.1f%%\t%s\n", profile.Name, profile.Type, profile.Calls,
+			profile.Time, profile.Time/time.Duration(profile.Calls), share, formatBytes(int(profile.AllocatedBytes)))
+	}
+	tw.Flush()
+}
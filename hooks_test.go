@@ -0,0 +1,100 @@
+package elefas_test
+
+import (
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/YohayAiTe/elefas"
+)
+
+// sleepLayer sleeps, and allocates an output large enough for the runtime to count the allocation right away
+type sleepLayer struct{ duration time.Duration }
+
+func (l sleepLayer) Apply(input elefas.DataFrame[float32]) elefas.DataFrame[float32] {
+	time.Sleep(l.duration)
+	return elefas.MakeDataFrame[float32]([]int{input.Dim(0), 10000})
+}
+
+type recordingHook struct {
+	mu     sync.Mutex
+	events []string
+	after  []elefas.LayerEvent[float32]
+}
+
+func (h *recordingHook) BeforeLayer(event elefas.LayerEvent[float32]) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.events = append(h.events, "before "+event.Node.Name())
+}
+
+func (h *recordingHook) AfterLayer(event elefas.LayerEvent[float32]) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.events = append(h.events, "after "+event.Node.Name())
+	h.after = append(h.after, event)
+}
+
+func TestHooks(t *testing.T) {
+	model := elefas.NewModel[float32](1, 1)
+	slow := model.Input(0).AddLayer(sleepLayer{10 * time.Millisecond}).Named("slow")
+	model.SetOutput(slow.AddLayer(scaleLayer{2}).Named("scale"), 0)
+	hook := &recordingHook{}
+	model.AddHook(hook)
+
+	model.Predict(elefas.MakeDataFrame[float32]([]int{2, 3}))
+	expected := []string{"before slow", "after slow", "before scale", "after scale"}
+	if !reflect.DeepEqual(hook.events, expected) {
+		t.Errorf("hook was called for %v, expected %v", hook.events, expected)
+	}
+	event := hook.after[0]
+	if !reflect.DeepEqual(event.InputShapes, [][]int{{2, 3}}) ||
+		!reflect.DeepEqual(event.OutputShape, []int{2, 10000}) {
+		t.Errorf("event has input shapes %v and output shape %v", event.InputShapes, event.OutputShape)
+	}
+	if event.Elapsed < 10*time.Millisecond {
+		t.Errorf("slow layer took %v", event.Elapsed)
+	}
+	if event.AllocatedBytes < 2*10000*4 {
+		t.Errorf("slow layer allocated %d bytes", event.AllocatedBytes)
+	}
+}
+
+func TestProfiler(t *testing.T) {
+	model := elefas.NewModel[float32](1, 1, elefas.WithParallelism(2))
+	slow := model.Input(0).AddLayer(sleepLayer{5 * time.Millisecond}).Named("slow")
+	fast := model.Input(0).AddLayer(scaleLayer{2}).Named("fast")
+	model.SetOutput(model.AddMultiInputLayer(sumLayer{}, fast, slow.AddLayer(scaleLayer{1})), 0)
+	profiler := elefas.NewProfiler[float32]()
+	model.AddHook(profiler)
+
+	for i := 0; i < 3; i++ {
+		model.Predict(elefas.MakeDataFrame[float32]([]int{1, 10000}))
+	}
+	profiles := profiler.Profiles()
+	if len(profiles) != 4 {
+		t.Fatalf("profiled %d layers, expected 4", len(profiles))
+	}
+	if profiles[0].Name != "slow" || profiles[0].Type != "sleepLayer" {
+		t.Errorf("slowest layer is %s (%s), expected slow (sleepLayer)", profiles[0].Name, profiles[0].Type)
+	}
+	for _, profile := range profiles {
+		if profile.Calls != 3 {
+			t.Errorf("%s ran %d times, expected 3", profile.Name, profile.Calls)
+		}
+	}
+
+	var b strings.Builder
+	profiler.Report(&b)
+	lines := strings.Split(strings.TrimSpace(b.String()), "\n")
+	if len(lines) != 5 || !strings.HasPrefix(lines[0], "Layer (type)") || !strings.HasPrefix(lines[1], "slow ") {
+		t.Errorf("report is\n%s", b.String())
+	}
+
+	profiler.Reset()
+	if profiles := profiler.Profiles(); len(profiles) != 0 {
+		t.Errorf("Reset left %v", profiles)
+	}
+}
@@ -0,0 +1,86 @@
+package hdf5
+
+import (
+	"bytes"
+	"fmt"
+)
+
+type Attribute struct {
+	Name  string
+	Shape []int
+	Type  Datatype
+	// Value is a slice of the Go type matching Type.Dtype(), or a []string for string attributes
+	Value any
+}
+
+func (f *File) attributes(oh *objectHeader) ([]Attribute, error) {
+	var attrs []Attribute
+	for _, data := range oh.findAll(msgAttribute) {
+		attr, err := f.parseAttribute(data)
+		if err != nil {
+			return nil, err
+		}
+		attrs = append(attrs, attr)
+	}
+	return attrs, nil
+}
+
+func (f *File) parseAttribute(data []byte) (Attribute, error) {
+	d := f.decoder(data)
+	version := d.u8()
+	if version < 1 || version > 3 {
+		return Attribute{}, fmt.Errorf("%w: attribute message version %d", ErrUnsupported, version)
+	}
+	flags := d.u8()
+	nameSize, typeSize, spaceSize := int(d.u16()), int(d.u16()), int(d.u16())
+	if version == 3 {
+		d.skip(1) // name character set
+	}
+	if flags&0x03 != 0 {
+		return Attribute{}, fmt.Errorf("%w: shared attribute datatype or dataspace", ErrUnsupported)
+	}
+	// version 1 pads each of the fields to a multiple of 8 bytes
+	padded := func(size int) int {
+		if version == 1 {
+			return (size + 7) / 8 * 8
+		}
+		return size
+	}
+	name := d.bytes(padded(nameSize))
+	typeData := d.bytes(padded(typeSize))
+	spaceData := d.bytes(padded(spaceSize))
+	if d.err != nil {
+		return Attribute{}, d.err
+	}
+	if end := bytes.IndexByte(name, 0); end >= 0 {
+		name = name[:end]
+	}
+
+	attr := Attribute{Name: string(name)}
+	td := f.decoder(typeData)
+	attr.Type = parseDatatype(td)
+	if td.err != nil {
+		return Attribute{}, td.err
+	}
+	sd := f.decoder(spaceData)
+	attr.Shape = parseDataspace(sd)
+	if sd.err != nil {
+		return Attribute{}, sd.err
+	}
+
+	count := elementCount(attr.Shape)
+	raw := d.bytes(count * attr.Type.Size)
+	if d.err != nil {
+		return Attribute{}, d.err
+	}
+	var err error
+	if attr.Type.IsString() {
+		attr.Value, err = f.decodeStrings(attr.Type, raw, count)
+	} else {
+		attr.Value, err = decodeNumeric(attr.Type, raw, count)
+	}
+	if err != nil {
+		return Attribute{}, fmt.Errorf("attribute %s: %w", attr.Name, err)
+	}
+	return attr, nil
+}
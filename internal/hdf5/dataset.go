@@ -0,0 +1,507 @@
+package hdf5
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"math"
+	"strings"
+)
+
+const (
+	classFixedPoint     = 0
+	classFloatingPoint  = 1
+	classString         = 3
+	classVariableLength = 9
+)
+
+type Datatype struct {
+	Class     int
+	Size      int
+	Signed    bool
+	BigEndian bool
+
+	// variableString is set for variable-length strings, which are stored in the global heap
+	variableString bool
+	// spacePadded is set for fixed-length strings padded with spaces instead of nulls
+	spacePadded bool
+}
+
+// Dtype returns the numpy style name of the type ("f4", "i8", ...), or an empty string if it has no numeric
+// equivalent.
+func (t Datatype) Dtype() string {
+	switch {
+	case t.Class == classFloatingPoint && (t.Size == 4 || t.Size == 8):
+		return "f" + fmt.Sprint(t.Size)
+	case t.Class == classFixedPoint && (t.Size == 1 || t.Size == 2 || t.Size == 4 || t.Size == 8):
+		if t.Signed {
+			return "i" + fmt.Sprint(t.Size)
+		}
+		return "u" + fmt.Sprint(t.Size)
+	default:
+		return ""
+	}
+}
+
+func (t Datatype) IsString() bool {
+	return t.Class == classString || t.variableString
+}
+
+func (t Datatype) byteOrder() binary.ByteOrder {
+	if t.BigEndian {
+		return binary.BigEndian
+	}
+	return binary.LittleEndian
+}
+
+func parseDatatype(d *decoder) Datatype {
+	classAndVersion := d.u8()
+	bits := d.bytes(3)
+	t := Datatype{
+		Class: int(classAndVersion & 0x0f),
+		Size:  int(d.u32()),
+	}
+	if d.err != nil {
+		return t
+	}
+	switch t.Class {
+	case classFixedPoint:
+		t.BigEndian = bits[0]&0x01 != 0
+		t.Signed = bits[0]&0x08 != 0
+	case classFloatingPoint:
+		t.BigEndian = bits[0]&0x01 != 0
+	case classString:
+		t.spacePadded = bits[0]&0x0f == 2
+	case classVariableLength:
+		t.variableString = bits[0]&0x0f == 1
+	}
+	return t
+}
+
+// parseDataspace returns the dimensions of a dataspace, which are empty for a scalar and {0} for a null dataspace.
+func parseDataspace(d *decoder) []int {
+	version := d.u8()
+	rank := int(d.u8())
+	d.skip(1) // flags, the maximum dimensions that may follow the current ones are not needed for reading
+	switch version {
+	case 1:
+		d.skip(1 + 4) // reserved
+	case 2:
+		if typ := d.u8(); typ == 2 {
+			return []int{0}
+		}
+	default:
+		if d.err == nil {
+			d.err = fmt.Errorf("%w: dataspace version %d", ErrUnsupported, version)
+		}
+		return nil
+	}
+	shape := make([]int, rank)
+	count := 1
+	for i := range shape {
+		shape[i] = int(d.length())
+		// the dimensions are used for allocations, so the number of elements must fit in an int
+		if shape[i] < 0 || shape[i] > 0 && count > math.MaxInt/shape[i] {
+			if d.err == nil {
+				d.err = ErrCorrupted
+			}
+			return nil
+		}
+		count *= shape[i]
+	}
+	return shape
+}
+
+func elementCount(shape []int) int {
+	count := 1
+	for _, dim := range shape {
+		count *= dim
+	}
+	return count
+}
+
+// decodeNumeric decodes count elements of a numeric type into a slice of the matching Go type.
+func decodeNumeric(t Datatype, raw []byte, count int) (any, error) {
+	var data any
+	switch t.Dtype() {
+	case "i1":
+		data = make([]int8, count)
+	case "i2":
+		data = make([]int16, count)
+	case "i4":
+		data = make([]int32, count)
+	case "i8":
+		data = make([]int64, count)
+	case "u1":
+		data = make([]uint8, count)
+	case "u2":
+		data = make([]uint16, count)
+	case "u4":
+		data = make([]uint32, count)
+	case "u8":
+		data = make([]uint64, count)
+	case "f4":
+		data = make([]float32, count)
+	case "f8":
+		data = make([]float64, count)
+	default:
+		return nil, fmt.Errorf("%w: datatype class %d of size %d", ErrUnsupported, t.Class, t.Size)
+	}
+	if err := binary.Read(bytes.NewReader(raw), t.byteOrder(), data); err != nil {
+		return nil, ErrTruncated
+	}
+	return data, nil
+}
+
+// decodeStrings decodes count fixed or variable-length strings.
+func (f *File) decodeStrings(t Datatype, raw []byte, count int) ([]string, error) {
+	if len(raw) < count*t.Size {
+		return nil, ErrTruncated
+	}
+	strs := make([]string, count)
+	for i := range strs {
+		elem := raw[i*t.Size : (i+1)*t.Size]
+		if t.variableString {
+			d := f.decoder(elem)
+			length := d.u32()
+			addr, index := d.offset(), d.u32()
+			if d.err != nil {
+				return nil, d.err
+			}
+			if length == 0 {
+				continue
+			}
+			var err error
+			if elem, err = f.globalHeapObject(addr, index); err != nil {
+				return nil, err
+			}
+		}
+		if end := bytes.IndexByte(elem, 0); end >= 0 {
+			elem = elem[:end]
+		}
+		strs[i] = string(elem)
+		if t.spacePadded {
+			strs[i] = strings.TrimRight(strs[i], " ")
+		}
+	}
+	return strs, nil
+}
+
+const (
+	layoutCompact    = 0
+	layoutContiguous = 1
+	layoutChunked    = 2
+)
+
+const (
+	chunkIndexBTree    = 0 // the version 1 B-tree of version 3 layouts
+	chunkIndexSingle   = 1
+	chunkIndexImplicit = 2
+)
+
+type Dataset struct {
+	Shape []int
+	Type  Datatype
+
+	file   *File
+	header *objectHeader
+
+	layoutClass int
+	address     uint64
+	size        uint64
+	compactData []byte
+
+	chunkShape      []int
+	chunkIndex      int
+	chunkFlags      uint8
+	singleChunkSize uint64
+	singleChunkMask uint32
+	filters         []filter
+}
+
+func (f *File) dataset(oh *objectHeader) (*Dataset, error) {
+	ds := &Dataset{file: f, header: oh}
+	for _, typ := range []uint16{msgDataspace, msgDatatype, msgLayout} {
+		if oh.find(typ) == nil {
+			return nil, fmt.Errorf("%w: not a dataset", ErrNotFound)
+		}
+	}
+
+	d := f.decoder(oh.find(msgDataspace))
+	ds.Shape = parseDataspace(d)
+	if d.err != nil {
+		return nil, d.err
+	}
+	d = f.decoder(oh.find(msgDatatype))
+	ds.Type = parseDatatype(d)
+	if d.err != nil {
+		return nil, d.err
+	}
+	if ds.Type.Size <= 0 || elementCount(ds.Shape) > math.MaxInt/ds.Type.Size {
+		return nil, ErrCorrupted
+	}
+	if err := ds.parseLayout(f.decoder(oh.find(msgLayout))); err != nil {
+		return nil, err
+	}
+	if data := oh.find(msgFilterPipeline); data != nil {
+		var err error
+		if ds.filters, err = parseFilterPipeline(f.decoder(data)); err != nil {
+			return nil, err
+		}
+	}
+	return ds, nil
+}
+
+func (ds *Dataset) parseLayout(d *decoder) error {
+	version := d.u8()
+	if version != 3 && version != 4 {
+		return fmt.Errorf("%w: data layout version %d", ErrUnsupported, version)
+	}
+	ds.layoutClass = int(d.u8())
+	switch ds.layoutClass {
+	case layoutCompact:
+		ds.compactData = d.bytes(int(d.u16()))
+	case layoutContiguous:
+		ds.address = d.offset()
+		ds.size = d.length()
+	case layoutChunked:
+		if version == 3 {
+			// the dimensionality includes an extra dimension for the element size
+			rank := int(d.u8()) - 1
+			ds.address = d.offset()
+			ds.chunkShape = make([]int, rank)
+			for i := range ds.chunkShape {
+				ds.chunkShape[i] = int(d.u32())
+			}
+			ds.chunkIndex = chunkIndexBTree
+			break
+		}
+
+		ds.chunkFlags = d.u8()
+		rank := int(d.u8()) - 1
+		encodedLength := int(d.u8())
+		ds.chunkShape = make([]int, rank)
+		for i := range ds.chunkShape {
+			ds.chunkShape[i] = int(d.uint(encodedLength))
+		}
+		d.skip(encodedLength) // element size
+		ds.chunkIndex = int(d.u8())
+		switch ds.chunkIndex {
+		case chunkIndexSingle:
+			if ds.chunkFlags&0x02 != 0 {
+				ds.singleChunkSize = d.length()
+				ds.singleChunkMask = d.u32()
+			}
+		case chunkIndexImplicit:
+		default:
+			if d.err == nil {
+				return fmt.Errorf("%w: chunk index type %d", ErrUnsupported, ds.chunkIndex)
+			}
+		}
+		ds.address = d.offset()
+	default:
+		return fmt.Errorf("%w: data layout class %d", ErrUnsupported, ds.layoutClass)
+	}
+	if d.err != nil || ds.layoutClass != layoutChunked {
+		return d.err
+	}
+	if len(ds.chunkShape) != len(ds.Shape) {
+		return ErrCorrupted
+	}
+	// chunk dimensions larger than an int were read as negative
+	count := 1
+	for _, dim := range ds.chunkShape {
+		if dim <= 0 || count > math.MaxInt/dim {
+			return fmt.Errorf("%w: chunk shape %v", ErrCorrupted, ds.chunkShape)
+		}
+		count *= dim
+	}
+	if count > math.MaxInt/ds.Type.Size {
+		return fmt.Errorf("%w: chunk shape %v", ErrCorrupted, ds.chunkShape)
+	}
+	return nil
+}
+
+// maxDatasetSize is the largest dataset, in bytes, that is not read straight from the file: a chunked dataset, whose
+// chunks may be compressed, or one whose storage was never allocated. Such datasets may be larger than the file, so
+// unlike the others their size is not bounded by it.
+const maxDatasetSize = 1 << 32
+
+func (ds *Dataset) rawData() ([]byte, error) {
+	size := elementCount(ds.Shape) * ds.Type.Size
+	if (ds.layoutClass == layoutChunked || ds.file.isUndefined(ds.address)) && size > maxDatasetSize {
+		return nil, fmt.Errorf("%w: dataset of %d bytes, larger than %d", ErrUnsupported, size, maxDatasetSize)
+	}
+	switch ds.layoutClass {
+	case layoutCompact:
+		if len(ds.compactData) < size {
+			return nil, ErrTruncated
+		}
+		return ds.compactData[:size], nil
+	case layoutContiguous:
+		if ds.file.isUndefined(ds.address) {
+			// storage was never allocated, so the dataset holds only fill values
+			return make([]byte, size), nil
+		}
+		if ds.size < uint64(size) {
+			return nil, ErrTruncated
+		}
+		return ds.file.readAt(ds.address, size)
+	default:
+		raw := make([]byte, size)
+		if ds.file.isUndefined(ds.address) {
+			return raw, nil
+		}
+		err := ds.forEachChunk(func(offsets []int, addr uint64, size uint64, filterMask uint32) error {
+			chunk, err := ds.file.readAt(addr, int(size))
+			if err != nil {
+				return err
+			}
+			if chunk, err = ds.unfilter(chunk, filterMask); err != nil {
+				return err
+			}
+			return ds.copyChunk(raw, chunk, offsets)
+		})
+		return raw, err
+	}
+}
+
+func (ds *Dataset) chunkBytes() int {
+	return elementCount(ds.chunkShape) * ds.Type.Size
+}
+
+// forEachChunk calls fn with the element offsets, address, stored size and filter mask of every allocated chunk.
+func (ds *Dataset) forEachChunk(fn func(offsets []int, addr uint64, size uint64, filterMask uint32) error) error {
+	switch ds.chunkIndex {
+	case chunkIndexBTree:
+		return ds.forEachBTreeChunk(ds.address, map[uint64]bool{}, fn)
+	case chunkIndexSingle:
+		size := ds.singleChunkSize
+		if ds.chunkFlags&0x02 == 0 {
+			size = uint64(ds.chunkBytes())
+		}
+		return fn(make([]int, len(ds.Shape)), ds.address, size, ds.singleChunkMask)
+	default:
+		// implicit indexing stores every chunk, unfiltered, one after the other in row-major order
+		chunkCounts := make([]int, len(ds.Shape))
+		for i := range chunkCounts {
+			chunkCounts[i] = (ds.Shape[i] + ds.chunkShape[i] - 1) / ds.chunkShape[i]
+		}
+		size := uint64(ds.chunkBytes())
+		offsets := make([]int, len(ds.Shape))
+		for i := 0; i < elementCount(chunkCounts); i++ {
+			rem := i
+			for dim := len(offsets) - 1; dim >= 0; dim-- {
+				offsets[dim] = rem % chunkCounts[dim] * ds.chunkShape[dim]
+				rem /= chunkCounts[dim]
+			}
+			if err := fn(offsets, ds.address+uint64(i)*size, size, 0); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+}
+
+// forEachBTreeChunk calls fn for the chunks under the B-tree node at addr. Like in readGroupBTree, visited holds the
+// nodes read so far.
+func (ds *Dataset) forEachBTreeChunk(addr uint64, visited map[uint64]bool,
+	fn func(offsets []int, addr uint64, size uint64, filterMask uint32) error) error {
+
+	if visited[addr] {
+		return fmt.Errorf("%w: chunk B-tree loops", ErrCorrupted)
+	}
+	visited[addr] = true
+	rank := len(ds.Shape)
+	level, keys, children, err := ds.file.readBTreeNode(addr, 1, 4+4+8*(rank+1))
+	if err != nil {
+		return err
+	}
+	for i, child := range children {
+		if level > 0 {
+			if err := ds.forEachBTreeChunk(child, visited, fn); err != nil {
+				return err
+			}
+			continue
+		}
+		d := ds.file.decoder(keys[i])
+		size := d.u32()
+		filterMask := d.u32()
+		offsets := make([]int, rank)
+		for j := range offsets {
+			offsets[j] = int(d.u64())
+		}
+		if d.err != nil {
+			return d.err
+		}
+		if err := fn(offsets, child, uint64(size), filterMask); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// copyChunk copies the part of a decoded chunk that lies inside the dataset into raw, the row-major data of the
+// whole dataset.
+func (ds *Dataset) copyChunk(raw, chunk []byte, offsets []int) error {
+	if len(chunk) < ds.chunkBytes() {
+		return ErrTruncated
+	}
+	for dim, offset := range offsets {
+		if offset < 0 {
+			return fmt.Errorf("%w: chunk offsets %v", ErrCorrupted, offsets)
+		}
+		if offset >= ds.Shape[dim] {
+			// the chunk lies outside the dataset, which was shrunk after it was written
+			return nil
+		}
+	}
+	last := len(ds.Shape) - 1
+	rowLength := ds.Shape[last] - offsets[last]
+	if rowLength > ds.chunkShape[last] {
+		rowLength = ds.chunkShape[last]
+	}
+	if rowLength <= 0 {
+		return nil
+	}
+
+	// iterate over the rows of the chunk, i.e. over every index but the last
+	index := make([]int, last)
+	for row := 0; row < elementCount(ds.chunkShape[:last]); row++ {
+		rem := row
+		inside := true
+		for dim := last - 1; dim >= 0; dim-- {
+			index[dim] = rem % ds.chunkShape[dim]
+			rem /= ds.chunkShape[dim]
+			inside = inside && offsets[dim]+index[dim] < ds.Shape[dim]
+		}
+		if !inside {
+			continue
+		}
+		dst := 0
+		for dim := 0; dim < last; dim++ {
+			dst = dst*ds.Shape[dim] + offsets[dim] + index[dim]
+		}
+		dst = dst*ds.Shape[last] + offsets[last]
+		src := row * ds.chunkShape[last]
+		copy(raw[dst*ds.Type.Size:(dst+rowLength)*ds.Type.Size], chunk[src*ds.Type.Size:])
+	}
+	return nil
+}
+
+// Read returns the dataset's elements in row-major order, as a slice of the Go type matching Type.Dtype(), or as
+// a []string for string datasets.
+func (ds *Dataset) Read() (any, error) {
+	raw, err := ds.rawData()
+	if err != nil {
+		return nil, err
+	}
+	if ds.Type.IsString() {
+		return ds.file.decodeStrings(ds.Type, raw, elementCount(ds.Shape))
+	}
+	return decodeNumeric(ds.Type, raw, elementCount(ds.Shape))
+}
+
+func (ds *Dataset) Attributes() ([]Attribute, error) {
+	return ds.file.attributes(ds.header)
+}
@@ -0,0 +1,88 @@
+package hdf5
+
+import (
+	"encoding/binary"
+)
+
+// decoder reads little-endian fields out of a byte slice. Reading past the end of the slice sets err and returns
+// zero values, so callers only need to check err once after decoding a structure.
+type decoder struct {
+	b   []byte
+	pos int
+	err error
+
+	offsetSize, lengthSize int
+}
+
+func (f *File) decoder(b []byte) *decoder {
+	return &decoder{b: b, offsetSize: f.offsetSize, lengthSize: f.lengthSize}
+}
+
+func (d *decoder) next(n int) []byte {
+	if d.err != nil {
+		return nil
+	}
+	if n < 0 || d.pos+n > len(d.b) {
+		d.err = ErrTruncated
+		return nil
+	}
+	b := d.b[d.pos : d.pos+n]
+	d.pos += n
+	return b
+}
+
+func (d *decoder) remaining() int     { return len(d.b) - d.pos }
+func (d *decoder) skip(n int)         { d.next(n) }
+func (d *decoder) bytes(n int) []byte { return d.next(n) }
+
+func (d *decoder) u8() uint8 {
+	b := d.next(1)
+	if b == nil {
+		return 0
+	}
+	return b[0]
+}
+
+func (d *decoder) u16() uint16 {
+	b := d.next(2)
+	if b == nil {
+		return 0
+	}
+	return binary.LittleEndian.Uint16(b)
+}
+
+func (d *decoder) u32() uint32 {
+	b := d.next(4)
+	if b == nil {
+		return 0
+	}
+	return binary.LittleEndian.Uint32(b)
+}
+
+func (d *decoder) u64() uint64 {
+	b := d.next(8)
+	if b == nil {
+		return 0
+	}
+	return binary.LittleEndian.Uint64(b)
+}
+
+// uint reads an unsigned integer of n bytes, as used by the variable sized fields of the format.
+func (d *decoder) uint(n int) uint64 {
+	b := d.next(n)
+	var v uint64
+	for i := len(b) - 1; i >= 0; i-- {
+		v = v<<8 | uint64(b[i])
+	}
+	return v
+}
+
+func (d *decoder) offset() uint64 { return d.uint(d.offsetSize) }
+func (d *decoder) length() uint64 { return d.uint(d.lengthSize) }
+
+func (d *decoder) signature(sig string) {
+	b := d.next(len(sig))
+	if d.err == nil && string(b) != sig {
+		d.err = ErrCorrupted
+	}
+}
@@ -0,0 +1,257 @@
+// Package hdf5 implements a read-only subset of the HDF5 file format, enough to read the weight files written by
+// Keras through h5py.
+package hdf5
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"strings"
+)
+
+var (
+	ErrNotHDF5     = errors.New("hdf5: not an HDF5 file")
+	ErrTruncated   = errors.New("hdf5: truncated structure")
+	ErrCorrupted   = errors.New("hdf5: corrupted structure")
+	ErrUnsupported = errors.New("hdf5: unsupported feature")
+	ErrNotFound    = errors.New("hdf5: object not found")
+)
+
+const signature = "\x89HDF\r\n\x1a\n"
+
+type File struct {
+	r    io.ReaderAt
+	base uint64
+	// size is the size of the data r reads, and eof the end of the file according to its superblock, relative to
+	// base; sizes read from the file are checked against them before anything is allocated
+	size, eof uint64
+
+	offsetSize, lengthSize int
+
+	rootAddr uint64
+}
+
+func Open(r io.ReaderAt) (*File, error) {
+	// the superblock may be located at 0, 512, 1024, 2048, ...
+	sig := make([]byte, len(signature))
+	for base := uint64(0); ; base = nextSuperblockLocation(base) {
+		if _, err := r.ReadAt(sig, int64(base)); err != nil {
+			if errors.Is(err, io.EOF) {
+				return nil, ErrNotHDF5
+			}
+			return nil, err
+		}
+		if string(sig) == signature {
+			f := &File{r: r, base: base, size: readerSize(r)}
+			if err := f.readSuperblock(); err != nil {
+				return nil, err
+			}
+			return f, nil
+		}
+	}
+}
+
+func nextSuperblockLocation(base uint64) uint64 {
+	if base == 0 {
+		return 512
+	}
+	return base * 2
+}
+
+// readerSize returns the size of the data r reads. Readers that cannot tell it are searched for their last byte.
+func readerSize(r io.ReaderAt) uint64 {
+	switch r := r.(type) {
+	case interface{ Size() int64 }:
+		return uint64(r.Size())
+	case interface{ Stat() (fs.FileInfo, error) }:
+		if info, err := r.Stat(); err == nil && info.Mode().IsRegular() {
+			return uint64(info.Size())
+		}
+	}
+	b := make([]byte, 1)
+	size := uint64(0)
+	for bit := uint64(1) << 62; bit > 0; bit >>= 1 {
+		if n, _ := r.ReadAt(b, int64(size+bit-1)); n == 1 {
+			size += bit
+		}
+	}
+	return size
+}
+
+// readAt reads n bytes at addr. A structure that goes past the end of the file the superblock gives is corrupted,
+// while one that only goes past the end of the data is truncated.
+func (f *File) readAt(addr uint64, n int) ([]byte, error) {
+	if n < 0 || addr > f.eof || uint64(n) > f.eof-addr {
+		return nil, ErrCorrupted
+	}
+	if end := f.base + addr + uint64(n); end < f.base || end > f.size {
+		return nil, ErrTruncated
+	}
+	b := make([]byte, n)
+	if _, err := f.r.ReadAt(b, int64(f.base+addr)); err != nil {
+		if errors.Is(err, io.EOF) {
+			return nil, ErrTruncated
+		}
+		return nil, err
+	}
+	return b, nil
+}
+
+func (f *File) readSuperblock() error {
+	// the largest fixed part of a version 0/1 superblock, with 8 byte offsets and lengths
+	const maxSize = 8 + 16 + 4*8 + 40
+	b := make([]byte, maxSize)
+	n, err := f.r.ReadAt(b, int64(f.base))
+	if err != nil && !errors.Is(err, io.EOF) {
+		return err
+	}
+	d := &decoder{b: b[:n]}
+	d.skip(len(signature))
+	version := d.u8()
+	switch version {
+	case 0, 1:
+		d.skip(3) // free-space, root group symbol table entry and shared header message versions
+		d.skip(1) // reserved
+		d.offsetSize, d.lengthSize = int(d.u8()), int(d.u8())
+		d.skip(1)     // reserved
+		d.skip(2 + 2) // group leaf and internal node K
+		d.skip(4)     // file consistency flags
+		if version == 1 {
+			d.skip(2 + 2) // indexed storage internal node K, reserved
+		}
+	case 2, 3:
+		d.offsetSize, d.lengthSize = int(d.u8()), int(d.u8())
+		d.skip(1) // file consistency flags
+	default:
+		return fmt.Errorf("%w: superblock version %d", ErrUnsupported, version)
+	}
+	if d.err == nil && (!validSize(d.offsetSize) || !validSize(d.lengthSize)) {
+		return ErrCorrupted
+	}
+
+	// like the reference library, addresses are taken relative to where the superblock was actually found,
+	// instead of to the stored base address
+	d.skip(d.offsetSize) // base address
+	if version < 2 {
+		d.skip(d.offsetSize) // free-space info address
+		f.eof = d.offset()
+		d.skip(d.offsetSize) // driver information block address
+		d.skip(d.offsetSize) // root group link name offset
+		f.rootAddr = d.offset()
+	} else {
+		d.skip(d.offsetSize) // superblock extension address
+		f.eof = d.offset()
+		f.rootAddr = d.offset()
+	}
+	if d.err != nil {
+		return d.err
+	}
+	f.offsetSize, f.lengthSize = d.offsetSize, d.lengthSize
+	return nil
+}
+
+// isUndefined reports whether addr is the all-ones address HDF5 uses for "no address".
+func (f *File) isUndefined(addr uint64) bool {
+	return addr == 1<<(8*f.offsetSize)-1
+}
+
+func validSize(size int) bool {
+	return size == 2 || size == 4 || size == 8
+}
+
+func splitPath(path string) []string {
+	var parts []string
+	for _, part := range strings.Split(path, "/") {
+		if part != "" && part != "." {
+			parts = append(parts, part)
+		}
+	}
+	return parts
+}
+
+// object resolves path, relative to the root group, into the header of the object it names.
+func (f *File) object(path string) (*objectHeader, error) {
+	oh, err := f.readObjectHeader(f.rootAddr)
+	if err != nil {
+		return nil, err
+	}
+	for _, name := range splitPath(path) {
+		g, err := f.group(oh)
+		if err != nil {
+			return nil, err
+		}
+		addr, ok := g.lookup(name)
+		if !ok {
+			return nil, fmt.Errorf("%w: %s", ErrNotFound, path)
+		}
+		if oh, err = f.readObjectHeader(addr); err != nil {
+			return nil, err
+		}
+	}
+	return oh, nil
+}
+
+func (f *File) OpenGroup(path string) (*Group, error) {
+	oh, err := f.object(path)
+	if err != nil {
+		return nil, err
+	}
+	return f.group(oh)
+}
+
+func (f *File) OpenDataset(path string) (*Dataset, error) {
+	oh, err := f.object(path)
+	if err != nil {
+		return nil, err
+	}
+	return f.dataset(oh)
+}
+
+// Walk calls fn for every dataset in the file, in storage order, with its full path.
+func (f *File) Walk(fn func(path string, ds *Dataset) error) error {
+	root, err := f.OpenGroup("/")
+	if err != nil {
+		return err
+	}
+	return f.walk(root, "", map[uint64]bool{f.rootAddr: true}, fn)
+}
+
+// walk walks g, whose path ends with prefix. HDF5 allows links to a group from inside it, so the groups on the way
+// to g are kept in ancestors, and are not walked again.
+func (f *File) walk(g *Group, prefix string, ancestors map[uint64]bool,
+	fn func(path string, ds *Dataset) error) error {
+
+	for _, l := range g.links {
+		if ancestors[l.addr] {
+			continue
+		}
+		oh, err := f.readObjectHeader(l.addr)
+		if err != nil {
+			return err
+		}
+		path := prefix + l.name
+		switch {
+		case oh.isGroup():
+			child, err := f.group(oh)
+			if err != nil {
+				return err
+			}
+			ancestors[l.addr] = true
+			err = f.walk(child, path+"/", ancestors, fn)
+			delete(ancestors, l.addr)
+			if err != nil {
+				return err
+			}
+		case oh.isDataset():
+			ds, err := f.dataset(oh)
+			if err != nil {
+				return err
+			}
+			if err := fn(path, ds); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,107 @@
+package hdf5
+
+import (
+	"bytes"
+	"compress/zlib"
+	"fmt"
+	"io"
+)
+
+const (
+	filterDeflate    = 1
+	filterShuffle    = 2
+	filterFletcher32 = 3
+)
+
+type filter struct {
+	id         uint16
+	clientData []uint32
+}
+
+func parseFilterPipeline(d *decoder) ([]filter, error) {
+	version := d.u8()
+	if version != 1 && version != 2 {
+		return nil, fmt.Errorf("%w: filter pipeline version %d", ErrUnsupported, version)
+	}
+	filters := make([]filter, d.u8())
+	if version == 1 {
+		d.skip(2 + 4) // reserved
+	}
+	for i := range filters {
+		filters[i].id = d.u16()
+		var nameLength int
+		if version == 1 || filters[i].id >= 256 {
+			nameLength = int(d.u16())
+		}
+		d.skip(2) // flags
+		values := int(d.u16())
+		if version == 1 {
+			nameLength = (nameLength + 7) / 8 * 8
+		}
+		d.skip(nameLength)
+		filters[i].clientData = make([]uint32, values)
+		for j := range filters[i].clientData {
+			filters[i].clientData[j] = d.u32()
+		}
+		if version == 1 && values%2 == 1 {
+			d.skip(4) // padding
+		}
+	}
+	return filters, d.err
+}
+
+// unfilter reverses the filter pipeline on a chunk. Filters whose bit is set in mask were skipped when writing.
+func (ds *Dataset) unfilter(chunk []byte, mask uint32) ([]byte, error) {
+	for i := len(ds.filters) - 1; i >= 0; i-- {
+		if mask&(1<<i) != 0 {
+			continue
+		}
+		switch ds.filters[i].id {
+		case filterDeflate:
+			zr, err := zlib.NewReader(bytes.NewReader(chunk))
+			if err != nil {
+				return nil, err
+			}
+			// a chunk decompresses to at most its size and the checksums of the filters applied before deflate,
+			// which bounds what a corrupted chunk can decompress to
+			limit := ds.chunkBytes() + 4*len(ds.filters)
+			if chunk, err = io.ReadAll(io.LimitReader(zr, int64(limit)+1)); err != nil {
+				return nil, err
+			}
+			if len(chunk) > limit {
+				return nil, fmt.Errorf("%w: chunk decompresses to more than %d bytes", ErrCorrupted, limit)
+			}
+		case filterShuffle:
+			size := ds.Type.Size
+			if len(ds.filters[i].clientData) > 0 {
+				size = int(ds.filters[i].clientData[0])
+			}
+			chunk = unshuffle(chunk, size)
+		case filterFletcher32:
+			if len(chunk) < 4 {
+				return nil, ErrTruncated
+			}
+			chunk = chunk[:len(chunk)-4]
+		default:
+			return nil, fmt.Errorf("%w: filter %d", ErrUnsupported, ds.filters[i].id)
+		}
+	}
+	return chunk, nil
+}
+
+// unshuffle undoes the shuffle filter, which stores the first byte of every element, then the second byte of
+// every element and so on. Trailing bytes that do not make up a whole element are left in place.
+func unshuffle(b []byte, size int) []byte {
+	if size <= 1 {
+		return b
+	}
+	count := len(b) / size
+	out := make([]byte, len(b))
+	for j := 0; j < size; j++ {
+		for i := 0; i < count; i++ {
+			out[i*size+j] = b[j*count+i]
+		}
+	}
+	copy(out[count*size:], b[count*size:])
+	return out
+}
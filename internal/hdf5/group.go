@@ -0,0 +1,233 @@
+package hdf5
+
+import (
+	"bytes"
+	"fmt"
+)
+
+type link struct {
+	name string
+	addr uint64
+}
+
+type Group struct {
+	file   *File
+	header *objectHeader
+	links  []link
+}
+
+// Names returns the names of the group's members in storage order.
+func (g *Group) Names() []string {
+	names := make([]string, len(g.links))
+	for i, l := range g.links {
+		names[i] = l.name
+	}
+	return names
+}
+
+func (g *Group) Attributes() ([]Attribute, error) {
+	return g.file.attributes(g.header)
+}
+
+func (g *Group) lookup(name string) (uint64, bool) {
+	for _, l := range g.links {
+		if l.name == name {
+			return l.addr, true
+		}
+	}
+	return 0, false
+}
+
+func (f *File) group(oh *objectHeader) (*Group, error) {
+	if data := oh.find(msgSymbolTable); data != nil {
+		d := f.decoder(data)
+		btreeAddr, heapAddr := d.offset(), d.offset()
+		if d.err != nil {
+			return nil, d.err
+		}
+		heap, err := f.readLocalHeap(heapAddr)
+		if err != nil {
+			return nil, err
+		}
+		g := &Group{file: f, header: oh}
+		if err := f.readGroupBTree(btreeAddr, heap, g, map[uint64]bool{}); err != nil {
+			return nil, err
+		}
+		return g, nil
+	}
+	if !oh.isGroup() {
+		return nil, fmt.Errorf("%w: not a group", ErrNotFound)
+	}
+
+	if data := oh.find(msgLinkInfo); data != nil {
+		d := f.decoder(data)
+		d.skip(1) // version
+		if flags := d.u8(); flags&0x01 != 0 {
+			d.skip(8) // maximum creation index
+		}
+		heapAddr := d.offset()
+		if d.err != nil {
+			return nil, d.err
+		}
+		if !f.isUndefined(heapAddr) {
+			return nil, fmt.Errorf("%w: dense link storage", ErrUnsupported)
+		}
+	}
+	g := &Group{file: f, header: oh}
+	for _, data := range oh.findAll(msgLink) {
+		l, ok, err := f.parseLink(data)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			g.links = append(g.links, l)
+		}
+	}
+	return g, nil
+}
+
+// parseLink parses a link message, reporting whether it is a hard link. Soft and external links are ignored.
+func (f *File) parseLink(data []byte) (link, bool, error) {
+	d := f.decoder(data)
+	if version := d.u8(); version != 1 {
+		return link{}, false, fmt.Errorf("%w: link message version %d", ErrUnsupported, version)
+	}
+	flags := d.u8()
+	var linkType uint8
+	if flags&0x08 != 0 {
+		linkType = d.u8()
+	}
+	if flags&0x04 != 0 {
+		d.skip(8) // creation order
+	}
+	if flags&0x10 != 0 {
+		d.skip(1) // name character set
+	}
+	nameLength := d.uint(1 << (flags & 0x03))
+	name := string(d.bytes(int(nameLength)))
+	if linkType != 0 {
+		return link{}, false, d.err
+	}
+	addr := d.offset()
+	return link{name: name, addr: addr}, true, d.err
+}
+
+func (f *File) readLocalHeap(addr uint64) ([]byte, error) {
+	b, err := f.readAt(addr, 8+2*f.lengthSize+f.offsetSize)
+	if err != nil {
+		return nil, err
+	}
+	d := f.decoder(b)
+	d.signature("HEAP")
+	d.skip(1 + 3) // version, reserved
+	size := d.length()
+	d.skip(f.lengthSize) // offset to head of free-list
+	dataAddr := d.offset()
+	if d.err != nil {
+		return nil, d.err
+	}
+	return f.readAt(dataAddr, int(size))
+}
+
+func heapString(heap []byte, offset uint64) (string, error) {
+	if offset >= uint64(len(heap)) {
+		return "", ErrCorrupted
+	}
+	s := heap[offset:]
+	if end := bytes.IndexByte(s, 0); end >= 0 {
+		s = s[:end]
+	}
+	return string(s), nil
+}
+
+// readBTreeNode reads a version 1 B-tree node, returning its level and the addresses of its children. The keys
+// are returned raw, keySize bytes each, with one more key than there are children.
+func (f *File) readBTreeNode(addr uint64, nodeType uint8, keySize int) (level uint8, keys [][]byte,
+	children []uint64, err error) {
+
+	header, err := f.readAt(addr, 8+2*f.offsetSize)
+	if err != nil {
+		return 0, nil, nil, err
+	}
+	d := f.decoder(header)
+	d.signature("TREE")
+	if typ := d.u8(); d.err == nil && typ != nodeType {
+		return 0, nil, nil, ErrCorrupted
+	}
+	level = d.u8()
+	entries := int(d.u16())
+	if d.err != nil {
+		return 0, nil, nil, d.err
+	}
+
+	b, err := f.readAt(addr+uint64(len(header)), entries*(keySize+f.offsetSize)+keySize)
+	if err != nil {
+		return 0, nil, nil, err
+	}
+	d = f.decoder(b)
+	for i := 0; i < entries; i++ {
+		keys = append(keys, d.bytes(keySize))
+		children = append(children, d.offset())
+	}
+	keys = append(keys, d.bytes(keySize))
+	return level, keys, children, d.err
+}
+
+// readGroupBTree reads the links of a group from the B-tree node at addr and its children. visited holds the nodes
+// read so far, since a node that is reached twice would make the tree a loop.
+func (f *File) readGroupBTree(addr uint64, heap []byte, g *Group, visited map[uint64]bool) error {
+	if visited[addr] {
+		return fmt.Errorf("%w: group B-tree loops", ErrCorrupted)
+	}
+	visited[addr] = true
+	level, _, children, err := f.readBTreeNode(addr, 0, f.lengthSize)
+	if err != nil {
+		return err
+	}
+	for _, child := range children {
+		if level > 0 {
+			err = f.readGroupBTree(child, heap, g, visited)
+		} else {
+			err = f.readSymbolTableNode(child, heap, g)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (f *File) readSymbolTableNode(addr uint64, heap []byte, g *Group) error {
+	header, err := f.readAt(addr, 8)
+	if err != nil {
+		return err
+	}
+	d := f.decoder(header)
+	d.signature("SNOD")
+	d.skip(1 + 1) // version, reserved
+	count := int(d.u16())
+	if d.err != nil {
+		return d.err
+	}
+
+	entrySize := 2*f.offsetSize + 4 + 4 + 16
+	b, err := f.readAt(addr+8, count*entrySize)
+	if err != nil {
+		return err
+	}
+	d = f.decoder(b)
+	for i := 0; i < count; i++ {
+		nameOffset := d.offset()
+		objectAddr := d.offset()
+		d.skip(4 + 4 + 16) // cache type, reserved, scratch-pad
+		if d.err != nil {
+			return d.err
+		}
+		name, err := heapString(heap, nameOffset)
+		if err != nil {
+			return err
+		}
+		g.links = append(g.links, link{name: name, addr: objectAddr})
+	}
+	return nil
+}
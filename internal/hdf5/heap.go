@@ -0,0 +1,42 @@
+package hdf5
+
+// globalHeapObject returns the data of an object in the global heap collection at addr, which is where
+// variable-length data is stored.
+func (f *File) globalHeapObject(addr uint64, index uint32) ([]byte, error) {
+	header, err := f.readAt(addr, 8+f.lengthSize)
+	if err != nil {
+		return nil, err
+	}
+	d := f.decoder(header)
+	d.signature("GCOL")
+	d.skip(1 + 3) // version, reserved
+	size := d.length()
+	if d.err != nil {
+		return nil, d.err
+	}
+
+	collection, err := f.readAt(addr, int(size))
+	if err != nil {
+		return nil, err
+	}
+	d = f.decoder(collection)
+	d.skip(len(header))
+	for d.remaining() >= 8+f.lengthSize {
+		objectIndex := d.u16()
+		d.skip(2 + 4) // reference count, reserved
+		objectSize := d.length()
+		if objectIndex == 0 {
+			// the free space object runs to the end of the collection
+			break
+		}
+		data := d.bytes(int(objectSize))
+		d.skip(int(-objectSize & 7)) // objects are padded to a multiple of 8 bytes
+		if d.err != nil {
+			return nil, d.err
+		}
+		if uint32(objectIndex) == index {
+			return data, nil
+		}
+	}
+	return nil, ErrCorrupted
+}
@@ -0,0 +1,201 @@
+package hdf5
+
+import (
+	"fmt"
+)
+
+const (
+	msgNil            = 0x0000
+	msgDataspace      = 0x0001
+	msgLinkInfo       = 0x0002
+	msgDatatype       = 0x0003
+	msgLink           = 0x0006
+	msgLayout         = 0x0008
+	msgGroupInfo      = 0x000A
+	msgFilterPipeline = 0x000B
+	msgAttribute      = 0x000C
+	msgContinuation   = 0x0010
+	msgSymbolTable    = 0x0011
+)
+
+type message struct {
+	typ  uint16
+	data []byte
+}
+
+type objectHeader struct {
+	messages []message
+}
+
+func (oh *objectHeader) find(typ uint16) []byte {
+	for _, m := range oh.messages {
+		if m.typ == typ {
+			return m.data
+		}
+	}
+	return nil
+}
+
+func (oh *objectHeader) findAll(typ uint16) [][]byte {
+	var found [][]byte
+	for _, m := range oh.messages {
+		if m.typ == typ {
+			found = append(found, m.data)
+		}
+	}
+	return found
+}
+
+func (oh *objectHeader) isGroup() bool {
+	return oh.find(msgSymbolTable) != nil || oh.find(msgLinkInfo) != nil || oh.find(msgGroupInfo) != nil ||
+		oh.find(msgLink) != nil
+}
+
+func (oh *objectHeader) isDataset() bool {
+	return oh.find(msgLayout) != nil
+}
+
+type headerBlock struct {
+	addr, size uint64
+}
+
+func (f *File) readObjectHeader(addr uint64) (*objectHeader, error) {
+	sig, err := f.readAt(addr, 4)
+	if err != nil {
+		return nil, err
+	}
+	if string(sig) == "OHDR" {
+		return f.readObjectHeaderV2(addr)
+	}
+	return f.readObjectHeaderV1(addr)
+}
+
+func (f *File) readObjectHeaderV1(addr uint64) (*objectHeader, error) {
+	prefix, err := f.readAt(addr, 16)
+	if err != nil {
+		return nil, err
+	}
+	d := f.decoder(prefix)
+	if version := d.u8(); version != 1 {
+		return nil, fmt.Errorf("%w: object header version %d", ErrUnsupported, version)
+	}
+	d.skip(1) // reserved
+	d.skip(2) // number of messages
+	d.skip(4) // object reference count
+	size := d.u32()
+
+	oh := &objectHeader{}
+	// the messages of a version 1 header are aligned to 8 bytes, so they start after the padded 12 byte prefix
+	blocks := []headerBlock{{addr + 16, uint64(size)}}
+	visited := map[uint64]bool{addr + 16: true}
+	for len(blocks) > 0 {
+		block := blocks[0]
+		blocks = blocks[1:]
+		b, err := f.readAt(block.addr, int(block.size))
+		if err != nil {
+			return nil, err
+		}
+		d := f.decoder(b)
+		for d.remaining() >= 8 {
+			typ := d.u16()
+			size := d.u16()
+			d.skip(1 + 3) // flags, reserved
+			data := d.bytes(int(size))
+			if d.err != nil {
+				return nil, d.err
+			}
+			if blocks, err = oh.add(f, typ, data, blocks, visited); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return oh, nil
+}
+
+func (f *File) readObjectHeaderV2(addr uint64) (*objectHeader, error) {
+	prefix, err := f.readAt(addr, 6)
+	if err != nil {
+		return nil, err
+	}
+	if version := prefix[4]; version != 2 {
+		return nil, fmt.Errorf("%w: object header version %d", ErrUnsupported, version)
+	}
+	flags := prefix[5]
+	prefixSize := 6
+	if flags&0x20 != 0 {
+		prefixSize += 4 * 4 // access, modification, change and birth times
+	}
+	if flags&0x10 != 0 {
+		prefixSize += 2 * 2 // maximum compact and minimum dense attribute counts
+	}
+	sizeLength := 1 << (flags & 0x03)
+	if prefix, err = f.readAt(addr, prefixSize+sizeLength); err != nil {
+		return nil, err
+	}
+	d := f.decoder(prefix)
+	d.skip(prefixSize)
+	size := d.uint(sizeLength)
+
+	messageHeaderSize := 4
+	if flags&0x04 != 0 {
+		messageHeaderSize += 2 // creation order
+	}
+
+	oh := &objectHeader{}
+	blocks := []headerBlock{{addr + uint64(prefixSize+sizeLength), size}}
+	visited := map[uint64]bool{blocks[0].addr: true}
+	for first := true; len(blocks) > 0; first = false {
+		block := blocks[0]
+		blocks = blocks[1:]
+		b, err := f.readAt(block.addr, int(block.size))
+		if err != nil {
+			return nil, err
+		}
+		d := f.decoder(b)
+		if !first {
+			// continuation blocks have a signature and a checksum around the messages
+			if len(b) < 8 {
+				return nil, ErrCorrupted
+			}
+			d.signature("OCHK")
+			d.b = d.b[:len(d.b)-4]
+		}
+		for d.remaining() >= messageHeaderSize {
+			typ := d.u8()
+			size := d.u16()
+			d.skip(messageHeaderSize - 3) // flags and creation order
+			data := d.bytes(int(size))
+			if d.err != nil {
+				return nil, d.err
+			}
+			if blocks, err = oh.add(f, uint16(typ), data, blocks, visited); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return oh, nil
+}
+
+// add records a message read from the header, following continuation messages by appending to blocks. visited holds
+// the addresses of the header's blocks so far, so that continuation messages cannot go round in a loop.
+func (oh *objectHeader) add(f *File, typ uint16, data []byte, blocks []headerBlock,
+	visited map[uint64]bool) ([]headerBlock, error) {
+
+	switch typ {
+	case msgNil:
+	case msgContinuation:
+		d := f.decoder(data)
+		next := headerBlock{d.offset(), d.length()}
+		if d.err != nil {
+			return nil, d.err
+		}
+		if visited[next.addr] {
+			return nil, fmt.Errorf("%w: object header continuation loops", ErrCorrupted)
+		}
+		visited[next.addr] = true
+		blocks = append(blocks, next)
+	default:
+		oh.messages = append(oh.messages, message{typ: typ, data: data})
+	}
+	return blocks, nil
+}
@@ -4,18 +4,15 @@ import (
 	"archive/zip"
 	"bytes"
 	"io"
+	"os"
+	"sort"
 	"strconv"
+	"strings"
 	"unsafe"
 
 	"github.com/kshedden/gonpy"
 )
 
-func castAndCopy[T, U SizedNumber](dst []T, src []U) {
-	for i := 0; i < len(dst); i++ {
-		dst[i] = T(src[i])
-	}
-}
-
 func sizedNumberToDtype[T SizedNumber]() string {
 	var zero T
 	var t any = zero
@@ -46,108 +43,145 @@ func sizedNumberToDtype[T SizedNumber]() string {
 }
 
 func LoadNumpyDataFrame[T SizedNumber](r io.Reader) (DataFrame[T], error) {
-	npyr, err := gonpy.NewReader(r)
+	df, err := LoadNumpyAnyDataFrame(r)
 	if err != nil {
 		return DataFrame[T]{}, err
 	}
-	if npyr.Dtype != sizedNumberToDtype[T]() {
-		return DataFrame[T]{}, ErrDifferentDataType
+	return As[T](df)
+}
+
+// LoadNumpyAnyDataFrame loads a .npy file of any supported dtype.
+func LoadNumpyAnyDataFrame(r io.Reader) (AnyDataFrame, error) {
+	h, err := readNumpyHeader(r)
+	if err != nil {
+		return AnyDataFrame{}, err
+	}
+	data, err := readNumpyAnyData(r, h)
+	if err != nil {
+		return AnyDataFrame{}, err
 	}
+	return AnyDataFrame{Dtype: h.dtype, Dims: h.dims(), Data: data}, nil
+}
+
+func LoadNumpyDataFrames[T SizedNumber](r io.Reader) ([]DataFrame[T], error) {
+	_, dfs, err := loadNumpyArchive[T](r)
+	return dfs, err
+}
 
-	shape := npyr.Shape
-	if len(shape) == 0 {
-		shape = []int{1}
+// LoadNumpyDataFrameMap loads an archive saved by np.savez or np.savez_compressed, keyed by the names the arrays
+// were saved with. The names are also returned in archive order.
+func LoadNumpyDataFrameMap[T SizedNumber](r io.Reader) (map[string]DataFrame[T], []string, error) {
+	names, dfs, err := loadNumpyArchive[T](r)
+	if err != nil {
+		return nil, nil, err
 	}
-	df := MakeDataFrame[T](shape)
+	return dataFrameMap(names, dfs), names, nil
+}
 
-	switch npyr.Dtype {
-	case "c16", "c8":
-		return df, ErrUnsupportedType
-	case "f8":
-		data, err := npyr.GetFloat64()
-		if err != nil {
-			return df, err
-		}
-		castAndCopy(df.Data, data)
-	case "f4":
-		data, err := npyr.GetFloat32()
-		if err != nil {
-			return df, err
-		}
-		castAndCopy(df.Data, data)
-	case "u8":
-		data, err := npyr.GetUint64()
-		if err != nil {
-			return df, err
+// LoadNumpyAnyDataFrames loads an archive whose arrays may have different dtypes.
+func LoadNumpyAnyDataFrames(r io.Reader) ([]AnyDataFrame, error) {
+	_, dfs, err := loadNumpyAnyArchive(r)
+	return dfs, err
+}
+
+// LoadNumpyAnyDataFrameMap is like LoadNumpyDataFrameMap, for archives whose arrays may have different dtypes.
+func LoadNumpyAnyDataFrameMap(r io.Reader) (map[string]AnyDataFrame, []string, error) {
+	names, dfs, err := loadNumpyAnyArchive(r)
+	if err != nil {
+		return nil, nil, err
+	}
+	return dataFrameMap(names, dfs), names, nil
+}
+
+func dataFrameMap[D any](names []string, dfs []D) map[string]D {
+	dfMap := make(map[string]D, len(dfs))
+	for i, name := range names {
+		dfMap[name] = dfs[i]
+	}
+	return dfMap
+}
+
+func loadNumpyArchive[T SizedNumber](r io.Reader) ([]string, []DataFrame[T], error) {
+	names, anyDfs, err := loadNumpyAnyArchive(r)
+	if err != nil {
+		return nil, nil, err
+	}
+	dfs := make([]DataFrame[T], len(anyDfs))
+	for i, df := range anyDfs {
+		if dfs[i], err = As[T](df); err != nil {
+			return names, dfs, err
 		}
-		castAndCopy(df.Data, data)
-	case "u4":
-		data, err := npyr.GetUint32()
+	}
+	return names, dfs, nil
+}
+
+func loadNumpyAnyArchive(r io.Reader) ([]string, []AnyDataFrame, error) {
+	ra, size, err := readerAt(r)
+	if err != nil {
+		return nil, nil, err
+	}
+	zr, err := zip.NewReader(ra, size)
+	if err != nil {
+		return nil, nil, err
+	}
+	names := make([]string, len(zr.File))
+	dfs := make([]AnyDataFrame, len(zr.File))
+	for i, fileinfo := range zr.File {
+		names[i] = strings.TrimSuffix(fileinfo.Name, ".npy")
+		f, err := fileinfo.Open()
 		if err != nil {
-			return df, err
+			return names, dfs, err
 		}
-		castAndCopy(df.Data, data)
-	case "u2":
-		data, err := npyr.GetUint16()
+		dfs[i], err = LoadNumpyAnyDataFrame(f)
+		f.Close()
 		if err != nil {
-			return df, err
+			return names, dfs, err
 		}
-		castAndCopy(df.Data, data)
-	case "u1":
-		data, err := npyr.GetUint8()
+	}
+	return names, dfs, nil
+}
+
+// readerAt returns the rest of r, from its current offset, as an io.ReaderAt if it can be read at random, and reads it
+// into memory otherwise.
+func readerAt(r io.Reader) (io.ReaderAt, int64, error) {
+	var ra io.ReaderAt
+	var size int64
+	switch r := r.(type) {
+	case interface {
+		io.ReaderAt
+		Size() int64
+	}:
+		ra, size = r, r.Size()
+	case *os.File:
+		info, err := r.Stat()
 		if err != nil {
-			return df, err
+			return nil, 0, err
 		}
-		castAndCopy(df.Data, data)
-	case "i8":
-		data, err := npyr.GetInt64()
-		if err != nil {
-			return df, err
+		if info.Mode().IsRegular() {
+			ra, size = r, info.Size()
 		}
-		castAndCopy(df.Data, data)
-	case "i4":
-		data, err := npyr.GetInt32()
-		if err != nil {
-			return df, err
+	}
+	if ra != nil {
+		offset := int64(0)
+		if s, ok := r.(io.Seeker); ok {
+			var err error
+			if offset, err = s.Seek(0, io.SeekCurrent); err != nil {
+				return nil, 0, err
+			}
 		}
-		castAndCopy(df.Data, data)
-	case "i2":
-		data, err := npyr.GetInt16()
-		if err != nil {
-			return df, err
+		if offset == 0 {
+			return ra, size, nil
 		}
-		castAndCopy(df.Data, data)
-	case "i1":
-		data, err := npyr.GetInt8()
-		if err != nil {
-			return df, err
+		if offset > size {
+			offset = size
 		}
-		castAndCopy(df.Data, data)
+		return io.NewSectionReader(ra, offset, size-offset), size - offset, nil
 	}
-	return df, nil
-}
-
-func LoadNumpyDataFrames[T SizedNumber](r io.Reader) ([]DataFrame[T], error) {
 	data, err := io.ReadAll(r)
 	if err != nil {
-		return nil, err
-	}
-	zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
-	if err != nil {
-		return nil, err
+		return nil, 0, err
 	}
-	dfs := make([]DataFrame[T], len(zr.File))
-	for i, fileinfo := range zr.File {
-		f, err := zr.Open(fileinfo.Name)
-		if err != nil {
-			return dfs, err
-		}
-		dfs[i], err = LoadNumpyDataFrame[T](f)
-		if err != nil {
-			return dfs, err
-		}
-	}
-	return dfs, nil
+	return bytes.NewReader(data), int64(len(data)), nil
 }
 
 type writerCloserExtender struct {
@@ -203,10 +237,94 @@ func SaveNumpyDataFrame[T SizedNumber](df DataFrame[T], w io.Writer) error {
 	}
 }
 
+func SaveNumpyAnyDataFrame(df AnyDataFrame, w io.Writer) error {
+	switch data := df.Data.(type) {
+	case []int8:
+		return SaveNumpyDataFrame(DataFrame[int8]{Dims: df.Dims, Data: data}, w)
+	case []int16:
+		return SaveNumpyDataFrame(DataFrame[int16]{Dims: df.Dims, Data: data}, w)
+	case []int32:
+		return SaveNumpyDataFrame(DataFrame[int32]{Dims: df.Dims, Data: data}, w)
+	case []int64:
+		return SaveNumpyDataFrame(DataFrame[int64]{Dims: df.Dims, Data: data}, w)
+	case []uint8:
+		return SaveNumpyDataFrame(DataFrame[uint8]{Dims: df.Dims, Data: data}, w)
+	case []uint16:
+		return SaveNumpyDataFrame(DataFrame[uint16]{Dims: df.Dims, Data: data}, w)
+	case []uint32:
+		return SaveNumpyDataFrame(DataFrame[uint32]{Dims: df.Dims, Data: data}, w)
+	case []uint64:
+		return SaveNumpyDataFrame(DataFrame[uint64]{Dims: df.Dims, Data: data}, w)
+	case []float32:
+		return SaveNumpyDataFrame(DataFrame[float32]{Dims: df.Dims, Data: data}, w)
+	case []float64:
+		return SaveNumpyDataFrame(DataFrame[float64]{Dims: df.Dims, Data: data}, w)
+	default:
+		return ErrUnsupportedType
+	}
+}
+
+// SaveNumpyDataFrames saves dfs like np.savez, as arr_0, arr_1, ...
 func SaveNumpyDataFrames[T SizedNumber](dfs []DataFrame[T], w io.Writer) error {
+	return saveNumpyArchive(numpyArchiveNames(len(dfs)), dfs, w, zip.Store)
+}
+
+// SaveNumpyDataFramesCompressed saves dfs like np.savez_compressed, as arr_0, arr_1, ...
+func SaveNumpyDataFramesCompressed[T SizedNumber](dfs []DataFrame[T], w io.Writer) error {
+	return saveNumpyArchive(numpyArchiveNames(len(dfs)), dfs, w, zip.Deflate)
+}
+
+// SaveNumpyDataFrameMap saves dfs like np.savez with keyword arguments, in sorted name order.
+func SaveNumpyDataFrameMap[T SizedNumber](dfs map[string]DataFrame[T], w io.Writer) error {
+	names, sorted := sortedDataFrames(dfs)
+	return saveNumpyArchive(names, sorted, w, zip.Store)
+}
+
+// SaveNumpyDataFrameMapCompressed saves dfs like np.savez_compressed with keyword arguments, in sorted name order.
+func SaveNumpyDataFrameMapCompressed[T SizedNumber](dfs map[string]DataFrame[T], w io.Writer) error {
+	names, sorted := sortedDataFrames(dfs)
+	return saveNumpyArchive(names, sorted, w, zip.Deflate)
+}
+
+// SaveActivations saves the activations returned by PredictWithActivations like np.savez, each named after its
+// node, with the model's inputs first and then its layers in the order they were added.
+func (m *Model[T]) SaveActivations(activations map[*LayerData[T]]DataFrame[T], w io.Writer) error {
+	var names []string
+	var dfs []DataFrame[T]
+	for _, node := range append(append([]*LayerData[T]{}, m.inputs...), m.layersData...) {
+		if df, ok := activations[node]; ok {
+			names = append(names, node.name)
+			dfs = append(dfs, df)
+		}
+	}
+	return saveNumpyArchive(names, dfs, w, zip.Store)
+}
+
+func numpyArchiveNames(count int) []string {
+	names := make([]string, count)
+	for i := range names {
+		names[i] = "arr_" + strconv.Itoa(i)
+	}
+	return names
+}
+
+func sortedDataFrames[T SizedNumber](dfs map[string]DataFrame[T]) ([]string, []DataFrame[T]) {
+	names := make([]string, 0, len(dfs))
+	for name := range dfs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	sorted := make([]DataFrame[T], len(names))
+	for i, name := range names {
+		sorted[i] = dfs[name]
+	}
+	return names, sorted
+}
+
+func saveNumpyArchive[T SizedNumber](names []string, dfs []DataFrame[T], w io.Writer, method uint16) error {
 	zw := zip.NewWriter(w)
 	for i, df := range dfs {
-		f, err := zw.Create("arr_" + strconv.Itoa(i) + ".npy")
+		f, err := zw.CreateHeader(&zip.FileHeader{Name: names[i] + ".npy", Method: method})
 		if err != nil {
 			return err
 		}
@@ -215,6 +333,5 @@ func SaveNumpyDataFrames[T SizedNumber](dfs []DataFrame[T], w io.Writer) error {
 			return err
 		}
 	}
-	zw.Close()
-	return nil
+	return zw.Close()
 }
@@ -0,0 +1,386 @@
+package elefas_test
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/YohayAiTe/elefas"
+)
+
+func TestNumpyDataFrameMap(t *testing.T) {
+	dfs := map[string]elefas.DataFrame[float32]{
+		"dense_kernel": {Dims: []int{2, 3}, Data: []float32{1, 2, 3, 4, 5, 6}},
+		"dense_bias":   {Dims: []int{3}, Data: []float32{0.5, -0.5, 0}},
+	}
+	for _, test := range []struct {
+		name   string
+		save   func(map[string]elefas.DataFrame[float32], *bytes.Buffer) error
+		method uint16
+	}{
+		{"savez", func(dfs map[string]elefas.DataFrame[float32], buf *bytes.Buffer) error {
+			return elefas.SaveNumpyDataFrameMap(dfs, buf)
+		}, zip.Store},
+		{"savez_compressed", func(dfs map[string]elefas.DataFrame[float32], buf *bytes.Buffer) error {
+			return elefas.SaveNumpyDataFrameMapCompressed(dfs, buf)
+		}, zip.Deflate},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := test.save(dfs, &buf); err != nil {
+				t.Fatalf("error saving: %v", err)
+			}
+
+			zr, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+			if err != nil {
+				t.Fatalf("error reading archive: %v", err)
+			}
+			for _, f := range zr.File {
+				if f.Method != test.method {
+					t.Errorf("%s has compression method %d, expected %d", f.Name, f.Method, test.method)
+				}
+			}
+
+			loaded, names, err := elefas.LoadNumpyDataFrameMap[float32](bytes.NewReader(buf.Bytes()))
+			if err != nil {
+				t.Fatalf("error loading: %v", err)
+			}
+			if !reflect.DeepEqual(names, []string{"dense_bias", "dense_kernel"}) {
+				t.Errorf("unexpected archive order: %v", names)
+			}
+			if !reflect.DeepEqual(loaded, dfs) {
+				t.Errorf("loaded %v, expected %v", loaded, dfs)
+			}
+		})
+	}
+}
+
+func TestNumpyDataFrameMapOffset(t *testing.T) {
+	dfs := map[string]elefas.DataFrame[float32]{"kernel": {Dims: []int{2}, Data: []float32{1, 2}}}
+	buf := bytes.NewBufferString("header")
+	if err := elefas.SaveNumpyDataFrameMap(dfs, buf); err != nil {
+		t.Fatalf("error saving: %v", err)
+	}
+	data := buf.Bytes()
+	path := filepath.Join(t.TempDir(), "weights.bin")
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	// readers that can be read at random are read from their current offset, like any other reader: past the
+	// header the archive loads, and at the end there is nothing left to load
+	for _, offset := range []int{len("header"), len(data)} {
+		for name, r := range map[string]io.ReadSeeker{"file": f, "bytes": bytes.NewReader(data)} {
+			if _, err := r.Seek(int64(offset), io.SeekStart); err != nil {
+				t.Fatal(err)
+			}
+			loaded, _, err := elefas.LoadNumpyDataFrameMap[float32](r)
+			if offset == len(data) {
+				if err == nil {
+					t.Errorf("loading from a %s at its end returned %v, expected an error", name, loaded)
+				}
+			} else if err != nil {
+				t.Errorf("error loading from a %s: %v", name, err)
+			} else if !reflect.DeepEqual(loaded, dfs) {
+				t.Errorf("loaded %v from a %s, expected %v", loaded, name, dfs)
+			}
+		}
+	}
+}
+
+func TestNumpyDataFramesCompressed(t *testing.T) {
+	dfs := []elefas.DataFrame[int64]{
+		{Dims: []int{2}, Data: []int64{-1, 1}},
+		{Dims: []int{1, 1}, Data: []int64{7}},
+	}
+	var buf bytes.Buffer
+	if err := elefas.SaveNumpyDataFramesCompressed(dfs, &buf); err != nil {
+		t.Fatalf("error saving: %v", err)
+	}
+	loaded, names, err := elefas.LoadNumpyDataFrameMap[int64](&buf)
+	if err != nil {
+		t.Fatalf("error loading: %v", err)
+	}
+	if !reflect.DeepEqual(names, []string{"arr_0", "arr_1"}) {
+		t.Errorf("unexpected names: %v", names)
+	}
+	for i, name := range names {
+		if !reflect.DeepEqual(loaded[name], dfs[i]) {
+			t.Errorf("%s is %v, expected %v", name, loaded[name], dfs[i])
+		}
+	}
+}
+
+func TestNumpyAnyDataFrameMap(t *testing.T) {
+	dfs := map[string]elefas.AnyDataFrame{
+		"weights": elefas.ToAnyDf(elefas.DataFrame[float32]{Dims: []int{2, 2}, Data: []float32{0.5, 1, 1.5, 2}}),
+		"labels":  elefas.ToAnyDf(elefas.DataFrame[int64]{Dims: []int{3}, Data: []int64{-1, 0, 1}}),
+		"mask":    elefas.ToAnyDf(elefas.DataFrame[uint8]{Dims: []int{3}, Data: []uint8{1, 0, 1}}),
+	}
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for _, name := range []string{"weights", "labels", "mask"} {
+		w, err := zw.Create(name + ".npy")
+		if err != nil {
+			t.Fatalf("error creating %s: %v", name, err)
+		}
+		if err := elefas.SaveNumpyAnyDataFrame(dfs[name], w); err != nil {
+			t.Fatalf("error saving %s: %v", name, err)
+		}
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatalf("error closing archive: %v", err)
+	}
+
+	loaded, names, err := elefas.LoadNumpyAnyDataFrameMap(bytes.NewReader(buf.Bytes()))
+	if err != nil {
+		t.Fatalf("error loading: %v", err)
+	}
+	if !reflect.DeepEqual(names, []string{"weights", "labels", "mask"}) {
+		t.Errorf("unexpected names: %v", names)
+	}
+	if !reflect.DeepEqual(loaded, dfs) {
+		t.Errorf("loaded %v, expected %v", loaded, dfs)
+	}
+
+	if _, err := elefas.As[float32](loaded["labels"]); !errors.Is(err, elefas.ErrDifferentDataType) {
+		t.Errorf("As[float32] on int64 data returned %v, expected %v", err, elefas.ErrDifferentDataType)
+	}
+	labels, err := elefas.As[int64](loaded["labels"])
+	if err != nil {
+		t.Fatalf("error converting labels: %v", err)
+	}
+	if !reflect.DeepEqual(labels.Data, []int64{-1, 0, 1}) {
+		t.Errorf("labels are %v", labels.Data)
+	}
+	if mask := elefas.CastAnyDf[float32](loaded["mask"]); !reflect.DeepEqual(mask.Data, []float32{1, 0, 1}) {
+		t.Errorf("mask cast to float32 is %v", mask.Data)
+	}
+
+	if _, err := elefas.LoadNumpyDataFrames[float32](bytes.NewReader(buf.Bytes())); !errors.Is(err, elefas.ErrDifferentDataType) {
+		t.Errorf("LoadNumpyDataFrames on mixed archive returned %v, expected %v", err, elefas.ErrDifferentDataType)
+	}
+}
+
+func TestMmapNumpyDataFrame(t *testing.T) {
+	dir := t.TempDir()
+	df := elefas.DataFrame[float32]{Dims: []int{2, 3}, Data: []float32{1, 2, 3, 4, 5, 6}}
+	save := func(name string, save func(*bytes.Buffer) error) string {
+		var buf bytes.Buffer
+		if err := save(&buf); err != nil {
+			t.Fatalf("error saving %s: %v", name, err)
+		}
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, buf.Bytes(), 0o644); err != nil {
+			t.Fatalf("error writing %s: %v", name, err)
+		}
+		return path
+	}
+	npy := save("weights.npy", func(buf *bytes.Buffer) error { return elefas.SaveNumpyDataFrame(df, buf) })
+	npz := save("weights.npz", func(buf *bytes.Buffer) error {
+		return elefas.SaveNumpyDataFrameMap(map[string]elefas.DataFrame[float32]{"kernel": df}, buf)
+	})
+	compressed := save("compressed.npz", func(buf *bytes.Buffer) error {
+		return elefas.SaveNumpyDataFrameMapCompressed(map[string]elefas.DataFrame[float32]{"kernel": df}, buf)
+	})
+	bigEndian := save("big_endian.npy", func(buf *bytes.Buffer) error {
+		_, err := buf.Write(rawNumpyFile("'>f4'", false, "(2, 3)", binary.BigEndian, df.Data))
+		return err
+	})
+	fortran := save("fortran.npy", func(buf *bytes.Buffer) error {
+		_, err := buf.Write(rawNumpyFile("'<f4'", true, "(2, 3)", binary.LittleEndian, []float32{1, 4, 2, 5, 3, 6}))
+		return err
+	})
+
+	for _, test := range []struct {
+		name, path, array string
+	}{
+		{"npy", npy, ""},
+		{"stored npz", npz, "kernel"},
+		{"compressed npz", compressed, "kernel"},
+		{"big endian", bigEndian, ""},
+		{"fortran order", fortran, ""},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			f, err := elefas.OpenNumpyFile(test.path)
+			if err != nil {
+				t.Fatalf("error opening: %v", err)
+			}
+			defer func() { f.Close() }()
+			if names := f.Names(); !reflect.DeepEqual(names, []string{test.array}) {
+				t.Errorf("unexpected names: %v", names)
+			}
+
+			loaded, err := elefas.MmapNumpyDataFrame[float32](f, test.array)
+			if err != nil {
+				t.Fatalf("error loading: %v", err)
+			}
+			if !reflect.DeepEqual(loaded, df) {
+				t.Errorf("loaded %v, expected %v", loaded, df)
+			}
+			converted, err := elefas.MmapNumpyDataFrame[float64](f, test.array)
+			if err != nil {
+				t.Fatalf("error loading as float64: %v", err)
+			}
+			if expected := elefas.CastDf[float32, float64](df); !reflect.DeepEqual(converted, expected) {
+				t.Errorf("loaded %v as float64, expected %v", converted, expected)
+			}
+
+			if _, err := elefas.MmapNumpyDataFrame[float32](f, "missing"); !errors.Is(err, elefas.ErrArrayNotFound) {
+				t.Errorf("loading a missing array returned %v, expected %v", err, elefas.ErrArrayNotFound)
+			}
+
+			// the file is mapped copy-on-write, so modifying the data must not change it
+			loaded.Data[0] = 100
+			if err := f.Close(); err != nil {
+				t.Fatalf("error closing: %v", err)
+			}
+			f, err = elefas.OpenNumpyFile(test.path)
+			if err != nil {
+				t.Fatalf("error reopening: %v", err)
+			}
+			if reloaded, err := elefas.MmapNumpyDataFrame[float32](f, test.array); err != nil || reloaded.Data[0] != 1 {
+				t.Errorf("reloaded %v (error %v) after modifying the mapped data", reloaded.Data, err)
+			}
+		})
+	}
+}
+
+// rawNumpyFile builds a .npy file by hand, for layouts SaveNumpyDataFrame does not write.
+func rawNumpyFile(descr string, fortranOrder bool, shape string, order binary.ByteOrder, data any) []byte {
+	fortran := "False"
+	if fortranOrder {
+		fortran = "True"
+	}
+	header := "{'descr': " + descr + ", 'fortran_order': " + fortran + ", 'shape': " + shape + ", }\n"
+	var buf bytes.Buffer
+	buf.WriteString("\x93NUMPY\x01\x00")
+	binary.Write(&buf, binary.LittleEndian, uint16(len(header)))
+	buf.WriteString(header)
+	binary.Write(&buf, order, data)
+	return buf.Bytes()
+}
+
+func TestNumpyDataFrameLayouts(t *testing.T) {
+	for _, test := range []struct {
+		name     string
+		file     []byte
+		expected elefas.AnyDataFrame
+	}{
+		{
+			"fortran order",
+			rawNumpyFile("'<f8'", true, "(2, 3)", binary.LittleEndian, []float64{1, 4, 2, 5, 3, 6}),
+			elefas.ToAnyDf(elefas.DataFrame[float64]{Dims: []int{2, 3}, Data: []float64{1, 2, 3, 4, 5, 6}}),
+		},
+		{
+			"fortran order 3d",
+			rawNumpyFile("'<i2'", true, "(2, 2, 2)", binary.LittleEndian, []int16{0, 4, 2, 6, 1, 5, 3, 7}),
+			elefas.ToAnyDf(elefas.DataFrame[int16]{Dims: []int{2, 2, 2}, Data: []int16{0, 1, 2, 3, 4, 5, 6, 7}}),
+		},
+		{
+			"fortran order 1d",
+			rawNumpyFile("'<u2'", true, "(3,)", binary.LittleEndian, []uint16{1, 2, 3}),
+			elefas.ToAnyDf(elefas.DataFrame[uint16]{Dims: []int{3}, Data: []uint16{1, 2, 3}}),
+		},
+		{
+			"big endian",
+			rawNumpyFile("'>i4'", false, "(3,)", binary.BigEndian, []int32{-2, 1, 1 << 20}),
+			elefas.ToAnyDf(elefas.DataFrame[int32]{Dims: []int{3}, Data: []int32{-2, 1, 1 << 20}}),
+		},
+		{
+			"big endian fortran order",
+			rawNumpyFile("'>f4'", true, "(2, 2)", binary.BigEndian, []float32{1, 3, 2, 4}),
+			elefas.ToAnyDf(elefas.DataFrame[float32]{Dims: []int{2, 2}, Data: []float32{1, 2, 3, 4}}),
+		},
+		{
+			"bool",
+			rawNumpyFile("'|b1'", false, "(4,)", binary.LittleEndian, []uint8{1, 0, 0, 1}),
+			elefas.ToAnyDf(elefas.DataFrame[uint8]{Dims: []int{4}, Data: []uint8{1, 0, 0, 1}}),
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			loaded, err := elefas.LoadNumpyAnyDataFrame(bytes.NewReader(test.file))
+			if err != nil {
+				t.Fatalf("error loading: %v", err)
+			}
+			if !reflect.DeepEqual(loaded, test.expected) {
+				t.Errorf("loaded %v, expected %v", loaded, test.expected)
+			}
+		})
+	}
+}
+
+func TestNumpyDataFrameUnsupportedDtype(t *testing.T) {
+	for _, test := range []struct {
+		descr, kind string
+	}{
+		{"'<c8'", "complex"},
+		{"'<U3'", "unicode string"},
+		{"'|O'", "object"},
+		{"'<f2'", "floating point"},
+		{"[('x', '<f4'), ('y', '<f4')]", "structured"},
+	} {
+		file := rawNumpyFile(test.descr, false, "(1,)", binary.LittleEndian, []uint64{0})
+		_, err := elefas.LoadNumpyAnyDataFrame(bytes.NewReader(file))
+		if !errors.Is(err, elefas.ErrUnsupportedType) {
+			t.Errorf("loading dtype %s returned %v, expected %v", test.descr, err, elefas.ErrUnsupportedType)
+		} else if !strings.Contains(err.Error(), test.kind) {
+			t.Errorf("error %q for dtype %s does not mention %q", err, test.descr, test.kind)
+		}
+	}
+}
+
+func TestNumpyDataFrameHugeShape(t *testing.T) {
+	dir := t.TempDir()
+	for _, test := range []struct {
+		name, descr, shape string
+		expected           error
+	}{
+		{"negative", "'<f4'", "(-2, 3)", elefas.ErrInvalidNumpyFile},
+		// the element count fits an int, but not its size in bytes
+		{"overflow", "'<f8'", "(1152921504606846976,)", elefas.ErrInvalidNumpyFile},
+		{"overflow product", "'<u1'", "(4294967296, 4294967296)", elefas.ErrInvalidNumpyFile},
+		// the data fits an int, but is far larger than the file, which must not be allocated
+		{"truncated", "'<u1'", "(1099511627776,)", io.ErrUnexpectedEOF},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			file := rawNumpyFile(test.descr, false, test.shape, binary.LittleEndian, make([]byte, 64))
+			if _, err := elefas.LoadNumpyAnyDataFrame(bytes.NewReader(file)); !errors.Is(err, test.expected) {
+				t.Errorf("LoadNumpyAnyDataFrame returned %v, expected %v", err, test.expected)
+			}
+			if _, err := elefas.LoadNumpyDataFrame[float64](bytes.NewReader(file)); !errors.Is(err, test.expected) {
+				t.Errorf("LoadNumpyDataFrame returned %v, expected %v", err, test.expected)
+			}
+
+			path := filepath.Join(dir, test.name+".npy")
+			if err := os.WriteFile(path, file, 0o644); err != nil {
+				t.Fatal(err)
+			}
+			f, err := elefas.OpenNumpyFile(path)
+			if err != nil {
+				t.Fatalf("error opening: %v", err)
+			}
+			defer f.Close()
+			for _, mmap := range []func() error{
+				func() error { _, err := elefas.MmapNumpyDataFrame[uint8](f, ""); return err },
+				func() error { _, err := elefas.MmapNumpyDataFrame[float64](f, ""); return err },
+			} {
+				if err := mmap(); !errors.Is(err, test.expected) {
+					t.Errorf("MmapNumpyDataFrame returned %v, expected %v", err, test.expected)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,547 @@
+package elefas
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/YohayAiTe/elefas/internal/hdf5"
+)
+
+// KerasLayerConfig is the serialized form of a single Keras layer, as found in the config.json of a .keras archive.
+type KerasLayerConfig struct {
+	ClassName string
+	Name      string
+	Config    json.RawMessage
+}
+
+// KerasLayerFactory creates a layer from its Keras config and its weights, in the order Keras saves them. A nil
+// layer means the layer does nothing at inference time (e.g. Dropout), and its input is passed through.
+type KerasLayerFactory[T SizedNumber] func(config KerasLayerConfig, weights []DataFrame[T]) (Layer[T], error)
+
+// KerasMultiInputLayerFactory is like KerasLayerFactory, for layers that take several inputs, such as Add.
+type KerasMultiInputLayerFactory[T SizedNumber] func(config KerasLayerConfig, weights []DataFrame[T]) (
+	MultiInputLayer[T], error)
+
+type kerasFactoryKey struct {
+	className, dtype string
+}
+
+var (
+	kerasFactoriesMu sync.RWMutex
+	kerasFactories   = map[kerasFactoryKey]any{}
+)
+
+// RegisterKerasLayer makes a layer available to LoadKerasModel[T] under its Keras class name. The layer package
+// registers all of its layers, so importing it is enough for most models.
+func RegisterKerasLayer[T SizedNumber](className string, factory KerasLayerFactory[T]) {
+	kerasFactoriesMu.Lock()
+	defer kerasFactoriesMu.Unlock()
+	kerasFactories[kerasFactoryKey{className, sizedNumberToDtype[T]()}] = factory
+}
+
+// RegisterKerasMultiInputLayer is like RegisterKerasLayer, for layers that take several inputs.
+func RegisterKerasMultiInputLayer[T SizedNumber](className string, factory KerasMultiInputLayerFactory[T]) {
+	kerasFactoriesMu.Lock()
+	defer kerasFactoriesMu.Unlock()
+	kerasFactories[kerasFactoryKey{className, sizedNumberToDtype[T]()}] = factory
+}
+
+// kerasFactory returns the KerasLayerFactory[T] or KerasMultiInputLayerFactory[T] registered for className.
+func kerasFactory[T SizedNumber](className string) (any, error) {
+	kerasFactoriesMu.RLock()
+	defer kerasFactoriesMu.RUnlock()
+	factory, ok := kerasFactories[kerasFactoryKey{className, sizedNumberToDtype[T]()}]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrUnsupportedLayer, className)
+	}
+	return factory, nil
+}
+
+type kerasSerialized struct {
+	ClassName string          `json:"class_name"`
+	Config    json.RawMessage `json:"config"`
+}
+
+type kerasSequentialConfig struct {
+	Layers []kerasSerialized `json:"layers"`
+}
+
+type kerasFunctionalConfig struct {
+	Layers []struct {
+		kerasSerialized
+		Name         string            `json:"name"`
+		InboundNodes []json.RawMessage `json:"inbound_nodes"`
+	} `json:"layers"`
+	InputLayers  json.RawMessage `json:"input_layers"`
+	OutputLayers json.RawMessage `json:"output_layers"`
+}
+
+// LoadKerasModel loads a model saved by Keras 3 in the .keras format. Only the layers registered with
+// RegisterKerasLayer[T] are supported, so the layer package should be imported. The options are passed to NewModel.
+func LoadKerasModel[T SizedNumber](r io.Reader, options ...ModelOption) (*Model[T], error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return nil, err
+	}
+	configData, err := readZipFile(zr, "config.json")
+	if err != nil {
+		return nil, err
+	}
+	weightsData, err := readZipFile(zr, "model.weights.h5")
+	if err != nil {
+		return nil, err
+	}
+	weights, err := hdf5.Open(bytes.NewReader(weightsData))
+	if err != nil {
+		return nil, err
+	}
+
+	var config kerasSerialized
+	if err := json.Unmarshal(configData, &config); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidKerasModel, err)
+	}
+	b := &kerasBuilder[T]{weights: weights, options: options, classCounts: map[string]int{}}
+	switch config.ClassName {
+	case "Sequential":
+		err = b.buildSequential(config.Config)
+	case "Functional", "Model":
+		err = b.buildFunctional(config.Config)
+	default:
+		err = fmt.Errorf("%w: %s", ErrUnsupportedModel, config.ClassName)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := b.build(); err != nil {
+		return nil, err
+	}
+	return b.model, nil
+}
+
+func readZipFile(zr *zip.Reader, name string) ([]byte, error) {
+	f, err := zr.Open(name)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidKerasModel, err)
+	}
+	defer f.Close()
+	return io.ReadAll(f)
+}
+
+type kerasBuilder[T SizedNumber] struct {
+	model   *Model[T]
+	weights *hdf5.File
+	options []ModelOption
+
+	// inputShapes are the shapes of the model's inputs, where the config has them
+	inputShapes [][]int
+
+	// classCounts counts the layers seen so far of each class, which is how Keras names the weights groups
+	classCounts map[string]int
+}
+
+func (b *kerasBuilder[T]) buildSequential(rawConfig json.RawMessage) error {
+	var config kerasSequentialConfig
+	if err := json.Unmarshal(rawConfig, &config); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidKerasModel, err)
+	}
+
+	b.model = NewModel[T](1, 1, b.options...)
+	b.inputShapes = make([][]int, 1)
+	if len(config.Layers) > 0 {
+		b.inputShapes[0] = kerasInputShape(config.Layers[0].Config)
+	}
+	current := b.model.Input(0)
+	for _, l := range config.Layers {
+		if l.ClassName == "InputLayer" {
+			if err := b.setName(current, kerasLayerName(l.Config)); err != nil {
+				return err
+			}
+			continue
+		}
+		var err error
+		if current, err = b.addLayer(l, "", []*LayerData[T]{current}); err != nil {
+			return err
+		}
+	}
+	if current == b.model.Input(0) {
+		return fmt.Errorf("%w: model has no layers", ErrInvalidKerasModel)
+	}
+	b.model.SetOutput(current, 0)
+	return nil
+}
+
+func (b *kerasBuilder[T]) buildFunctional(rawConfig json.RawMessage) error {
+	var config kerasFunctionalConfig
+	if err := json.Unmarshal(rawConfig, &config); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidKerasModel, err)
+	}
+	inputs, err := kerasLayerReferences(config.InputLayers)
+	if err != nil {
+		return err
+	}
+	outputs, err := kerasLayerReferences(config.OutputLayers)
+	if err != nil {
+		return err
+	}
+
+	b.model = NewModel[T](len(inputs), len(outputs), b.options...)
+	b.inputShapes = make([][]int, len(inputs))
+	nodes := map[string]*LayerData[T]{}
+	inputIndices := map[string]int{}
+	for i, name := range inputs {
+		nodes[name] = b.model.Input(i)
+		inputIndices[name] = i
+		if err := b.setName(nodes[name], name); err != nil {
+			return err
+		}
+	}
+	for _, l := range config.Layers {
+		name := l.Name
+		if name == "" {
+			name = kerasLayerName(l.Config)
+		}
+		if l.ClassName == "InputLayer" {
+			i, ok := inputIndices[name]
+			if !ok {
+				return fmt.Errorf("%w: input layer %s is not a model input", ErrInvalidKerasModel, name)
+			}
+			b.inputShapes[i] = kerasInputShape(l.Config)
+			continue
+		}
+		if len(l.InboundNodes) != 1 {
+			return fmt.Errorf("%w: layer %s is called %d times", ErrUnsupportedModel, name, len(l.InboundNodes))
+		}
+		inbound, err := kerasInboundLayers(l.InboundNodes[0])
+		if err != nil {
+			return err
+		}
+		inputs := make([]*LayerData[T], len(inbound))
+		for i, inboundName := range inbound {
+			var ok bool
+			if inputs[i], ok = nodes[inboundName]; !ok {
+				return fmt.Errorf("%w: layer %s is used before it is defined", ErrInvalidKerasModel, inboundName)
+			}
+		}
+		if nodes[name], err = b.addLayer(l.kerasSerialized, name, inputs); err != nil {
+			return err
+		}
+	}
+
+	for i, name := range outputs {
+		node, ok := nodes[name]
+		if !ok {
+			return fmt.Errorf("%w: unknown output layer %s", ErrInvalidKerasModel, name)
+		}
+		if node.layer == nil && node.multiLayer == nil {
+			return fmt.Errorf("%w: output %d is a model input", ErrUnsupportedModel, i)
+		}
+		b.model.SetOutput(node, i)
+	}
+	return nil
+}
+
+// build infers the shapes of the model's nodes, if the shapes of all its inputs are known, which also checks that
+// the layers' weights fit together.
+func (b *kerasBuilder[T]) build() error {
+	for _, shape := range b.inputShapes {
+		if shape == nil {
+			return nil
+		}
+	}
+	if err := b.model.Build(b.inputShapes...); err != nil {
+		return fmt.Errorf("%w: %w", ErrInvalidKerasModel, err)
+	}
+	return nil
+}
+
+// addLayer adds the layer serialized in l after inputs, followed by its activation if it has one, and returns the
+// last node added (or the input, if the layer is passed through).
+func (b *kerasBuilder[T]) addLayer(l kerasSerialized, name string, inputs []*LayerData[T]) (*LayerData[T], error) {
+	if name == "" {
+		name = kerasLayerName(l.Config)
+	}
+	config := KerasLayerConfig{ClassName: l.ClassName, Name: name, Config: l.Config}
+
+	weights, err := b.layerWeights(l.ClassName)
+	if err != nil {
+		return nil, err
+	}
+	factory, err := kerasFactory[T](l.ClassName)
+	if err != nil {
+		return nil, err
+	}
+	var input *LayerData[T]
+	switch factory := factory.(type) {
+	case KerasLayerFactory[T]:
+		if len(inputs) != 1 {
+			return nil, fmt.Errorf("%w: layer %s has %d inputs", ErrUnsupportedLayer, name, len(inputs))
+		}
+		layer, err := factory(config, weights)
+		if err != nil {
+			return nil, fmt.Errorf("layer %s: %w", name, err)
+		}
+		input = inputs[0]
+		if layer == nil {
+			break
+		}
+		input = b.model.AddLayer(layer, input)
+		if err := b.setName(input, name); err != nil {
+			return nil, err
+		}
+	case KerasMultiInputLayerFactory[T]:
+		layer, err := factory(config, weights)
+		if err != nil {
+			return nil, fmt.Errorf("layer %s: %w", name, err)
+		}
+		input = b.model.AddMultiInputLayer(layer, inputs...)
+		if err := b.setName(input, name); err != nil {
+			return nil, err
+		}
+	}
+
+	if l.ClassName == "Activation" {
+		return input, nil
+	}
+	activation, err := b.activation(config)
+	if err != nil {
+		return nil, err
+	}
+	if activation != nil {
+		input = b.model.AddLayer(activation, input)
+		// the activation keeps its generated name if a layer of the model is already named like this
+		if name != "" && b.model.Layer(name+"_activation") == nil {
+			input.Named(name + "_activation")
+		}
+	}
+	return input, nil
+}
+
+// setName names node after its Keras layer. Keras layer names are unique, so no other node should have been named
+// the same way.
+func (b *kerasBuilder[T]) setName(node *LayerData[T], name string) error {
+	if name == "" {
+		return nil
+	}
+	if other := b.model.Layer(name); other != nil && other != node && other.namePrefix == "" {
+		return fmt.Errorf("%w: there are several layers named %s", ErrInvalidKerasModel, name)
+	}
+	node.Named(name)
+	return nil
+}
+
+// activation returns the layer for the "activation" argument many Keras layers take, if it is set.
+func (b *kerasBuilder[T]) activation(config KerasLayerConfig) (Layer[T], error) {
+	var activationConfig struct {
+		Activation json.RawMessage `json:"activation"`
+	}
+	if err := json.Unmarshal(config.Config, &activationConfig); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidKerasModel, err)
+	}
+	if activationConfig.Activation == nil {
+		return nil, nil
+	}
+	var name string
+	if err := json.Unmarshal(activationConfig.Activation, &name); err != nil {
+		return nil, fmt.Errorf("%w: activation of layer %s is not a builtin activation", ErrUnsupportedLayer,
+			config.Name)
+	}
+	if name == "linear" {
+		return nil, nil
+	}
+
+	factory, err := kerasFactory[T]("Activation")
+	if err != nil {
+		return nil, err
+	}
+	layer, err := factory.(KerasLayerFactory[T])(KerasLayerConfig{
+		ClassName: "Activation",
+		Name:      config.Name + "_activation",
+		Config:    json.RawMessage(`{"activation":` + string(activationConfig.Activation) + `}`),
+	}, nil)
+	if err != nil {
+		return nil, fmt.Errorf("layer %s: %w", config.Name, err)
+	}
+	return layer, nil
+}
+
+// layerWeights loads the weights of the next layer of the given class. Keras 3 stores them under
+// layers/<class>[_<n>]/vars/<i>, where the group is named after the layer's class and its index among the model's
+// layers of the same class, not after the layer's name.
+func (b *kerasBuilder[T]) layerWeights(className string) ([]DataFrame[T], error) {
+	groupName := kerasSnakeCase(className)
+	if count := b.classCounts[className]; count > 0 {
+		groupName += "_" + strconv.Itoa(count)
+	}
+	b.classCounts[className]++
+
+	path := "layers/" + groupName + "/vars"
+	group, err := b.weights.OpenGroup(path)
+	if errors.Is(err, hdf5.ErrNotFound) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	names := map[string]bool{}
+	for _, name := range group.Names() {
+		names[name] = true
+	}
+
+	var weights []DataFrame[T]
+	for i := 0; names[strconv.Itoa(i)]; i++ {
+		ds, err := b.weights.OpenDataset(path + "/" + strconv.Itoa(i))
+		if err != nil {
+			return nil, err
+		}
+		df, err := loadHDF5AnyDataFrame(ds)
+		if err != nil {
+			return nil, err
+		}
+		if df.Dtype == "" {
+			return nil, fmt.Errorf("%w: weight %s/%d is not numeric", ErrInvalidKerasModel, path, i)
+		}
+		weight, err := As[T](df)
+		if err != nil {
+			weight = CastAnyDf[T](df)
+		}
+		weights = append(weights, weight)
+	}
+	return weights, nil
+}
+
+// kerasInputShape returns the batch shape in the config of an InputLayer, or of the first layer of a Sequential model
+// in older versions, with -1 for the dimensions that are None. It returns nil if the config has no shape.
+func kerasInputShape(config json.RawMessage) []int {
+	var c struct {
+		BatchShape      []*int `json:"batch_shape"`
+		BatchInputShape []*int `json:"batch_input_shape"`
+	}
+	_ = json.Unmarshal(config, &c)
+	shape := c.BatchShape
+	if shape == nil {
+		shape = c.BatchInputShape
+	}
+	if shape == nil {
+		return nil
+	}
+	dims := make([]int, len(shape))
+	for i, d := range shape {
+		dims[i] = -1
+		if d != nil {
+			dims[i] = *d
+		}
+	}
+	return dims
+}
+
+func kerasLayerName(config json.RawMessage) string {
+	var named struct {
+		Name string `json:"name"`
+	}
+	_ = json.Unmarshal(config, &named)
+	return named.Name
+}
+
+// kerasLayerReferences parses the input_layers/output_layers of a functional model, which is either a single
+// [name, node index, tensor index] triplet or a list of them.
+func kerasLayerReferences(raw json.RawMessage) ([]string, error) {
+	var single []json.RawMessage
+	if err := json.Unmarshal(raw, &single); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidKerasModel, err)
+	}
+	var name string
+	if len(single) > 0 && json.Unmarshal(single[0], &name) == nil {
+		return []string{name}, nil
+	}
+
+	names := make([]string, len(single))
+	for i, ref := range single {
+		var triplet []json.RawMessage
+		if err := json.Unmarshal(ref, &triplet); err != nil || len(triplet) == 0 {
+			return nil, fmt.Errorf("%w: bad layer reference %s", ErrInvalidKerasModel, ref)
+		}
+		if err := json.Unmarshal(triplet[0], &names[i]); err != nil {
+			return nil, fmt.Errorf("%w: bad layer reference %s", ErrInvalidKerasModel, ref)
+		}
+	}
+	return names, nil
+}
+
+// kerasInboundLayers returns the names of the layers whose outputs are the arguments of an inbound node, in order.
+// Keras 3 serializes a node as {"args": [...], "kwargs": {...}} with the tensors in args, while older versions
+// serialize it as a list of [name, node index, tensor index, kwargs].
+func kerasInboundLayers(node json.RawMessage) ([]string, error) {
+	var call struct {
+		Args []json.RawMessage `json:"args"`
+	}
+	if err := json.Unmarshal(node, &call); err == nil {
+		var names []string
+		for _, arg := range call.Args {
+			names = appendKerasTensorHistories(names, arg)
+		}
+		return names, nil
+	}
+
+	var legacy [][]json.RawMessage
+	if err := json.Unmarshal(node, &legacy); err != nil {
+		return nil, fmt.Errorf("%w: bad inbound node %s", ErrInvalidKerasModel, node)
+	}
+	names := make([]string, len(legacy))
+	for i, ref := range legacy {
+		if len(ref) == 0 || json.Unmarshal(ref[0], &names[i]) != nil {
+			return nil, fmt.Errorf("%w: bad inbound node %s", ErrInvalidKerasModel, node)
+		}
+	}
+	return names, nil
+}
+
+func appendKerasTensorHistories(names []string, arg json.RawMessage) []string {
+	var list []json.RawMessage
+	if json.Unmarshal(arg, &list) == nil {
+		for _, elem := range list {
+			names = appendKerasTensorHistories(names, elem)
+		}
+		return names
+	}
+
+	var tensor struct {
+		ClassName string `json:"class_name"`
+		Config    struct {
+			KerasHistory []json.RawMessage `json:"keras_history"`
+		} `json:"config"`
+	}
+	if json.Unmarshal(arg, &tensor) != nil || tensor.ClassName != "__keras_tensor__" ||
+		len(tensor.Config.KerasHistory) == 0 {
+		return names
+	}
+	var name string
+	if json.Unmarshal(tensor.Config.KerasHistory[0], &name) == nil {
+		names = append(names, name)
+	}
+	return names
+}
+
+var (
+	snakeCaseWords  = regexp.MustCompile(`(.)([A-Z][a-z]+)`)
+	snakeCaseCamels = regexp.MustCompile(`([a-z])([A-Z])`)
+	nonWordChars    = regexp.MustCompile(`\W+`)
+)
+
+// kerasSnakeCase converts a class name the same way keras.src.utils.naming.to_snake_case does.
+func kerasSnakeCase(name string) string {
+	name = nonWordChars.ReplaceAllString(name, "")
+	name = snakeCaseWords.ReplaceAllString(name, "${1}_${2}")
+	name = snakeCaseCamels.ReplaceAllString(name, "${1}_${2}")
+	return strings.ToLower(name)
+}
@@ -0,0 +1,110 @@
+package elefas_test
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"reflect"
+	"testing"
+
+	"github.com/YohayAiTe/elefas"
+	_ "github.com/YohayAiTe/elefas/layer"
+)
+
+// loadKerasTestModel loads testdata/keras/<name>.keras, with the inputs and the outputs of testdata/keras/<name>.npz.
+// Both are written by testdata/make_keras.py.
+func loadKerasTestModel(t *testing.T, name string) (*elefas.Model[float32], []elefas.DataFrame[float32],
+	[]elefas.DataFrame[float32]) {
+
+	f, err := os.Open("testdata/keras/" + name + ".keras")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	model, err := elefas.LoadKerasModel[float32](f)
+	if err != nil {
+		t.Fatalf("error loading %s: %v", name, err)
+	}
+
+	npz, err := os.Open("testdata/keras/" + name + ".npz")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer npz.Close()
+	dfs, _, err := elefas.LoadNumpyDataFrameMap[float32](npz)
+	if err != nil {
+		t.Fatalf("error loading the data of %s: %v", name, err)
+	}
+	var inputs, outputs []elefas.DataFrame[float32]
+	for i := 0; dfs[fmt.Sprintf("input_%d", i)].Data != nil; i++ {
+		inputs = append(inputs, dfs[fmt.Sprintf("input_%d", i)])
+	}
+	for i := 0; dfs[fmt.Sprintf("output_%d", i)].Data != nil; i++ {
+		outputs = append(outputs, dfs[fmt.Sprintf("output_%d", i)])
+	}
+	return model, inputs, outputs
+}
+
+func TestLoadKerasModel(t *testing.T) {
+	for _, test := range []struct {
+		name string
+		// layers are the names of some of the model's nodes, which are named after the Keras layers, and the shapes the
+		// loader infers for them from the shapes of the model's inputs
+		layers map[string][]int
+	}{
+		{"sequential", map[string][]int{"input_layer": {-1, 2, 3}, "flatten": {-1, 6}, "dense": {-1, 4},
+			"dense_activation": {-1, 4}, "dense_1": {-1, 3}}},
+		{"functional", map[string][]int{"input_a": {-1, 4}, "input_b": {-1, 3}, "encoder_a": {-1, 5},
+			"encoder_a_activation": {-1, 5}, "sum": {-1, 5}, "joined": {-1, 9}, "head": {-1, 2}}},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			model, inputs, expected := loadKerasTestModel(t, test.name)
+			for name, shape := range test.layers {
+				if node := model.Layer(name); node == nil {
+					t.Errorf("the model has no layer named %s", name)
+				} else if !reflect.DeepEqual(node.Shape(), shape) {
+					t.Errorf("%s has shape %v, expected %v", name, node.Shape(), shape)
+				}
+			}
+
+			outputs, err := model.PredictE(inputs...)
+			if err != nil {
+				t.Fatalf("error predicting: %v", err)
+			}
+			if len(outputs) != len(expected) {
+				t.Fatalf("the model has %d outputs, expected %d", len(outputs), len(expected))
+			}
+			for i, output := range outputs {
+				if !reflect.DeepEqual(output.Dims, expected[i].Dims) {
+					t.Errorf("output %d has shape %v, expected %v", i, output.Dims, expected[i].Dims)
+					continue
+				}
+				for j, v := range output.Data {
+					if math.Abs(float64(v-expected[i].Data[j])) > 1e-5 {
+						t.Errorf("output %d is %v, expected %v", i, output.Data, expected[i].Data)
+						break
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestLoadKerasModelInvalid(t *testing.T) {
+	for _, name := range []string{
+		// the kernel of the Dense layer is made of strings
+		"string_weights",
+		// the kernel of the Dense layer does not fit the model's input
+		"mismatched_weights",
+	} {
+		f, err := os.Open("testdata/keras/" + name + ".keras")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := elefas.LoadKerasModel[float32](f); !errors.Is(err, elefas.ErrInvalidKerasModel) {
+			t.Errorf("loading %s returned %v, expected %v", name, err, elefas.ErrInvalidKerasModel)
+		}
+		f.Close()
+	}
+}
@@ -1,6 +1,7 @@
 package layer
 
 import (
+	"fmt"
 	"math"
 
 	"github.com/YohayAiTe/elefas"
@@ -19,28 +20,49 @@ func NewReLUActivation[T elefas.SizedNumber]() *ReLUActivation[T] {
 }
 
 func (ra *ReLUActivation[T]) Apply(input elefas.DataFrame[T]) elefas.DataFrame[T] {
-	output := elefas.MakeDataFrame[T](input.Dims)
-	for i := 0; i < output.TotalSize(); i++ {
+	return must(applyInto[T](ra, input))
+}
+
+func (ra *ReLUActivation[T]) ApplyInto(dst, input elefas.DataFrame[T]) error {
+	for i := 0; i < dst.TotalSize(); i++ {
 		if ra.MaxValue <= input.Data[i] {
-			output.Data[i] = ra.MaxValue
+			dst.Data[i] = ra.MaxValue
 		} else if ra.Threshold <= input.Data[i] {
-			output.Data[i] = input.Data[i]
+			dst.Data[i] = input.Data[i]
 		} else {
-			output.Data[i] = (input.Data[i] - ra.Threshold) * ra.NegativeSlope
+			dst.Data[i] = (input.Data[i] - ra.Threshold) * ra.NegativeSlope
 		}
 	}
+	return nil
+}
+
+func (ra *ReLUActivation[T]) OutputShape(inputShape []int) ([]int, error) {
+	return elementwiseShape(inputShape)
+}
 
-	return output
+func (ra *ReLUActivation[T]) SupportsInPlace() bool {
+	return true
 }
 
 type SigmoidActivation[T elefas.SizedNumber] struct{}
 
 func (sa *SigmoidActivation[T]) Apply(input elefas.DataFrame[T]) elefas.DataFrame[T] {
-	output := elefas.MakeDataFrame[T](input.Dims)
-	for i := 0; i < output.TotalSize(); i++ {
-		output.Data[i] = T(1 / (1 + math.Exp(-float64(input.Data[i]))))
+	return must(applyInto[T](sa, input))
+}
+
+func (sa *SigmoidActivation[T]) ApplyInto(dst, input elefas.DataFrame[T]) error {
+	for i := 0; i < dst.TotalSize(); i++ {
+		dst.Data[i] = T(1 / (1 + math.Exp(-float64(input.Data[i]))))
 	}
-	return output
+	return nil
+}
+
+func (sa *SigmoidActivation[T]) OutputShape(inputShape []int) ([]int, error) {
+	return elementwiseShape(inputShape)
+}
+
+func (sa *SigmoidActivation[T]) SupportsInPlace() bool {
+	return true
 }
 
 type SoftmaxActivation[T elefas.SizedNumber] struct {
@@ -48,14 +70,41 @@ type SoftmaxActivation[T elefas.SizedNumber] struct {
 }
 
 func (sa *SoftmaxActivation[T]) Apply(input elefas.DataFrame[T]) elefas.DataFrame[T] {
+	return must(sa.ApplyE(input))
+}
+
+func (sa *SoftmaxActivation[T]) OutputShape(inputShape []int) ([]int, error) {
+	if _, err := sa.axis(inputShape); err != nil {
+		return nil, err
+	}
+	return elementwiseShape(inputShape)
+}
+
+func (sa *SoftmaxActivation[T]) SupportsInPlace() bool {
+	return true
+}
+
+func (sa *SoftmaxActivation[T]) axis(inputShape []int) (int, error) {
 	axis := sa.Axis
-	if axis >= len(input.Dims) {
-		panic("axis is greater than the number of dimensions")
+	if axis < 0 {
+		axis += len(inputShape)
+	}
+	if axis < 0 || axis >= len(inputShape) {
+		return 0, &elefas.ShapeError{Actual: inputShape,
+			Reason: fmt.Sprintf("softmax axis %d is out of range", sa.Axis)}
 	}
-	if axis == -1 {
-		axis = len(input.Dims) - 1
+	return axis, nil
+}
+
+func (sa *SoftmaxActivation[T]) ApplyE(input elefas.DataFrame[T]) (elefas.DataFrame[T], error) {
+	return applyInto[T](sa, input)
+}
+
+func (sa *SoftmaxActivation[T]) ApplyInto(dst, input elefas.DataFrame[T]) error {
+	axis, err := sa.axis(input.Dims)
+	if err != nil {
+		return err
 	}
-	output := elefas.MakeDataFrame[T](input.Dims)
 
 	postIdxMax := 1
 	for i := len(input.Dims) - 1; i > axis; i-- {
@@ -69,65 +118,109 @@ func (sa *SoftmaxActivation[T]) Apply(input elefas.DataFrame[T]) elefas.DataFram
 			for axisIdx := 0; axisIdx < input.Dims[axis]; axisIdx++ {
 				idx := preIdx + postIdx + axisIdx*postIdxMax
 				value := T(math.Exp(float64(input.Data[idx])))
-				output.Data[idx] = value
+				dst.Data[idx] = value
 				sum += value
 			}
 			for axisIdx := 0; axisIdx < input.Dims[axis]; axisIdx++ {
 				idx := preIdx + postIdx + axisIdx*postIdxMax
-				output.Data[idx] /= sum
+				dst.Data[idx] /= sum
 			}
 		}
 	}
-	return output
+	return nil
 }
 
 type SoftplusActivation[T elefas.SizedNumber] struct{}
 
 func (sa *SoftplusActivation[T]) Apply(input elefas.DataFrame[T]) elefas.DataFrame[T] {
-	output := elefas.MakeDataFrame[T](input.Dims)
-	for i := 0; i < output.TotalSize(); i++ {
-		output.Data[i] = T(math.Log(math.Exp(float64(input.Data[i])) + 1))
+	return must(applyInto[T](sa, input))
+}
+
+func (sa *SoftplusActivation[T]) ApplyInto(dst, input elefas.DataFrame[T]) error {
+	for i := 0; i < dst.TotalSize(); i++ {
+		dst.Data[i] = T(math.Log(math.Exp(float64(input.Data[i])) + 1))
 	}
-	return output
+	return nil
+}
+
+func (sa *SoftplusActivation[T]) OutputShape(inputShape []int) ([]int, error) {
+	return elementwiseShape(inputShape)
+}
+
+func (sa *SoftplusActivation[T]) SupportsInPlace() bool {
+	return true
 }
 
 type SoftsignActivation[T elefas.SizedNumber] struct{}
 
 func (sa *SoftsignActivation[T]) Apply(input elefas.DataFrame[T]) elefas.DataFrame[T] {
-	output := elefas.MakeDataFrame[T](input.Dims)
-	for i := 0; i < output.TotalSize(); i++ {
+	return must(applyInto[T](sa, input))
+}
+
+func (sa *SoftsignActivation[T]) ApplyInto(dst, input elefas.DataFrame[T]) error {
+	for i := 0; i < dst.TotalSize(); i++ {
 		a := input.Data[i]
 		if a < 0 {
 			a = -a
 		}
-		output.Data[i] = a / (a + 1)
+		dst.Data[i] = a / (a + 1)
 	}
-	return output
+	return nil
+}
+
+func (sa *SoftsignActivation[T]) OutputShape(inputShape []int) ([]int, error) {
+	return elementwiseShape(inputShape)
+}
+
+func (sa *SoftsignActivation[T]) SupportsInPlace() bool {
+	return true
 }
 
 type TanhActivation[T elefas.SizedNumber] struct{}
 
 func (ta *TanhActivation[T]) Apply(input elefas.DataFrame[T]) elefas.DataFrame[T] {
-	output := elefas.MakeDataFrame[T](input.Dims)
-	for i := 0; i < output.TotalSize(); i++ {
-		output.Data[i] = T(math.Tanh(float64(input.Data[i])))
+	return must(applyInto[T](ta, input))
+}
+
+func (ta *TanhActivation[T]) ApplyInto(dst, input elefas.DataFrame[T]) error {
+	for i := 0; i < dst.TotalSize(); i++ {
+		dst.Data[i] = T(math.Tanh(float64(input.Data[i])))
 	}
-	return output
+	return nil
+}
+
+func (ta *TanhActivation[T]) OutputShape(inputShape []int) ([]int, error) {
+	return elementwiseShape(inputShape)
+}
+
+func (ta *TanhActivation[T]) SupportsInPlace() bool {
+	return true
 }
 
 type SeluActivation[T elefas.SizedNumber] struct{}
 
 func (sa *SeluActivation[T]) Apply(input elefas.DataFrame[T]) elefas.DataFrame[T] {
+	return must(applyInto[T](sa, input))
+}
+
+func (sa *SeluActivation[T]) ApplyInto(dst, input elefas.DataFrame[T]) error {
 	const scale, alpha = 1.05070098, 1.67326324
-	output := elefas.MakeDataFrame[T](input.Dims)
-	for i := 0; i < output.TotalSize(); i++ {
+	for i := 0; i < dst.TotalSize(); i++ {
 		if input.Data[i] >= 0 {
-			output.Data[i] = T(scale * float64(input.Data[i]))
+			dst.Data[i] = T(scale * float64(input.Data[i]))
 		} else {
-			output.Data[i] = T(scale * alpha * (math.Exp(float64(input.Data[i]) - 1)))
+			dst.Data[i] = T(scale * alpha * (math.Exp(float64(input.Data[i]) - 1)))
 		}
 	}
-	return output
+	return nil
+}
+
+func (sa *SeluActivation[T]) OutputShape(inputShape []int) ([]int, error) {
+	return elementwiseShape(inputShape)
+}
+
+func (sa *SeluActivation[T]) SupportsInPlace() bool {
+	return true
 }
 
 type EluActivation[T elefas.SizedNumber] struct {
@@ -135,25 +228,47 @@ type EluActivation[T elefas.SizedNumber] struct {
 }
 
 func (ea *EluActivation[T]) Apply(input elefas.DataFrame[T]) elefas.DataFrame[T] {
-	output := elefas.MakeDataFrame[T](input.Dims)
-	for i := 0; i < output.TotalSize(); i++ {
+	return must(applyInto[T](ea, input))
+}
+
+func (ea *EluActivation[T]) ApplyInto(dst, input elefas.DataFrame[T]) error {
+	for i := 0; i < dst.TotalSize(); i++ {
 		if input.Data[i] >= 0 {
-			output.Data[i] = input.Data[i]
+			dst.Data[i] = input.Data[i]
 		} else {
-			output.Data[i] = ea.Alpha * T((math.Exp(float64(input.Data[i]) - 1)))
+			dst.Data[i] = ea.Alpha * T((math.Exp(float64(input.Data[i]) - 1)))
 		}
 	}
-	return output
+	return nil
+}
+
+func (ea *EluActivation[T]) OutputShape(inputShape []int) ([]int, error) {
+	return elementwiseShape(inputShape)
+}
+
+func (ea *EluActivation[T]) SupportsInPlace() bool {
+	return true
 }
 
 type ExponentialActivation[T elefas.SizedNumber] struct{}
 
 func (ea *ExponentialActivation[T]) Apply(input elefas.DataFrame[T]) elefas.DataFrame[T] {
-	output := elefas.MakeDataFrame[T](input.Dims)
-	for i := 0; i < output.TotalSize(); i++ {
-		output.Data[i] = T(math.Exp(float64(input.Data[i])))
+	return must(applyInto[T](ea, input))
+}
+
+func (ea *ExponentialActivation[T]) ApplyInto(dst, input elefas.DataFrame[T]) error {
+	for i := 0; i < dst.TotalSize(); i++ {
+		dst.Data[i] = T(math.Exp(float64(input.Data[i])))
 	}
-	return output
+	return nil
+}
+
+func (ea *ExponentialActivation[T]) OutputShape(inputShape []int) ([]int, error) {
+	return elementwiseShape(inputShape)
+}
+
+func (ea *ExponentialActivation[T]) SupportsInPlace() bool {
+	return true
 }
 
 //TODO: add 'activation layers' from Keras: LeakyReLU, PReLU, and ThresholdedReLU.
@@ -0,0 +1,378 @@
+package layer
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/YohayAiTe/elefas"
+)
+
+// Padding is how the convolution and pooling layers pad their inputs, named like in Keras.
+type Padding string
+
+const (
+	// PaddingValid does not pad the input, so the output is smaller than the input.
+	PaddingValid Padding = "valid"
+	// PaddingSame pads the input evenly, with the extra padding at the end, so that the output has the size of the
+	// input divided by the strides, rounded up.
+	PaddingSame Padding = "same"
+	// PaddingCausal pads only the start of the input, so that no output depends on later inputs; only Conv1D takes
+	// it.
+	PaddingCausal Padding = "causal"
+)
+
+// DataFormat is where the channels are in the inputs of the convolution and pooling layers, named like in Keras.
+type DataFormat string
+
+const (
+	// ChannelsLast is the (batch, spatial dimensions..., channels) layout.
+	ChannelsLast DataFormat = "channels_last"
+	// ChannelsFirst is the (batch, channels, spatial dimensions...) layout.
+	ChannelsFirst DataFormat = "channels_first"
+)
+
+// ConvOptions are the arguments of Keras's convolution layers besides their weights. Strides and DilationRate have
+// one value per spatial dimension, and are all 1 if nil. The zero value stands for Keras's defaults: valid padding,
+// strides and dilation rate of 1, a single group, and channels last.
+type ConvOptions struct {
+	Padding      Padding
+	Strides      []int
+	DilationRate []int
+	// Groups splits the input channels and the filters into groups convolved separately, as in Keras.
+	Groups     int
+	DataFormat DataFormat
+	// OutputPadding is only for the transposed convolutions: how much larger than Keras's default their output is in
+	// each spatial dimension, less than the strides. Like Keras's None, nil keeps the default output size.
+	OutputPadding []int
+}
+
+// convolution is the core of the convolution layers, for any number of spatial dimensions.
+type convolution[T elefas.SizedNumber] struct {
+	kernelSize, strides, dilationRate []int
+	inChannels, outChannels, groups   int
+	padding                           Padding
+	channelsFirst                     bool
+
+	// kernel has Keras's layout: the spatial dimensions, then the input channels of a group, then the filters
+	kernel, bias elefas.DataFrame[T]
+}
+
+func newConvolution[T elefas.SizedNumber](rank int, kernel, bias elefas.DataFrame[T], options ConvOptions) (
+	convolution[T], error) {
+
+	if kernel.DimCount() != rank+2 {
+		return convolution[T]{}, fmt.Errorf("%dD convolution kernel must have %d dimensions, got shape %v", rank,
+			rank+2, kernel.Dims)
+	}
+	c := convolution[T]{
+		kernelSize:  append([]int{}, kernel.Dims[:rank]...),
+		outChannels: kernel.Dim(rank + 1),
+		groups:      options.Groups,
+		padding:     options.Padding,
+		kernel:      kernel, bias: bias,
+	}
+	if bias.DimCount() != 1 || bias.Dim(0) != c.outChannels {
+		return convolution[T]{}, fmt.Errorf("convolution bias has shape %v, expected (%d,)", bias.Dims,
+			c.outChannels)
+	}
+	if c.groups == 0 {
+		c.groups = 1
+	}
+	if options.OutputPadding != nil {
+		return convolution[T]{}, fmt.Errorf("output padding is only for transposed convolutions")
+	}
+	if c.groups < 0 || c.outChannels%c.groups != 0 {
+		return convolution[T]{}, fmt.Errorf("%d filters cannot be split into %d groups", c.outChannels, c.groups)
+	}
+	c.inChannels = kernel.Dim(rank) * c.groups
+
+	var err error
+	if c.strides, err = convVector(options.Strides, rank, "strides"); err != nil {
+		return convolution[T]{}, err
+	}
+	if c.dilationRate, err = convVector(options.DilationRate, rank, "dilation rate"); err != nil {
+		return convolution[T]{}, err
+	}
+	switch c.padding {
+	case "":
+		c.padding = PaddingValid
+	case PaddingValid, PaddingSame:
+	case PaddingCausal:
+		if rank != 1 {
+			return convolution[T]{}, fmt.Errorf("causal padding is only for 1D convolutions")
+		}
+	default:
+		return convolution[T]{}, fmt.Errorf("unknown padding %q", c.padding)
+	}
+	if c.channelsFirst, err = isChannelsFirst(options.DataFormat); err != nil {
+		return convolution[T]{}, err
+	}
+	return c, nil
+}
+
+// isChannelsFirst reports whether the data format is ChannelsFirst, where an empty one is ChannelsLast.
+func isChannelsFirst(dataFormat DataFormat) (bool, error) {
+	switch dataFormat {
+	case "", ChannelsLast:
+		return false, nil
+	case ChannelsFirst:
+		return true, nil
+	}
+	return false, fmt.Errorf("unknown data format %q", dataFormat)
+}
+
+// convVector returns the strides or dilation rate of a convolution of the given rank, which are all 1 if v is nil.
+func convVector(v []int, rank int, name string) ([]int, error) {
+	if v == nil {
+		v = make([]int, rank)
+		for i := range v {
+			v[i] = 1
+		}
+		return v, nil
+	}
+	if len(v) != rank {
+		return nil, fmt.Errorf("%dD convolution takes %d %s, got %v", rank, rank, name, v)
+	}
+	for _, n := range v {
+		if n < 1 {
+			return nil, fmt.Errorf("convolution %s must be positive, got %v", name, v)
+		}
+	}
+	return append([]int{}, v...), nil
+}
+
+func (c convolution[T]) ParamCount() int {
+	return c.kernel.TotalSize() + c.bias.TotalSize()
+}
+
+func (c convolution[T]) Apply(input elefas.DataFrame[T]) elefas.DataFrame[T] {
+	return must(c.ApplyE(input))
+}
+
+func (c convolution[T]) ApplyE(input elefas.DataFrame[T]) (elefas.DataFrame[T], error) {
+	return c.ApplyContext(context.Background(), input)
+}
+
+func (c convolution[T]) OutputShape(inputShape []int) ([]int, error) {
+	rank := len(c.kernelSize)
+	channelAxis := rank + 1
+	if c.channelsFirst {
+		channelAxis = 1
+	}
+	if len(inputShape) != rank+2 || !dimsMatch(inputShape[channelAxis], c.inChannels) {
+		expected := anyShape(rank + 2)
+		expected[channelAxis] = c.inChannels
+		return nil, &elefas.ShapeError{Expected: expected, Actual: inputShape,
+			Reason: fmt.Sprintf("%dD convolution's input does not match its kernel", rank)}
+	}
+
+	outputShape := append([]int{}, inputShape...)
+	outputShape[channelAxis] = c.outChannels
+	spatialAxis := 1
+	if c.channelsFirst {
+		spatialAxis = 2
+	}
+	for i := 0; i < rank; i++ {
+		size := inputShape[spatialAxis+i]
+		if size == -1 {
+			continue
+		}
+		if c.padding == PaddingValid && size < c.dilatedKernelSize(i) {
+			return nil, &elefas.ShapeError{Actual: inputShape,
+				Reason: fmt.Sprintf("%dD convolution's input is smaller than its kernel of size %v, dilated by %v",
+					rank, c.kernelSize, c.dilationRate)}
+		}
+		outputShape[spatialAxis+i] = c.outputSize(i, size)
+	}
+	return outputShape, nil
+}
+
+// dilatedKernelSize returns the size of the kernel in the i-th spatial dimension, with the gaps of the dilation.
+func (c convolution[T]) dilatedKernelSize(i int) int {
+	return (c.kernelSize[i]-1)*c.dilationRate[i] + 1
+}
+
+// outputSize returns the size of the output in the i-th spatial dimension, for an input of the given size.
+func (c convolution[T]) outputSize(i, size int) int {
+	if c.padding != PaddingValid {
+		return (size + c.strides[i] - 1) / c.strides[i]
+	}
+	return (size-c.dilatedKernelSize(i))/c.strides[i] + 1
+}
+
+// padBefore returns the padding before the input in the i-th spatial dimension; with PaddingSame, Keras puts the odd
+// padding after.
+func (c convolution[T]) padBefore(i, inputSize, outputSize int) int {
+	switch c.padding {
+	case PaddingValid:
+		return 0
+	case PaddingCausal:
+		return c.dilatedKernelSize(i) - 1
+	}
+	total := (outputSize-1)*c.strides[i] + c.dilatedKernelSize(i) - inputSize
+	if total < 0 {
+		return 0
+	}
+	return total / 2
+}
+
+func (c convolution[T]) ApplyContext(ctx context.Context, input elefas.DataFrame[T]) (elefas.DataFrame[T], error) {
+	outputDims, err := c.OutputShape(input.Dims)
+	if err != nil {
+		return elefas.DataFrame[T]{}, err
+	}
+	output := elefas.MakeDataFrame[T](outputDims)
+	if err := c.ApplyIntoContext(ctx, output, input); err != nil {
+		return elefas.DataFrame[T]{}, err
+	}
+	return output, nil
+}
+
+func (c convolution[T]) ApplyInto(dst, input elefas.DataFrame[T]) error {
+	return c.ApplyIntoContext(context.Background(), dst, input)
+}
+
+// ApplyIntoContext checks ctx between the samples of the batch.
+func (c convolution[T]) ApplyIntoContext(ctx context.Context, dst, input elefas.DataFrame[T]) error {
+	rank := len(c.kernelSize)
+	in, out := newSpatialLayout(input.Dims, c.channelsFirst), newSpatialLayout(dst.Dims, c.channelsFirst)
+	pad := make([]int, rank)
+	for i := range pad {
+		pad[i] = c.padBefore(i, in.spatial[i], out.spatial[i])
+	}
+
+	// offsets are the positions of the kernel's elements, relative to its first one
+	kernelPositions := dimsProduct(c.kernelSize)
+	offsets := make([]int, kernelPositions*rank)
+	index := make([]int, rank)
+	for k := 0; k < kernelPositions; k++ {
+		for i := range index {
+			offsets[k*rank+i] = index[i] * c.dilationRate[i]
+		}
+		nextIndex(index, c.kernelSize)
+	}
+
+	inGroup, outGroup := c.inChannels/c.groups, c.outChannels/c.groups
+	outPositions := dimsProduct(out.spatial)
+	origin := make([]int, rank)
+	for b := 0; b < input.Dim(0); b++ {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		for o := 0; o < outPositions; o++ {
+			outBase := b * out.batchStride
+			for i := range index {
+				outBase += index[i] * out.strides[i]
+				origin[i] = index[i]*c.strides[i] - pad[i]
+			}
+			for oc := 0; oc < c.outChannels; oc++ {
+				dst.Data[outBase+oc*out.channelStride] = c.bias.Data[oc]
+			}
+
+			for k := 0; k < kernelPositions; k++ {
+				inBase, inside := b*in.batchStride, true
+				for i := 0; i < rank && inside; i++ {
+					p := origin[i] + offsets[k*rank+i]
+					inside = p >= 0 && p < in.spatial[i]
+					inBase += p * in.strides[i]
+				}
+				if !inside {
+					continue
+				}
+				for g := 0; g < c.groups; g++ {
+					for ic := 0; ic < inGroup; ic++ {
+						x := input.Data[inBase+(g*inGroup+ic)*in.channelStride]
+						kernelBase := (k*inGroup+ic)*c.outChannels + g*outGroup
+						for j, w := range c.kernel.Data[kernelBase : kernelBase+outGroup] {
+							dst.Data[outBase+(g*outGroup+j)*out.channelStride] += x * w
+						}
+					}
+				}
+			}
+			nextIndex(index, out.spatial)
+		}
+	}
+	return nil
+}
+
+// spatialLayout is where the elements of a batch of images, or of other inputs with spatial dimensions, are in their
+// DataFrame.
+type spatialLayout struct {
+	spatial, strides           []int
+	channelStride, batchStride int
+}
+
+func newSpatialLayout(dims []int, channelsFirst bool) spatialLayout {
+	l := spatialLayout{batchStride: dimsProduct(dims[1:]), channelStride: 1}
+	stride := dims[len(dims)-1]
+	l.spatial = dims[1 : len(dims)-1]
+	if channelsFirst {
+		l.spatial = dims[2:]
+		l.channelStride = dimsProduct(l.spatial)
+		stride = 1
+	}
+	l.strides = make([]int, len(l.spatial))
+	for i := len(l.spatial) - 1; i >= 0; i-- {
+		l.strides[i] = stride
+		stride *= l.spatial[i]
+	}
+	return l
+}
+
+// nextIndex moves index to the next element of a row-major array of shape dims, wrapping around to all zeros.
+func nextIndex(index, dims []int) {
+	for i := len(index) - 1; i >= 0; i-- {
+		if index[i]++; index[i] < dims[i] {
+			return
+		}
+		index[i] = 0
+	}
+}
+
+// Conv1D is Keras's Conv1D layer. Its inputs have the shape (batch, steps, channels), or (batch, channels, steps)
+// with ChannelsFirst.
+type Conv1D[T elefas.SizedNumber] struct {
+	convolution[T]
+}
+
+// NewConv1D returns a Conv1D layer with a kernel of shape (size, input channels / groups, filters), as in Keras, and
+// a bias with one value per filter. It panics if the weights or the options are invalid.
+func NewConv1D[T elefas.SizedNumber](kernel, bias elefas.DataFrame[T], options ConvOptions) Conv1D[T] {
+	c, err := newConvolution(1, kernel, bias, options)
+	if err != nil {
+		panic(err)
+	}
+	return Conv1D[T]{c}
+}
+
+// Conv2D is Keras's Conv2D layer. Its inputs have the shape (batch, height, width, channels), or (batch, channels,
+// height, width) with ChannelsFirst.
+type Conv2D[T elefas.SizedNumber] struct {
+	convolution[T]
+}
+
+// NewConv2D returns a Conv2D layer with a kernel of shape (height, width, input channels / groups, filters), as in
+// Keras, and a bias with one value per filter. It panics if the weights or the options are invalid.
+func NewConv2D[T elefas.SizedNumber](kernel, bias elefas.DataFrame[T], options ConvOptions) Conv2D[T] {
+	c, err := newConvolution(2, kernel, bias, options)
+	if err != nil {
+		panic(err)
+	}
+	return Conv2D[T]{c}
+}
+
+// Conv3D is Keras's Conv3D layer. Its inputs have the shape (batch, depth, height, width, channels), or (batch,
+// channels, depth, height, width) with ChannelsFirst.
+type Conv3D[T elefas.SizedNumber] struct {
+	convolution[T]
+}
+
+// NewConv3D returns a Conv3D layer with a kernel of shape (depth, height, width, input channels / groups, filters),
+// as in Keras, and a bias with one value per filter. It panics if the weights or the options are invalid.
+func NewConv3D[T elefas.SizedNumber](kernel, bias elefas.DataFrame[T], options ConvOptions) Conv3D[T] {
+	c, err := newConvolution(3, kernel, bias, options)
+	if err != nil {
+		panic(err)
+	}
+	return Conv3D[T]{c}
+}
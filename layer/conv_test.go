@@ -0,0 +1,294 @@
+package layer_test
+
+import (
+	"fmt"
+	"math/rand"
+	"testing"
+
+	"github.com/YohayAiTe/elefas"
+	"github.com/YohayAiTe/elefas/layer"
+	"github.com/YohayAiTe/elefas/layer/internal/testutils"
+)
+
+type convTestCase struct {
+	input      []int
+	kernelSize []int
+	filters    int
+	options    layer.ConvOptions
+}
+
+func (c convTestCase) String() string {
+	return fmt.Sprintf("%s,k=%s,f=%d,s=%v,d=%v,g=%d,%s,%s", testutils.DimString(c.input),
+		testutils.DimString(c.kernelSize), c.filters, c.options.Strides, c.options.DilationRate, c.options.Groups,
+		c.options.Padding, c.options.DataFormat)
+}
+
+// channels returns the number of input channels of the test case.
+func (c convTestCase) channels() int {
+	if c.options.DataFormat == layer.ChannelsFirst {
+		return c.input[1]
+	}
+	return c.input[len(c.input)-1]
+}
+
+// convConfig saves the options of a convolution for keras_script.py.
+func convConfig[T elefas.SizedNumber](rank int, options layer.ConvOptions) elefas.DataFrame[T] {
+	config := elefas.MakeDataFrame[T]([]int{2*rank + 3})
+	for i := 0; i < rank; i++ {
+		config.Data[i], config.Data[rank+i] = 1, 1
+		if options.Strides != nil {
+			config.Data[i] = T(options.Strides[i])
+		}
+		if options.DilationRate != nil {
+			config.Data[rank+i] = T(options.DilationRate[i])
+		}
+	}
+	config.Data[2*rank] = 1
+	if options.Groups != 0 {
+		config.Data[2*rank] = T(options.Groups)
+	}
+	switch options.Padding {
+	case layer.PaddingSame:
+		config.Data[2*rank+1] = 1
+	case layer.PaddingCausal:
+		config.Data[2*rank+1] = 2
+	}
+	if options.DataFormat == layer.ChannelsFirst {
+		config.Data[2*rank+2] = 1
+	}
+	return config
+}
+
+func convTestFunc[T elefas.SizedNumber, L elefas.Layer[T]](t *testing.T, r *rand.Rand, name string,
+	newLayer func(kernel, bias elefas.DataFrame[T], options layer.ConvOptions) L, testCases []convTestCase,
+	epsilon T) {
+
+	for _, testCase := range testCases {
+		t.Run(testCase.String(), func(t *testing.T) {
+			rank := len(testCase.kernelSize)
+			channels := testCase.channels()
+			if testCase.options.Groups > 1 {
+				channels /= testCase.options.Groups
+			}
+			kernel := testutils.RandomDataFrame[T](r, append(append([]int{}, testCase.kernelSize...), channels,
+				testCase.filters))
+			bias := testutils.RandomDataFrame[T](r, []int{testCase.filters})
+			input := testutils.RandomDataFrame[T](r, testCase.input)
+
+			testutils.TestLayerByPython[T](t, testutils.PythonLayerData[T]{
+				Name:    name,
+				Weights: []elefas.DataFrame[T]{kernel, bias, convConfig[T](rank, testCase.options)},
+			}, newLayer(kernel, bias, testCase.options), input, epsilon)
+		})
+	}
+}
+
+func TestConv1D(t *testing.T) {
+	t.Parallel()
+	r := rand.New(rand.NewSource(0))
+	testCases := []convTestCase{
+		{[]int{1, 5, 1}, []int{3}, 1, layer.ConvOptions{}},
+		{[]int{2, 10, 3}, []int{4}, 5, layer.ConvOptions{Strides: []int{3}}},
+		{[]int{2, 10, 3}, []int{4}, 5, layer.ConvOptions{Padding: layer.PaddingSame, Strides: []int{3}}},
+		{[]int{2, 10, 3}, []int{4}, 5, layer.ConvOptions{Padding: layer.PaddingCausal}},
+		{[]int{2, 10, 3}, []int{3}, 2, layer.ConvOptions{Padding: layer.PaddingCausal, DilationRate: []int{2}}},
+		{[]int{2, 11, 4}, []int{3}, 4, layer.ConvOptions{Padding: layer.PaddingCausal, Strides: []int{2}, Groups: 2}},
+		{[]int{2, 3, 10}, []int{3}, 4, layer.ConvOptions{Padding: layer.PaddingSame, DilationRate: []int{3},
+			DataFormat: layer.ChannelsFirst}},
+	}
+	t.Run("float32", func(t *testing.T) {
+		convTestFunc(t, r, "conv1d", layer.NewConv1D[float32], testCases, 1e-4)
+	})
+	t.Run("float64", func(t *testing.T) {
+		convTestFunc(t, r, "conv1d", layer.NewConv1D[float64], testCases, 1e-5)
+	})
+}
+
+func TestConv2D(t *testing.T) {
+	t.Parallel()
+	r := rand.New(rand.NewSource(0))
+	testCases := []convTestCase{
+		{[]int{1, 5, 5, 1}, []int{3, 3}, 1, layer.ConvOptions{}},
+		{[]int{2, 6, 7, 3}, []int{3, 2}, 4, layer.ConvOptions{}},
+		{[]int{2, 6, 7, 3}, []int{3, 2}, 4, layer.ConvOptions{Padding: layer.PaddingSame}},
+		{[]int{2, 8, 9, 2}, []int{3, 3}, 3, layer.ConvOptions{Strides: []int{2, 3}}},
+		{[]int{2, 8, 9, 2}, []int{2, 4}, 3, layer.ConvOptions{Padding: layer.PaddingSame, Strides: []int{2, 3}}},
+		{[]int{1, 9, 9, 2}, []int{3, 3}, 2, layer.ConvOptions{DilationRate: []int{2, 3}}},
+		{[]int{1, 9, 9, 2}, []int{2, 3}, 2, layer.ConvOptions{Padding: layer.PaddingSame, DilationRate: []int{3, 2}}},
+		{[]int{2, 6, 6, 4}, []int{3, 3}, 6, layer.ConvOptions{Groups: 2}},
+		{[]int{1, 7, 7, 6}, []int{3, 3}, 6, layer.ConvOptions{Padding: layer.PaddingSame, Groups: 3}},
+		{[]int{2, 3, 6, 7}, []int{3, 2}, 4, layer.ConvOptions{DataFormat: layer.ChannelsFirst}},
+		{[]int{2, 4, 8, 9}, []int{3, 3}, 4, layer.ConvOptions{Padding: layer.PaddingSame, Strides: []int{2, 2},
+			Groups: 2, DataFormat: layer.ChannelsFirst}},
+	}
+	t.Run("float32", func(t *testing.T) {
+		convTestFunc(t, r, "conv2d", layer.NewConv2D[float32], testCases, 1e-4)
+	})
+	t.Run("float64", func(t *testing.T) {
+		convTestFunc(t, r, "conv2d", layer.NewConv2D[float64], testCases, 1e-5)
+	})
+}
+
+func TestConv3D(t *testing.T) {
+	t.Parallel()
+	r := rand.New(rand.NewSource(0))
+	testCases := []convTestCase{
+		{[]int{1, 4, 5, 6, 2}, []int{2, 3, 3}, 3, layer.ConvOptions{}},
+		{[]int{2, 5, 6, 7, 2}, []int{3, 2, 3}, 3, layer.ConvOptions{Padding: layer.PaddingSame}},
+		{[]int{2, 6, 6, 7, 3}, []int{3, 3, 2}, 2, layer.ConvOptions{Strides: []int{2, 1, 3}}},
+		{[]int{1, 7, 6, 6, 2}, []int{2, 2, 3}, 2, layer.ConvOptions{Padding: layer.PaddingSame,
+			Strides: []int{2, 3, 1}}},
+		{[]int{1, 7, 7, 7, 4}, []int{2, 3, 2}, 4, layer.ConvOptions{DilationRate: []int{3, 1, 2}, Groups: 2}},
+		{[]int{2, 3, 5, 6, 4}, []int{3, 2, 2}, 2, layer.ConvOptions{Padding: layer.PaddingSame,
+			DataFormat: layer.ChannelsFirst}},
+	}
+	t.Run("float32", func(t *testing.T) {
+		convTestFunc(t, r, "conv3d", layer.NewConv3D[float32], testCases, 1e-4)
+	})
+	t.Run("float64", func(t *testing.T) {
+		convTestFunc(t, r, "conv3d", layer.NewConv3D[float64], testCases, 1e-5)
+	})
+}
+
+// depthwiseTestFunc tests DepthwiseConv2D, where the filters of the test cases are their depth multiplier.
+func depthwiseTestFunc[T elefas.SizedNumber](t *testing.T, r *rand.Rand, testCases []convTestCase, epsilon T) {
+	for _, testCase := range testCases {
+		t.Run(testCase.String(), func(t *testing.T) {
+			channels := testCase.channels()
+			kernel := testutils.RandomDataFrame[T](r, append(append([]int{}, testCase.kernelSize...), channels,
+				testCase.filters))
+			bias := testutils.RandomDataFrame[T](r, []int{channels * testCase.filters})
+			input := testutils.RandomDataFrame[T](r, testCase.input)
+
+			testutils.TestLayerByPython[T](t, testutils.PythonLayerData[T]{
+				Name:    "depthwise_conv2d",
+				Weights: []elefas.DataFrame[T]{kernel, bias, convConfig[T](2, testCase.options)},
+			}, layer.NewDepthwiseConv2D(kernel, bias, testCase.options), input, epsilon)
+		})
+	}
+}
+
+func TestDepthwiseConv2D(t *testing.T) {
+	t.Parallel()
+	r := rand.New(rand.NewSource(0))
+	testCases := []convTestCase{
+		{[]int{1, 5, 5, 1}, []int{3, 3}, 1, layer.ConvOptions{}},
+		{[]int{2, 6, 7, 3}, []int{3, 2}, 2, layer.ConvOptions{}},
+		{[]int{2, 6, 7, 3}, []int{3, 3}, 1, layer.ConvOptions{Padding: layer.PaddingSame}},
+		{[]int{2, 8, 9, 2}, []int{3, 3}, 3, layer.ConvOptions{Padding: layer.PaddingSame, Strides: []int{2, 2}}},
+		{[]int{1, 9, 9, 2}, []int{3, 2}, 2, layer.ConvOptions{DilationRate: []int{2, 3}}},
+		{[]int{2, 3, 6, 7}, []int{3, 3}, 2, layer.ConvOptions{Padding: layer.PaddingSame,
+			DataFormat: layer.ChannelsFirst}},
+	}
+	t.Run("float32", func(t *testing.T) {
+		depthwiseTestFunc[float32](t, r, testCases, 1e-4)
+	})
+	t.Run("float64", func(t *testing.T) {
+		depthwiseTestFunc[float64](t, r, testCases, 1e-5)
+	})
+}
+
+type separableTestCase struct {
+	convTestCase
+	depthMultiplier int
+}
+
+func (c separableTestCase) String() string {
+	return fmt.Sprintf("%v,m=%d", c.convTestCase, c.depthMultiplier)
+}
+
+func separableTestFunc[T elefas.SizedNumber](t *testing.T, r *rand.Rand, testCases []separableTestCase, epsilon T) {
+	for _, testCase := range testCases {
+		t.Run(testCase.String(), func(t *testing.T) {
+			channels := testCase.channels()
+			depthwiseKernel := testutils.RandomDataFrame[T](r, append(append([]int{}, testCase.kernelSize...),
+				channels, testCase.depthMultiplier))
+			pointwiseKernel := testutils.RandomDataFrame[T](r, []int{1, 1, channels * testCase.depthMultiplier,
+				testCase.filters})
+			bias := testutils.RandomDataFrame[T](r, []int{testCase.filters})
+			input := testutils.RandomDataFrame[T](r, testCase.input)
+
+			testutils.TestLayerByPython[T](t, testutils.PythonLayerData[T]{
+				Name: "separable_conv2d",
+				Weights: []elefas.DataFrame[T]{depthwiseKernel, pointwiseKernel, bias,
+					convConfig[T](2, testCase.options)},
+			}, layer.NewSeparableConv2D(depthwiseKernel, pointwiseKernel, bias, testCase.options), input, epsilon)
+		})
+	}
+}
+
+func TestSeparableConv2D(t *testing.T) {
+	t.Parallel()
+	r := rand.New(rand.NewSource(0))
+	testCases := []separableTestCase{
+		{convTestCase{[]int{1, 5, 5, 1}, []int{3, 3}, 1, layer.ConvOptions{}}, 1},
+		{convTestCase{[]int{2, 6, 7, 3}, []int{3, 2}, 4, layer.ConvOptions{}}, 2},
+		{convTestCase{[]int{2, 8, 9, 2}, []int{3, 3}, 5, layer.ConvOptions{Padding: layer.PaddingSame,
+			Strides: []int{2, 3}}}, 1},
+		{convTestCase{[]int{1, 9, 9, 2}, []int{2, 3}, 3, layer.ConvOptions{Padding: layer.PaddingSame,
+			DilationRate: []int{3, 2}}}, 3},
+		{convTestCase{[]int{2, 3, 6, 7}, []int{3, 3}, 4, layer.ConvOptions{DataFormat: layer.ChannelsFirst}}, 2},
+	}
+	t.Run("float32", func(t *testing.T) {
+		separableTestFunc[float32](t, r, testCases, 1e-4)
+	})
+	t.Run("float64", func(t *testing.T) {
+		separableTestFunc[float64](t, r, testCases, 1e-5)
+	})
+}
+
+// convTransposeConfig saves the options of a transposed convolution for keras_script.py: those of convConfig, then
+// whether it has output padding, and the output padding.
+func convTransposeConfig[T elefas.SizedNumber](rank int, options layer.ConvOptions) elefas.DataFrame[T] {
+	config := convConfig[T](rank, options)
+	outputPadding := make([]T, rank+1)
+	if options.OutputPadding != nil {
+		outputPadding[0] = 1
+		for i, p := range options.OutputPadding {
+			outputPadding[i+1] = T(p)
+		}
+	}
+	config.Data = append(config.Data, outputPadding...)
+	config.Dims[0] = len(config.Data)
+	return config
+}
+
+func convTransposeTestFunc[T elefas.SizedNumber](t *testing.T, r *rand.Rand, testCases []convTestCase, epsilon T) {
+	for _, testCase := range testCases {
+		t.Run(testCase.String(), func(t *testing.T) {
+			kernel := testutils.RandomDataFrame[T](r, append(append([]int{}, testCase.kernelSize...),
+				testCase.filters, testCase.channels()))
+			bias := testutils.RandomDataFrame[T](r, []int{testCase.filters})
+			input := testutils.RandomDataFrame[T](r, testCase.input)
+
+			testutils.TestLayerByPython[T](t, testutils.PythonLayerData[T]{
+				Name:    "conv2d_transpose",
+				Weights: []elefas.DataFrame[T]{kernel, bias, convTransposeConfig[T](2, testCase.options)},
+			}, layer.NewConv2DTranspose(kernel, bias, testCase.options), input, epsilon)
+		})
+	}
+}
+
+func TestConv2DTranspose(t *testing.T) {
+	t.Parallel()
+	r := rand.New(rand.NewSource(0))
+	testCases := []convTestCase{
+		{[]int{1, 3, 3, 1}, []int{3, 3}, 1, layer.ConvOptions{}},
+		{[]int{2, 4, 5, 3}, []int{3, 2}, 4, layer.ConvOptions{Strides: []int{2, 2}}},
+		{[]int{2, 4, 5, 3}, []int{3, 3}, 2, layer.ConvOptions{Padding: layer.PaddingSame, Strides: []int{2, 3}}},
+		{[]int{2, 4, 5, 2}, []int{2, 2}, 3, layer.ConvOptions{Padding: layer.PaddingSame, Strides: []int{2, 2}}},
+		{[]int{2, 4, 4, 2}, []int{1, 2}, 3, layer.ConvOptions{Strides: []int{3, 3}}},
+		{[]int{1, 4, 5, 2}, []int{3, 3}, 2, layer.ConvOptions{Padding: layer.PaddingSame, Strides: []int{2, 2},
+			OutputPadding: []int{1, 0}}},
+		{[]int{1, 4, 5, 2}, []int{3, 2}, 2, layer.ConvOptions{Strides: []int{3, 2}, OutputPadding: []int{2, 1}}},
+		{[]int{1, 5, 5, 2}, []int{3, 3}, 2, layer.ConvOptions{Padding: layer.PaddingSame, DilationRate: []int{2, 1}}},
+		{[]int{2, 3, 4, 5}, []int{3, 3}, 4, layer.ConvOptions{Padding: layer.PaddingSame, Strides: []int{2, 2},
+			DataFormat: layer.ChannelsFirst}},
+	}
+	t.Run("float32", func(t *testing.T) {
+		convTransposeTestFunc[float32](t, r, testCases, 1e-4)
+	})
+	t.Run("float64", func(t *testing.T) {
+		convTransposeTestFunc[float64](t, r, testCases, 1e-5)
+	})
+}
@@ -0,0 +1,252 @@
+package layer
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/YohayAiTe/elefas"
+)
+
+// transposedConvolution is the core of the transposed convolution layers, for any number of spatial dimensions. It
+// goes the opposite way of a convolution with the same options: every element of the input adds the kernel, scaled
+// by it, to the output.
+type transposedConvolution[T elefas.SizedNumber] struct {
+	kernelSize, strides, dilationRate []int
+	// outputPadding is nil for Keras's default output size
+	outputPadding           []int
+	inChannels, outChannels int
+	padding                 Padding
+	channelsFirst           bool
+
+	// kernel has Keras's layout: the spatial dimensions, then the filters, then the input channels
+	kernel, bias elefas.DataFrame[T]
+}
+
+func newTransposedConvolution[T elefas.SizedNumber](rank int, kernel, bias elefas.DataFrame[T],
+	options ConvOptions) (transposedConvolution[T], error) {
+
+	if kernel.DimCount() != rank+2 {
+		return transposedConvolution[T]{}, fmt.Errorf("%dD transposed convolution kernel must have %d dimensions, "+
+			"got shape %v", rank, rank+2, kernel.Dims)
+	}
+	c := transposedConvolution[T]{
+		kernelSize:  append([]int{}, kernel.Dims[:rank]...),
+		outChannels: kernel.Dim(rank),
+		inChannels:  kernel.Dim(rank + 1),
+		padding:     options.Padding,
+		kernel:      kernel, bias: bias,
+	}
+	if bias.DimCount() != 1 || bias.Dim(0) != c.outChannels {
+		return transposedConvolution[T]{}, fmt.Errorf("convolution bias has shape %v, expected (%d,)", bias.Dims,
+			c.outChannels)
+	}
+	if options.Groups > 1 {
+		return transposedConvolution[T]{}, fmt.Errorf("transposed convolution takes no groups")
+	}
+
+	var err error
+	if c.strides, err = convVector(options.Strides, rank, "strides"); err != nil {
+		return transposedConvolution[T]{}, err
+	}
+	if c.dilationRate, err = convVector(options.DilationRate, rank, "dilation rate"); err != nil {
+		return transposedConvolution[T]{}, err
+	}
+	if options.OutputPadding != nil {
+		if len(options.OutputPadding) != rank {
+			return transposedConvolution[T]{}, fmt.Errorf("%dD convolution takes %d output padding, got %v", rank,
+				rank, options.OutputPadding)
+		}
+		for i, p := range options.OutputPadding {
+			if p < 0 || p >= c.strides[i] {
+				return transposedConvolution[T]{}, fmt.Errorf("output padding %v must be less than the strides %v",
+					options.OutputPadding, c.strides)
+			}
+		}
+		c.outputPadding = append([]int{}, options.OutputPadding...)
+	}
+	switch c.padding {
+	case "":
+		c.padding = PaddingValid
+	case PaddingValid, PaddingSame:
+	default:
+		return transposedConvolution[T]{}, fmt.Errorf("transposed convolution cannot take padding %q", c.padding)
+	}
+	if c.channelsFirst, err = isChannelsFirst(options.DataFormat); err != nil {
+		return transposedConvolution[T]{}, err
+	}
+	return c, nil
+}
+
+func (c transposedConvolution[T]) ParamCount() int {
+	return c.kernel.TotalSize() + c.bias.TotalSize()
+}
+
+func (c transposedConvolution[T]) Apply(input elefas.DataFrame[T]) elefas.DataFrame[T] {
+	return must(c.ApplyE(input))
+}
+
+func (c transposedConvolution[T]) ApplyE(input elefas.DataFrame[T]) (elefas.DataFrame[T], error) {
+	return c.ApplyContext(context.Background(), input)
+}
+
+func (c transposedConvolution[T]) OutputShape(inputShape []int) ([]int, error) {
+	rank := len(c.kernelSize)
+	channelAxis, spatialAxis := rank+1, 1
+	if c.channelsFirst {
+		channelAxis, spatialAxis = 1, 2
+	}
+	if len(inputShape) != rank+2 || !dimsMatch(inputShape[channelAxis], c.inChannels) {
+		expected := anyShape(rank + 2)
+		expected[channelAxis] = c.inChannels
+		return nil, &elefas.ShapeError{Expected: expected, Actual: inputShape,
+			Reason: fmt.Sprintf("%dD transposed convolution's input does not match its kernel", rank)}
+	}
+
+	outputShape := append([]int{}, inputShape...)
+	outputShape[channelAxis] = c.outChannels
+	for i := 0; i < rank; i++ {
+		if size := inputShape[spatialAxis+i]; size != -1 {
+			outputShape[spatialAxis+i] = c.outputSize(i, size)
+		}
+	}
+	return outputShape, nil
+}
+
+func (c transposedConvolution[T]) dilatedKernelSize(i int) int {
+	return (c.kernelSize[i]-1)*c.dilationRate[i] + 1
+}
+
+// outputSize returns the size of the output in the i-th spatial dimension, for an input of the given size, like
+// Keras's deconv_output_length.
+func (c transposedConvolution[T]) outputSize(i, size int) int {
+	kernelSize, stride := c.dilatedKernelSize(i), c.strides[i]
+	if c.outputPadding == nil {
+		if c.padding == PaddingSame || kernelSize < stride {
+			return size * stride
+		}
+		return size*stride + kernelSize - stride
+	}
+	pad := 0
+	if c.padding == PaddingSame {
+		pad = kernelSize / 2
+	}
+	return (size-1)*stride + kernelSize - 2*pad + c.outputPadding[i]
+}
+
+// padBefore returns how much of the start of the output in the i-th spatial dimension is cut off; it is the padding
+// before the input of the convolution that the transposed convolution goes the opposite way of.
+func (c transposedConvolution[T]) padBefore(i, inputSize, outputSize int) int {
+	if c.padding == PaddingValid {
+		return 0
+	}
+	total := (inputSize-1)*c.strides[i] + c.dilatedKernelSize(i) - outputSize
+	if total < 0 {
+		return 0
+	}
+	return total / 2
+}
+
+func (c transposedConvolution[T]) ApplyContext(ctx context.Context, input elefas.DataFrame[T]) (elefas.DataFrame[T],
+	error) {
+
+	outputDims, err := c.OutputShape(input.Dims)
+	if err != nil {
+		return elefas.DataFrame[T]{}, err
+	}
+	output := elefas.MakeDataFrame[T](outputDims)
+	if err := c.ApplyIntoContext(ctx, output, input); err != nil {
+		return elefas.DataFrame[T]{}, err
+	}
+	return output, nil
+}
+
+func (c transposedConvolution[T]) ApplyInto(dst, input elefas.DataFrame[T]) error {
+	return c.ApplyIntoContext(context.Background(), dst, input)
+}
+
+// ApplyIntoContext checks ctx between the samples of the batch.
+func (c transposedConvolution[T]) ApplyIntoContext(ctx context.Context, dst, input elefas.DataFrame[T]) error {
+	rank := len(c.kernelSize)
+	in, out := newSpatialLayout(input.Dims, c.channelsFirst), newSpatialLayout(dst.Dims, c.channelsFirst)
+	pad := make([]int, rank)
+	for i := range pad {
+		pad[i] = c.padBefore(i, in.spatial[i], out.spatial[i])
+	}
+
+	// offsets are the positions of the kernel's elements, relative to its first one
+	kernelPositions := dimsProduct(c.kernelSize)
+	offsets := make([]int, kernelPositions*rank)
+	index := make([]int, rank)
+	for k := 0; k < kernelPositions; k++ {
+		for i := range index {
+			offsets[k*rank+i] = index[i] * c.dilationRate[i]
+		}
+		nextIndex(index, c.kernelSize)
+	}
+
+	inPositions, outPositions := dimsProduct(in.spatial), dimsProduct(out.spatial)
+	origin := make([]int, rank)
+	for b := 0; b < input.Dim(0); b++ {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		for o := 0; o < outPositions; o++ {
+			outBase := b * out.batchStride
+			for i := range index {
+				outBase += index[i] * out.strides[i]
+			}
+			for oc := 0; oc < c.outChannels; oc++ {
+				dst.Data[outBase+oc*out.channelStride] = c.bias.Data[oc]
+			}
+			nextIndex(index, out.spatial)
+		}
+
+		for p := 0; p < inPositions; p++ {
+			inBase := b * in.batchStride
+			for i := range index {
+				inBase += index[i] * in.strides[i]
+				origin[i] = index[i]*c.strides[i] - pad[i]
+			}
+			for k := 0; k < kernelPositions; k++ {
+				outBase, inside := b*out.batchStride, true
+				for i := 0; i < rank && inside; i++ {
+					o := origin[i] + offsets[k*rank+i]
+					inside = o >= 0 && o < out.spatial[i]
+					outBase += o * out.strides[i]
+				}
+				if !inside {
+					continue
+				}
+				for oc := 0; oc < c.outChannels; oc++ {
+					kernelBase := (k*c.outChannels + oc) * c.inChannels
+					var sum T
+					for ic, w := range c.kernel.Data[kernelBase : kernelBase+c.inChannels] {
+						sum += input.Data[inBase+ic*in.channelStride] * w
+					}
+					dst.Data[outBase+oc*out.channelStride] += sum
+				}
+			}
+			nextIndex(index, in.spatial)
+		}
+	}
+	return nil
+}
+
+// Conv2DTranspose is Keras's Conv2DTranspose layer. Its inputs have the shape (batch, height, width, channels), or
+// (batch, channels, height, width) with ChannelsFirst.
+type Conv2DTranspose[T elefas.SizedNumber] struct {
+	transposedConvolution[T]
+}
+
+// NewConv2DTranspose returns a Conv2DTranspose layer with a kernel of shape (height, width, filters, input channels),
+// as in Keras, and a bias with one value per filter. The options cannot have Groups or causal padding. It panics if
+// the weights or the options are invalid.
+func NewConv2DTranspose[T elefas.SizedNumber](kernel, bias elefas.DataFrame[T],
+	options ConvOptions) Conv2DTranspose[T] {
+
+	c, err := newTransposedConvolution(2, kernel, bias, options)
+	if err != nil {
+		panic(err)
+	}
+	return Conv2DTranspose[T]{c}
+}
@@ -1,6 +1,7 @@
 package layer
 
 import (
+	"context"
 	"unsafe"
 
 	"github.com/YohayAiTe/elefas"
@@ -40,27 +41,82 @@ func NewDense[T elefas.SizedNumber](kernel, bias elefas.DataFrame[T]) Dense[T] {
 	}
 }
 
+func (d Dense[T]) ParamCount() int {
+	return d.kernel.TotalSize() + d.bias.TotalSize()
+}
+
 func (d Dense[T]) Apply(input elefas.DataFrame[T]) elefas.DataFrame[T] {
-	if len(input.Dims) < 1 {
-		panic("dense layer's input must have at least one dimension")
+	return must(d.ApplyE(input))
+}
+
+func (d Dense[T]) OutputShape(inputShape []int) ([]int, error) {
+	if len(inputShape) < 1 || !dimsMatch(d.inputUnits, inputShape[len(inputShape)-1]) {
+		expected := anyShape(len(inputShape))
+		if len(expected) == 0 {
+			expected = []int{-1}
+		}
+		expected[len(expected)-1] = d.inputUnits
+		return nil, &elefas.ShapeError{Expected: expected, Actual: inputShape,
+			Reason: "dense layer's input does not match the specified input units"}
 	}
-	if d.inputUnits != input.Dims[len(input.Dims)-1] {
-		panic("dense layer's input does not match the specified input units")
+	outputShape := append([]int{}, inputShape...)
+	outputShape[len(outputShape)-1] = d.outputUnits
+	return outputShape, nil
+}
+
+func (d Dense[T]) ApplyE(input elefas.DataFrame[T]) (elefas.DataFrame[T], error) {
+	return d.ApplyContext(context.Background(), input)
+}
+
+// denseChunkSize is about how many multiplications Dense does between checks of its context.
+const denseChunkSize = 1 << 20
+
+func (d Dense[T]) ApplyContext(ctx context.Context, input elefas.DataFrame[T]) (elefas.DataFrame[T], error) {
+	outputDims, err := d.OutputShape(input.Dims)
+	if err != nil {
+		return elefas.DataFrame[T]{}, err
 	}
-	outputDims := make([]int, len(input.Dims))
-	copy(outputDims, input.Dims)
-	outputDims[len(outputDims)-1] = d.outputUnits
 	output := elefas.MakeDataFrame[T](outputDims)
+	if err := d.ApplyIntoContext(ctx, output, input); err != nil {
+		return elefas.DataFrame[T]{}, err
+	}
+	return output, nil
+}
+
+func (d Dense[T]) ApplyInto(dst, input elefas.DataFrame[T]) error {
+	return d.ApplyIntoContext(context.Background(), dst, input)
+}
+
+func (d Dense[T]) ApplyIntoContext(ctx context.Context, dst, input elefas.DataFrame[T]) error {
+	batchCount := dst.TotalSize() / d.outputUnits
+	chunk := denseChunkSize / (d.inputUnits*d.outputUnits + 1)
+	if chunk < 1 {
+		chunk = 1
+	}
+	for start := 0; start < batchCount; start += chunk {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		end := start + chunk
+		if end > batchCount {
+			end = batchCount
+		}
+		d.applyBatches(input.Data[start*d.inputUnits:end*d.inputUnits],
+			dst.Data[start*d.outputUnits:end*d.outputUnits], end-start)
+	}
+	return nil
+}
 
-	batchCount := output.TotalSize() / d.outputUnits
+// applyBatches computes batchCount rows of output from the rows of input.
+func (d Dense[T]) applyBatches(input, output []T, batchCount int) {
 	if _, isF32 := any(T(0)).(float32); isF32 && optimization.HasDenseApplyF32 {
 		optimization.DenseApplyF32(
-			(*float32)(unsafe.Pointer(unsafe.SliceData(input.Data))),
+			(*float32)(unsafe.Pointer(unsafe.SliceData(input))),
 			(*float32)(unsafe.Pointer(unsafe.SliceData(d.kernel.Data))),
 			(*float32)(unsafe.Pointer(unsafe.SliceData(d.bias.Data))),
-			(*float32)(unsafe.Pointer(unsafe.SliceData(output.Data))),
+			(*float32)(unsafe.Pointer(unsafe.SliceData(output))),
 			int64(batchCount), int64(d.inputUnits), int64(d.outputUnits))
-		return output
+		return
 	}
 
 	var acc T
@@ -71,12 +127,10 @@ func (d Dense[T]) Apply(input elefas.DataFrame[T]) elefas.DataFrame[T] {
 			acc = d.bias.Data[j]
 
 			for i := 0; i < d.inputUnits; i++ {
-				acc += input.Data[batch*d.inputUnits+i] * d.kernel.Data[kernelIndex] // kernelIndex = i*dl.outputUnits+j
+				acc += input[batch*d.inputUnits+i] * d.kernel.Data[kernelIndex] // kernelIndex = i*dl.outputUnits+j
 				kernelIndex++
 			}
-			output.Data[outputIndex] = acc
+			output[outputIndex] = acc
 		}
 	}
-
-	return output
 }
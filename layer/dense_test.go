@@ -1,7 +1,9 @@
 package layer_test
 
 import (
+	"context"
 	"fmt"
+	"math"
 	"math/rand"
 	"testing"
 
@@ -79,3 +81,34 @@ func BenchmarkDense(b *testing.B) {
 		denseBenchFunc[float64](b, r, dims)
 	})
 }
+
+func TestDenseApplyContext(t *testing.T) {
+	r := rand.New(rand.NewSource(0))
+	kernel := testutils.RandomDataFrame[float32](r, []int{256, 300})
+	bias := testutils.RandomDataFrame[float32](r, []int{300})
+	input := testutils.RandomDataFrame[float32](r, []int{50, 256})
+	dense := layer.NewDense(kernel, bias)
+
+	// the input is large enough to be split into chunks, which should not change the result
+	output, err := dense.ApplyContext(context.Background(), input)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for b := 0; b < 50; b++ {
+		for j := 0; j < 300; j++ {
+			expected := float64(bias.At(j))
+			for i := 0; i < 256; i++ {
+				expected += float64(input.At(b, i)) * float64(kernel.At(i, j))
+			}
+			if math.Abs(float64(output.At(b, j))-expected) > 1e-3 {
+				t.Fatalf("output[%d, %d] is %v, expected %v", b, j, output.At(b, j), expected)
+			}
+		}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := dense.ApplyContext(ctx, input); err != context.Canceled {
+		t.Errorf("ApplyContext returned %v, expected %v", err, context.Canceled)
+	}
+}
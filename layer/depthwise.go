@@ -0,0 +1,170 @@
+package layer
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/YohayAiTe/elefas"
+)
+
+// newDepthwiseConvolution returns the convolution of a depthwise convolution layer, whose kernel has Keras's layout:
+// the spatial dimensions, then the input channels, then the depth multiplier. Each input channel is convolved
+// separately into as many output channels as the depth multiplier, which is a convolution with a group per input
+// channel, and whose kernel has the same data.
+func newDepthwiseConvolution[T elefas.SizedNumber](rank int, kernel, bias elefas.DataFrame[T],
+	options ConvOptions) (convolution[T], error) {
+
+	if kernel.DimCount() != rank+2 {
+		return convolution[T]{}, fmt.Errorf("%dD depthwise convolution kernel must have %d dimensions, got shape %v",
+			rank, rank+2, kernel.Dims)
+	}
+	if options.Groups != 0 {
+		return convolution[T]{}, fmt.Errorf("depthwise convolution takes no groups")
+	}
+	channels := kernel.Dim(rank)
+	dims := append(append([]int{}, kernel.Dims[:rank]...), 1, channels*kernel.Dim(rank+1))
+	options.Groups = channels
+	return newConvolution(rank, elefas.DataFrame[T]{Dims: dims, Data: kernel.Data}, bias, options)
+}
+
+// DepthwiseConv2D is Keras's DepthwiseConv2D layer. Its inputs have the shape (batch, height, width, channels), or
+// (batch, channels, height, width) with ChannelsFirst, and its output has channels times the depth multiplier
+// channels.
+type DepthwiseConv2D[T elefas.SizedNumber] struct {
+	convolution[T]
+}
+
+// NewDepthwiseConv2D returns a DepthwiseConv2D layer with a kernel of shape (height, width, input channels, depth
+// multiplier), as in Keras, and a bias with one value per output channel. The options cannot have Groups. It panics
+// if the weights or the options are invalid.
+func NewDepthwiseConv2D[T elefas.SizedNumber](kernel, bias elefas.DataFrame[T],
+	options ConvOptions) DepthwiseConv2D[T] {
+
+	c, err := newDepthwiseConvolution(2, kernel, bias, options)
+	if err != nil {
+		panic(err)
+	}
+	return DepthwiseConv2D[T]{c}
+}
+
+// separableConvolution is the core of the separable convolution layers: a depthwise convolution, with the strides,
+// dilation rate and padding of the layer, followed by a pointwise convolution with a kernel of size 1 and the bias.
+// The output of the depthwise convolution is only needed until the pointwise convolution has run, so its buffers are
+// kept in scratch, which the copies of the layer share, for the next calls.
+type separableConvolution[T elefas.SizedNumber] struct {
+	depthwise, pointwise convolution[T]
+	scratch              *sync.Pool
+}
+
+func newSeparableConvolution[T elefas.SizedNumber](rank int, depthwiseKernel, pointwiseKernel,
+	bias elefas.DataFrame[T], options ConvOptions) (separableConvolution[T], error) {
+
+	if depthwiseKernel.DimCount() != rank+2 {
+		return separableConvolution[T]{}, fmt.Errorf("%dD depthwise kernel must have %d dimensions, got shape %v",
+			rank, rank+2, depthwiseKernel.Dims)
+	}
+	depthwiseChannels := depthwiseKernel.Dim(rank) * depthwiseKernel.Dim(rank+1)
+	depthwise, err := newDepthwiseConvolution(rank, depthwiseKernel, elefas.MakeDataFrame[T]([]int{depthwiseChannels}),
+		options)
+	if err != nil {
+		return separableConvolution[T]{}, err
+	}
+	if pointwiseKernel.DimCount() != rank+2 || dimsProduct(pointwiseKernel.Dims[:rank]) != 1 ||
+		pointwiseKernel.Dim(rank) != depthwiseChannels {
+		return separableConvolution[T]{}, fmt.Errorf("pointwise kernel has shape %v, expected a kernel of size 1 "+
+			"for %d channels", pointwiseKernel.Dims, depthwiseChannels)
+	}
+	pointwise, err := newConvolution(rank, pointwiseKernel, bias, ConvOptions{DataFormat: options.DataFormat})
+	if err != nil {
+		return separableConvolution[T]{}, err
+	}
+	return separableConvolution[T]{depthwise: depthwise, pointwise: pointwise, scratch: &sync.Pool{}}, nil
+}
+
+// scratchBuffer returns a DataFrame of shape dims for the output of the depthwise convolution, whose data is taken
+// from scratch if a large enough buffer is there, and the buffer to put back. The data is not cleared.
+func (s separableConvolution[T]) scratchBuffer(dims []int) (elefas.DataFrame[T], *[]T) {
+	size := dimsProduct(dims)
+	if s.scratch != nil {
+		if buffer, ok := s.scratch.Get().(*[]T); ok && cap(*buffer) >= size {
+			return elefas.DataFrame[T]{Dims: dims, Data: (*buffer)[:size]}, buffer
+		}
+	}
+	buffer := make([]T, size)
+	return elefas.DataFrame[T]{Dims: dims, Data: buffer}, &buffer
+}
+
+// ParamCount does not count the bias of the depthwise convolution, which is always zero.
+func (s separableConvolution[T]) ParamCount() int {
+	return s.depthwise.kernel.TotalSize() + s.pointwise.ParamCount()
+}
+
+func (s separableConvolution[T]) Apply(input elefas.DataFrame[T]) elefas.DataFrame[T] {
+	return must(s.ApplyE(input))
+}
+
+func (s separableConvolution[T]) ApplyE(input elefas.DataFrame[T]) (elefas.DataFrame[T], error) {
+	return s.ApplyContext(context.Background(), input)
+}
+
+func (s separableConvolution[T]) OutputShape(inputShape []int) ([]int, error) {
+	shape, err := s.depthwise.OutputShape(inputShape)
+	if err != nil {
+		return nil, err
+	}
+	return s.pointwise.OutputShape(shape)
+}
+
+func (s separableConvolution[T]) ApplyContext(ctx context.Context, input elefas.DataFrame[T]) (elefas.DataFrame[T],
+	error) {
+
+	outputDims, err := s.OutputShape(input.Dims)
+	if err != nil {
+		return elefas.DataFrame[T]{}, err
+	}
+	output := elefas.MakeDataFrame[T](outputDims)
+	if err := s.ApplyIntoContext(ctx, output, input); err != nil {
+		return elefas.DataFrame[T]{}, err
+	}
+	return output, nil
+}
+
+func (s separableConvolution[T]) ApplyInto(dst, input elefas.DataFrame[T]) error {
+	return s.ApplyIntoContext(context.Background(), dst, input)
+}
+
+func (s separableConvolution[T]) ApplyIntoContext(ctx context.Context, dst, input elefas.DataFrame[T]) error {
+	depthwiseDims, err := s.depthwise.OutputShape(input.Dims)
+	if err != nil {
+		return err
+	}
+	depthwiseOutput, buffer := s.scratchBuffer(depthwiseDims)
+	if s.scratch != nil {
+		defer s.scratch.Put(buffer)
+	}
+	if err := s.depthwise.ApplyIntoContext(ctx, depthwiseOutput, input); err != nil {
+		return err
+	}
+	return s.pointwise.ApplyIntoContext(ctx, dst, depthwiseOutput)
+}
+
+// SeparableConv2D is Keras's SeparableConv2D layer. Its inputs have the shape (batch, height, width, channels), or
+// (batch, channels, height, width) with ChannelsFirst.
+type SeparableConv2D[T elefas.SizedNumber] struct {
+	separableConvolution[T]
+}
+
+// NewSeparableConv2D returns a SeparableConv2D layer with the weights Keras has: a depthwise kernel of shape (height,
+// width, input channels, depth multiplier), a pointwise kernel of shape (1, 1, input channels * depth multiplier,
+// filters), and a bias with one value per filter. The options cannot have Groups. It panics if the weights or the
+// options are invalid.
+func NewSeparableConv2D[T elefas.SizedNumber](depthwiseKernel, pointwiseKernel, bias elefas.DataFrame[T],
+	options ConvOptions) SeparableConv2D[T] {
+
+	s, err := newSeparableConvolution(2, depthwiseKernel, pointwiseKernel, bias, options)
+	if err != nil {
+		panic(err)
+	}
+	return SeparableConv2D[T]{s}
+}
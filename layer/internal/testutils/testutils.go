@@ -20,33 +20,63 @@ type PythonLayerData[T elefas.SizedNumber] struct {
 func TestLayerByPython[T elefas.SizedNumber](t *testing.T, pythonData PythonLayerData[T],
 	layer elefas.Layer[T], input elefas.DataFrame[T], epsilon T) {
 
-	weightsFile, err := os.CreateTemp("testdata", "weights_*.npz")
+	inputFile, err := os.CreateTemp("testdata", "input_*.npy")
 	if err != nil {
-		wd, _ := os.Getwd()
-		t.Fatalf("error creating temp weights file: %v (current wd: %s)", err, wd)
+		t.Fatalf("error creating temp input file: %v", err)
 	}
 	defer func() {
-		name := weightsFile.Name()
-		weightsFile.Close()
+		name := inputFile.Name()
+		inputFile.Close()
 		os.Remove(name)
 	}()
-	err = elefas.SaveNumpyDataFrames(pythonData.Weights, weightsFile)
+	err = elefas.SaveNumpyDataFrame(input, inputFile)
 	if err != nil {
-		t.Fatalf("error writing to temp weights file: %v", err)
+		t.Fatalf("error writing to temp input file: %v", err)
 	}
 
-	inputFile, err := os.CreateTemp("testdata", "input_*.npy")
+	pythonOutput := runPython(t, pythonData, inputFile.Name())
+	compareOutputs(t, layer.Apply(input), pythonOutput, epsilon)
+}
+
+// TestMultiInputLayerByPython is like TestLayerByPython, for layers with several inputs. Unlike TestLayerByPython,
+// the first dimension of the inputs is used as the batch dimension in Keras.
+func TestMultiInputLayerByPython[T elefas.SizedNumber](t *testing.T, pythonData PythonLayerData[T],
+	layer elefas.MultiInputLayer[T], inputs []elefas.DataFrame[T], epsilon T) {
+
+	inputsFile, err := os.CreateTemp("testdata", "inputs_*.npz")
 	if err != nil {
-		t.Fatalf("error creating temp input file: %v", err)
+		t.Fatalf("error creating temp inputs file: %v", err)
 	}
 	defer func() {
-		name := inputFile.Name()
-		inputFile.Close()
+		name := inputsFile.Name()
+		inputsFile.Close()
 		os.Remove(name)
 	}()
-	err = elefas.SaveNumpyDataFrame(input, inputFile)
+	err = elefas.SaveNumpyDataFrames(inputs, inputsFile)
 	if err != nil {
-		t.Fatalf("error writing to temp input file: %v", err)
+		t.Fatalf("error writing to temp inputs file: %v", err)
+	}
+
+	pythonOutput := runPython(t, pythonData, inputsFile.Name())
+	compareOutputs(t, layer.ApplyMulti(inputs), pythonOutput, epsilon)
+}
+
+func runPython[T elefas.SizedNumber](t *testing.T, pythonData PythonLayerData[T],
+	inputFileName string) elefas.DataFrame[T] {
+
+	weightsFile, err := os.CreateTemp("testdata", "weights_*.npz")
+	if err != nil {
+		wd, _ := os.Getwd()
+		t.Fatalf("error creating temp weights file: %v (current wd: %s)", err, wd)
+	}
+	defer func() {
+		name := weightsFile.Name()
+		weightsFile.Close()
+		os.Remove(name)
+	}()
+	err = elefas.SaveNumpyDataFrames(pythonData.Weights, weightsFile)
+	if err != nil {
+		t.Fatalf("error writing to temp weights file: %v", err)
 	}
 
 	outputFile, err := os.CreateTemp("testdata", "output_*.npy")
@@ -58,7 +88,7 @@ func TestLayerByPython[T elefas.SizedNumber](t *testing.T, pythonData PythonLaye
 	outputFile.Close()
 
 	pythonCommand := exec.Command("python3", "testdata/keras_script.py", pythonData.Name,
-		weightsFile.Name(), inputFile.Name(), outputFileName)
+		weightsFile.Name(), inputFileName, outputFileName)
 
 	if out, err := pythonCommand.CombinedOutput(); err != nil {
 		t.Fatalf("error running python script: %v; its output is:\n%s", err, out)
@@ -74,8 +104,12 @@ func TestLayerByPython[T elefas.SizedNumber](t *testing.T, pythonData PythonLaye
 	if err != nil {
 		t.Fatalf("error loading output: %v", err)
 	}
+	return pythonOutput
+}
+
+func compareOutputs[T elefas.SizedNumber](t *testing.T, computedOutput, pythonOutput elefas.DataFrame[T],
+	epsilon T) {
 
-	computedOutput := layer.Apply(input)
 	if computedOutput.DimCount() != pythonOutput.DimCount() {
 		t.Fatalf("computed output and python output have different number of dimensions: %d-%d",
 			computedOutput.DimCount(), pythonOutput.DimCount())
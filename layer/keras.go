@@ -0,0 +1,542 @@
+package layer
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/YohayAiTe/elefas"
+)
+
+func init() {
+	registerKerasLayers[float32]()
+	registerKerasLayers[float64]()
+}
+
+func registerKerasLayers[T elefas.SizedNumber]() {
+	elefas.RegisterKerasLayer[T]("Dense", denseFromKeras[T])
+	elefas.RegisterKerasLayer[T]("Flatten", flattenFromKeras[T])
+	elefas.RegisterKerasLayer[T]("Conv1D", conv1DFromKeras[T])
+	elefas.RegisterKerasLayer[T]("Conv2D", conv2DFromKeras[T])
+	elefas.RegisterKerasLayer[T]("Conv3D", conv3DFromKeras[T])
+	elefas.RegisterKerasLayer[T]("DepthwiseConv2D", depthwiseConv2DFromKeras[T])
+	elefas.RegisterKerasLayer[T]("SeparableConv2D", separableConv2DFromKeras[T])
+	elefas.RegisterKerasLayer[T]("Conv2DTranspose", conv2DTransposeFromKeras[T])
+	elefas.RegisterKerasLayer[T]("UpSampling1D", upSampling1DFromKeras[T])
+	elefas.RegisterKerasLayer[T]("UpSampling2D", upSampling2DFromKeras[T])
+	elefas.RegisterKerasLayer[T]("UpSampling3D", upSampling3DFromKeras[T])
+	elefas.RegisterKerasLayer[T]("MaxPooling1D", poolingFromKeras[T](1, false))
+	elefas.RegisterKerasLayer[T]("MaxPooling2D", poolingFromKeras[T](2, false))
+	elefas.RegisterKerasLayer[T]("MaxPooling3D", poolingFromKeras[T](3, false))
+	elefas.RegisterKerasLayer[T]("AveragePooling1D", poolingFromKeras[T](1, true))
+	elefas.RegisterKerasLayer[T]("AveragePooling2D", poolingFromKeras[T](2, true))
+	elefas.RegisterKerasLayer[T]("AveragePooling3D", poolingFromKeras[T](3, true))
+	for _, rank := range []string{"1D", "2D", "3D"} {
+		elefas.RegisterKerasLayer[T]("GlobalMaxPooling"+rank, globalPoolingFromKeras[T](false))
+		elefas.RegisterKerasLayer[T]("GlobalAveragePooling"+rank, globalPoolingFromKeras[T](true))
+	}
+	elefas.RegisterKerasLayer[T]("Activation", activationFromKeras[T])
+	elefas.RegisterKerasLayer[T]("ReLU", reluFromKeras[T])
+	elefas.RegisterKerasLayer[T]("Softmax", softmaxFromKeras[T])
+	elefas.RegisterKerasLayer[T]("ELU", eluFromKeras[T])
+	elefas.RegisterKerasMultiInputLayer[T]("Add", mergeFromKeras[T](Add[T]{}))
+	elefas.RegisterKerasMultiInputLayer[T]("Subtract", mergeFromKeras[T](Subtract[T]{}))
+	elefas.RegisterKerasMultiInputLayer[T]("Multiply", mergeFromKeras[T](Multiply[T]{}))
+	elefas.RegisterKerasMultiInputLayer[T]("Average", mergeFromKeras[T](Average[T]{}))
+	elefas.RegisterKerasMultiInputLayer[T]("Maximum", mergeFromKeras[T](Maximum[T]{}))
+	elefas.RegisterKerasMultiInputLayer[T]("Minimum", mergeFromKeras[T](Minimum[T]{}))
+	elefas.RegisterKerasMultiInputLayer[T]("Concatenate", concatenateFromKeras[T])
+	elefas.RegisterKerasMultiInputLayer[T]("Dot", dotFromKeras[T])
+	for _, className := range []string{"Dropout", "SpatialDropout1D", "SpatialDropout2D", "SpatialDropout3D",
+		"GaussianDropout", "GaussianNoise", "AlphaDropout", "ActivityRegularization"} {
+		elefas.RegisterKerasLayer[T](className, inferenceIdentityFromKeras[T])
+	}
+}
+
+func unmarshalKerasConfig(config elefas.KerasLayerConfig, v any) error {
+	if err := json.Unmarshal(config.Config, v); err != nil {
+		return fmt.Errorf("%w: %v", elefas.ErrInvalidKerasModel, err)
+	}
+	return nil
+}
+
+func checkKerasWeights[T elefas.SizedNumber](config elefas.KerasLayerConfig, weights []elefas.DataFrame[T],
+	count int) error {
+
+	if len(weights) != count {
+		return fmt.Errorf("%w: %s expects %d weights, got %d", elefas.ErrInvalidKerasModel, config.ClassName,
+			count, len(weights))
+	}
+	return nil
+}
+
+func denseFromKeras[T elefas.SizedNumber](config elefas.KerasLayerConfig, weights []elefas.DataFrame[T]) (
+	elefas.Layer[T], error) {
+
+	var c struct {
+		Units   int  `json:"units"`
+		UseBias bool `json:"use_bias"`
+	}
+	if err := unmarshalKerasConfig(config, &c); err != nil {
+		return nil, err
+	}
+	if !c.UseBias {
+		if err := checkKerasWeights(config, weights, 1); err != nil {
+			return nil, err
+		}
+		weights = append(weights, elefas.MakeDataFrame[T]([]int{c.Units}))
+	}
+	if err := checkKerasWeights(config, weights, 2); err != nil {
+		return nil, err
+	}
+	if weights[0].DimCount() != 2 || weights[1].DimCount() != 1 || weights[0].Dim(1) != weights[1].Dim(0) {
+		return nil, fmt.Errorf("%w: dense weights have shapes %v and %v", elefas.ErrInvalidKerasModel,
+			weights[0].Dims, weights[1].Dims)
+	}
+	return NewDense(weights[0], weights[1]), nil
+}
+
+func conv1DFromKeras[T elefas.SizedNumber](config elefas.KerasLayerConfig, weights []elefas.DataFrame[T]) (
+	elefas.Layer[T], error) {
+
+	c, err := convolutionFromKeras(1, config, weights)
+	if err != nil {
+		return nil, err
+	}
+	return Conv1D[T]{c}, nil
+}
+
+func conv2DFromKeras[T elefas.SizedNumber](config elefas.KerasLayerConfig, weights []elefas.DataFrame[T]) (
+	elefas.Layer[T], error) {
+
+	c, err := convolutionFromKeras(2, config, weights)
+	if err != nil {
+		return nil, err
+	}
+	return Conv2D[T]{c}, nil
+}
+
+func conv3DFromKeras[T elefas.SizedNumber](config elefas.KerasLayerConfig, weights []elefas.DataFrame[T]) (
+	elefas.Layer[T], error) {
+
+	c, err := convolutionFromKeras(3, config, weights)
+	if err != nil {
+		return nil, err
+	}
+	return Conv3D[T]{c}, nil
+}
+
+// kerasConvConfig is the configuration shared by Keras's convolution layers.
+type kerasConvConfig struct {
+	Filters       int        `json:"filters"`
+	Strides       []int      `json:"strides"`
+	Padding       Padding    `json:"padding"`
+	DataFormat    DataFormat `json:"data_format"`
+	DilationRate  []int      `json:"dilation_rate"`
+	Groups        int        `json:"groups"`
+	UseBias       bool       `json:"use_bias"`
+	OutputPadding []int      `json:"output_padding"`
+}
+
+func (c kerasConvConfig) options() ConvOptions {
+	return ConvOptions{Padding: c.Padding, Strides: c.Strides, DilationRate: c.DilationRate, Groups: c.Groups,
+		DataFormat: c.DataFormat, OutputPadding: c.OutputPadding}
+}
+
+// convolutionFromKeras reads the configuration and the weights shared by Keras's convolution layers.
+func convolutionFromKeras[T elefas.SizedNumber](rank int, config elefas.KerasLayerConfig,
+	weights []elefas.DataFrame[T]) (convolution[T], error) {
+
+	var c kerasConvConfig
+	if err := unmarshalKerasConfig(config, &c); err != nil {
+		return convolution[T]{}, err
+	}
+	if !c.UseBias {
+		if err := checkKerasWeights(config, weights, 1); err != nil {
+			return convolution[T]{}, err
+		}
+		weights = append(weights, elefas.MakeDataFrame[T]([]int{c.Filters}))
+	}
+	if err := checkKerasWeights(config, weights, 2); err != nil {
+		return convolution[T]{}, err
+	}
+	conv, err := newConvolution(rank, weights[0], weights[1], c.options())
+	if err != nil {
+		return convolution[T]{}, fmt.Errorf("%w: %s: %v", elefas.ErrInvalidKerasModel, config.ClassName, err)
+	}
+	return conv, nil
+}
+
+func depthwiseConv2DFromKeras[T elefas.SizedNumber](config elefas.KerasLayerConfig, weights []elefas.DataFrame[T]) (
+	elefas.Layer[T], error) {
+
+	var c kerasConvConfig
+	if err := unmarshalKerasConfig(config, &c); err != nil {
+		return nil, err
+	}
+	if !c.UseBias {
+		if err := checkKerasWeights(config, weights, 1); err != nil {
+			return nil, err
+		}
+		// the depthwise kernel has the shape (height, width, channels, depth multiplier)
+		if weights[0].DimCount() != 4 {
+			return nil, fmt.Errorf("%w: %s: depthwise kernel has shape %v", elefas.ErrInvalidKerasModel,
+				config.ClassName, weights[0].Dims)
+		}
+		weights = append(weights, elefas.MakeDataFrame[T]([]int{weights[0].Dim(2) * weights[0].Dim(3)}))
+	}
+	if err := checkKerasWeights(config, weights, 2); err != nil {
+		return nil, err
+	}
+	conv, err := newDepthwiseConvolution(2, weights[0], weights[1], c.options())
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s: %v", elefas.ErrInvalidKerasModel, config.ClassName, err)
+	}
+	return DepthwiseConv2D[T]{conv}, nil
+}
+
+func separableConv2DFromKeras[T elefas.SizedNumber](config elefas.KerasLayerConfig, weights []elefas.DataFrame[T]) (
+	elefas.Layer[T], error) {
+
+	var c kerasConvConfig
+	if err := unmarshalKerasConfig(config, &c); err != nil {
+		return nil, err
+	}
+	if !c.UseBias {
+		if err := checkKerasWeights(config, weights, 2); err != nil {
+			return nil, err
+		}
+		weights = append(weights, elefas.MakeDataFrame[T]([]int{c.Filters}))
+	}
+	if err := checkKerasWeights(config, weights, 3); err != nil {
+		return nil, err
+	}
+	s, err := newSeparableConvolution(2, weights[0], weights[1], weights[2], c.options())
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s: %v", elefas.ErrInvalidKerasModel, config.ClassName, err)
+	}
+	return SeparableConv2D[T]{s}, nil
+}
+
+func conv2DTransposeFromKeras[T elefas.SizedNumber](config elefas.KerasLayerConfig, weights []elefas.DataFrame[T]) (
+	elefas.Layer[T], error) {
+
+	var c kerasConvConfig
+	if err := unmarshalKerasConfig(config, &c); err != nil {
+		return nil, err
+	}
+	if !c.UseBias {
+		if err := checkKerasWeights(config, weights, 1); err != nil {
+			return nil, err
+		}
+		weights = append(weights, elefas.MakeDataFrame[T]([]int{c.Filters}))
+	}
+	if err := checkKerasWeights(config, weights, 2); err != nil {
+		return nil, err
+	}
+	conv, err := newTransposedConvolution(2, weights[0], weights[1], c.options())
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s: %v", elefas.ErrInvalidKerasModel, config.ClassName, err)
+	}
+	return Conv2DTranspose[T]{conv}, nil
+}
+
+func upSampling1DFromKeras[T elefas.SizedNumber](config elefas.KerasLayerConfig, weights []elefas.DataFrame[T]) (
+	elefas.Layer[T], error) {
+
+	c := struct {
+		Size int `json:"size"`
+	}{Size: 2}
+	if err := unmarshalKerasConfig(config, &c); err != nil {
+		return nil, err
+	}
+	if err := checkKerasWeights(config, weights, 0); err != nil {
+		return nil, err
+	}
+	u, err := newUpSampling[T](1, []int{c.Size}, InterpolationNearest, ChannelsLast)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s: %v", elefas.ErrInvalidKerasModel, config.ClassName, err)
+	}
+	return UpSampling1D[T]{u}, nil
+}
+
+func upSampling2DFromKeras[T elefas.SizedNumber](config elefas.KerasLayerConfig, weights []elefas.DataFrame[T]) (
+	elefas.Layer[T], error) {
+
+	var c struct {
+		Size          []int         `json:"size"`
+		DataFormat    DataFormat    `json:"data_format"`
+		Interpolation Interpolation `json:"interpolation"`
+	}
+	if err := unmarshalKerasConfig(config, &c); err != nil {
+		return nil, err
+	}
+	if err := checkKerasWeights(config, weights, 0); err != nil {
+		return nil, err
+	}
+	switch c.Interpolation {
+	case "", InterpolationNearest, InterpolationBilinear:
+	default:
+		return nil, fmt.Errorf("%w: %s interpolation", elefas.ErrUnsupportedLayer, c.Interpolation)
+	}
+	u, err := newUpSampling[T](2, c.Size, c.Interpolation, c.DataFormat)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s: %v", elefas.ErrInvalidKerasModel, config.ClassName, err)
+	}
+	return UpSampling2D[T]{u}, nil
+}
+
+func upSampling3DFromKeras[T elefas.SizedNumber](config elefas.KerasLayerConfig, weights []elefas.DataFrame[T]) (
+	elefas.Layer[T], error) {
+
+	var c struct {
+		Size       []int      `json:"size"`
+		DataFormat DataFormat `json:"data_format"`
+	}
+	if err := unmarshalKerasConfig(config, &c); err != nil {
+		return nil, err
+	}
+	if err := checkKerasWeights(config, weights, 0); err != nil {
+		return nil, err
+	}
+	u, err := newUpSampling[T](3, c.Size, InterpolationNearest, c.DataFormat)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s: %v", elefas.ErrInvalidKerasModel, config.ClassName, err)
+	}
+	return UpSampling3D[T]{u}, nil
+}
+
+// kerasInts reads a configuration that Keras saves as either an int or a list of ints, which is nil if null.
+func kerasInts(raw json.RawMessage) ([]int, error) {
+	if raw == nil || string(raw) == "null" {
+		return nil, nil
+	}
+	var n int
+	if json.Unmarshal(raw, &n) == nil {
+		return []int{n}, nil
+	}
+	var v []int
+	if err := json.Unmarshal(raw, &v); err != nil {
+		return nil, fmt.Errorf("%w: %v", elefas.ErrInvalidKerasModel, err)
+	}
+	return v, nil
+}
+
+// poolingFromKeras returns a factory for Keras's max or average pooling layers of the given rank.
+func poolingFromKeras[T elefas.SizedNumber](rank int, average bool) elefas.KerasLayerFactory[T] {
+	return func(config elefas.KerasLayerConfig, weights []elefas.DataFrame[T]) (elefas.Layer[T], error) {
+		var c struct {
+			PoolSize   json.RawMessage `json:"pool_size"`
+			Strides    json.RawMessage `json:"strides"`
+			Padding    Padding         `json:"padding"`
+			DataFormat DataFormat      `json:"data_format"`
+		}
+		if err := unmarshalKerasConfig(config, &c); err != nil {
+			return nil, err
+		}
+		if err := checkKerasWeights(config, weights, 0); err != nil {
+			return nil, err
+		}
+		poolSize, err := kerasInts(c.PoolSize)
+		if err != nil {
+			return nil, err
+		}
+		strides, err := kerasInts(c.Strides)
+		if err != nil {
+			return nil, err
+		}
+		p, err := newPooling[T](rank, PoolingOptions{PoolSize: poolSize, Strides: strides, Padding: c.Padding,
+			DataFormat: c.DataFormat}, average)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %s: %v", elefas.ErrInvalidKerasModel, config.ClassName, err)
+		}
+
+		switch {
+		case rank == 1 && average:
+			return AveragePooling1D[T]{p}, nil
+		case rank == 1:
+			return MaxPooling1D[T]{p}, nil
+		case rank == 2 && average:
+			return AveragePooling2D[T]{p}, nil
+		case rank == 2:
+			return MaxPooling2D[T]{p}, nil
+		case average:
+			return AveragePooling3D[T]{p}, nil
+		}
+		return MaxPooling3D[T]{p}, nil
+	}
+}
+
+// globalPoolingFromKeras returns a factory for Keras's global max or average pooling layers, of any rank.
+func globalPoolingFromKeras[T elefas.SizedNumber](average bool) elefas.KerasLayerFactory[T] {
+	return func(config elefas.KerasLayerConfig, weights []elefas.DataFrame[T]) (elefas.Layer[T], error) {
+		var c struct {
+			DataFormat DataFormat `json:"data_format"`
+			KeepDims   bool       `json:"keepdims"`
+		}
+		if err := unmarshalKerasConfig(config, &c); err != nil {
+			return nil, err
+		}
+		if err := checkKerasWeights(config, weights, 0); err != nil {
+			return nil, err
+		}
+		if _, err := isChannelsFirst(c.DataFormat); err != nil {
+			return nil, fmt.Errorf("%w: %s: %v", elefas.ErrInvalidKerasModel, config.ClassName, err)
+		}
+		if average {
+			return GlobalAveragePooling[T]{DataFormat: c.DataFormat, KeepDims: c.KeepDims}, nil
+		}
+		return GlobalMaxPooling[T]{DataFormat: c.DataFormat, KeepDims: c.KeepDims}, nil
+	}
+}
+
+func flattenFromKeras[T elefas.SizedNumber](config elefas.KerasLayerConfig, weights []elefas.DataFrame[T]) (
+	elefas.Layer[T], error) {
+
+	if err := checkKerasWeights(config, weights, 0); err != nil {
+		return nil, err
+	}
+	return Flatten[T]{}, nil
+}
+
+func activationFromKeras[T elefas.SizedNumber](config elefas.KerasLayerConfig, weights []elefas.DataFrame[T]) (
+	elefas.Layer[T], error) {
+
+	var c struct {
+		Activation string `json:"activation"`
+	}
+	if err := unmarshalKerasConfig(config, &c); err != nil {
+		return nil, err
+	}
+	switch c.Activation {
+	case "linear":
+		return nil, nil
+	case "relu":
+		return NewReLUActivation[T](), nil
+	case "sigmoid":
+		return &SigmoidActivation[T]{}, nil
+	case "softmax":
+		return &SoftmaxActivation[T]{Axis: -1}, nil
+	case "softplus":
+		return &SoftplusActivation[T]{}, nil
+	case "softsign":
+		return &SoftsignActivation[T]{}, nil
+	case "tanh":
+		return &TanhActivation[T]{}, nil
+	case "selu":
+		return &SeluActivation[T]{}, nil
+	case "elu":
+		return &EluActivation[T]{Alpha: 1}, nil
+	case "exponential":
+		return &ExponentialActivation[T]{}, nil
+	default:
+		return nil, fmt.Errorf("%w: activation %s", elefas.ErrUnsupportedLayer, c.Activation)
+	}
+}
+
+func reluFromKeras[T elefas.SizedNumber](config elefas.KerasLayerConfig, weights []elefas.DataFrame[T]) (
+	elefas.Layer[T], error) {
+
+	var c struct {
+		MaxValue      *float64 `json:"max_value"`
+		NegativeSlope float64  `json:"negative_slope"`
+		Threshold     float64  `json:"threshold"`
+	}
+	if err := unmarshalKerasConfig(config, &c); err != nil {
+		return nil, err
+	}
+	if err := checkKerasWeights(config, weights, 0); err != nil {
+		return nil, err
+	}
+	relu := NewReLUActivation[T]()
+	if c.MaxValue != nil {
+		relu.MaxValue = T(*c.MaxValue)
+	}
+	relu.NegativeSlope, relu.Threshold = T(c.NegativeSlope), T(c.Threshold)
+	return relu, nil
+}
+
+func softmaxFromKeras[T elefas.SizedNumber](config elefas.KerasLayerConfig, weights []elefas.DataFrame[T]) (
+	elefas.Layer[T], error) {
+
+	var c struct {
+		Axis json.RawMessage `json:"axis"`
+	}
+	if err := unmarshalKerasConfig(config, &c); err != nil {
+		return nil, err
+	}
+	if err := checkKerasWeights(config, weights, 0); err != nil {
+		return nil, err
+	}
+	axis := -1
+	if c.Axis != nil && json.Unmarshal(c.Axis, &axis) != nil {
+		return nil, fmt.Errorf("%w: softmax over several axes", elefas.ErrUnsupportedLayer)
+	}
+	return &SoftmaxActivation[T]{Axis: axis}, nil
+}
+
+func eluFromKeras[T elefas.SizedNumber](config elefas.KerasLayerConfig, weights []elefas.DataFrame[T]) (
+	elefas.Layer[T], error) {
+
+	c := struct {
+		Alpha float64 `json:"alpha"`
+	}{Alpha: 1}
+	if err := unmarshalKerasConfig(config, &c); err != nil {
+		return nil, err
+	}
+	if err := checkKerasWeights(config, weights, 0); err != nil {
+		return nil, err
+	}
+	return &EluActivation[T]{Alpha: T(c.Alpha)}, nil
+}
+
+// inferenceIdentityFromKeras is used for layers that only have an effect during training.
+func inferenceIdentityFromKeras[T elefas.SizedNumber](config elefas.KerasLayerConfig,
+	weights []elefas.DataFrame[T]) (elefas.Layer[T], error) {
+
+	return nil, checkKerasWeights(config, weights, 0)
+}
+
+// mergeFromKeras returns a factory for the merge layers that have no configuration.
+func mergeFromKeras[T elefas.SizedNumber](layer elefas.MultiInputLayer[T]) elefas.KerasMultiInputLayerFactory[T] {
+	return func(config elefas.KerasLayerConfig, weights []elefas.DataFrame[T]) (elefas.MultiInputLayer[T], error) {
+		return layer, checkKerasWeights(config, weights, 0)
+	}
+}
+
+func concatenateFromKeras[T elefas.SizedNumber](config elefas.KerasLayerConfig, weights []elefas.DataFrame[T]) (
+	elefas.MultiInputLayer[T], error) {
+
+	c := struct {
+		Axis int `json:"axis"`
+	}{Axis: -1}
+	if err := unmarshalKerasConfig(config, &c); err != nil {
+		return nil, err
+	}
+	if err := checkKerasWeights(config, weights, 0); err != nil {
+		return nil, err
+	}
+	return Concatenate[T]{Axis: c.Axis}, nil
+}
+
+func dotFromKeras[T elefas.SizedNumber](config elefas.KerasLayerConfig, weights []elefas.DataFrame[T]) (
+	elefas.MultiInputLayer[T], error) {
+
+	var c struct {
+		Axes      json.RawMessage `json:"axes"`
+		Normalize bool            `json:"normalize"`
+	}
+	if err := unmarshalKerasConfig(config, &c); err != nil {
+		return nil, err
+	}
+	if err := checkKerasWeights(config, weights, 0); err != nil {
+		return nil, err
+	}
+	var axis int
+	var axes []int
+	if json.Unmarshal(c.Axes, &axis) == nil {
+		axes = []int{axis, axis}
+	} else if json.Unmarshal(c.Axes, &axes) != nil || len(axes) != 2 {
+		return nil, fmt.Errorf("%w: dot axes %s", elefas.ErrInvalidKerasModel, c.Axes)
+	}
+	return Dot[T]{Axes: [2]int{axes[0], axes[1]}, Normalize: c.Normalize}, nil
+}
@@ -0,0 +1,66 @@
+package layer
+
+import "github.com/YohayAiTe/elefas"
+
+// must is used by the layers' Apply methods, which panic where ApplyE returns an error.
+func must[T elefas.SizedNumber](output elefas.DataFrame[T], err error) elefas.DataFrame[T] {
+	if err != nil {
+		panic(err)
+	}
+	return output
+}
+
+// dimsMatch reports whether two dimensions of shapes can be the same, where -1 stands for an unknown size. Only the
+// shapes of Model.Build have unknown sizes: the model rejects inputs with negative dimensions, so when it runs the
+// dimensions are matched exactly.
+func dimsMatch(a, b int) bool {
+	return a == -1 || b == -1 || a == b
+}
+
+// anyShape returns the shape of the given rank whose dimensions are all unknown.
+func anyShape(rank int) []int {
+	shape := make([]int, rank)
+	for i := range shape {
+		shape[i] = -1
+	}
+	return shape
+}
+
+// elementwiseShape is the OutputShape of layers that keep the shape of their input.
+func elementwiseShape(inputShape []int) ([]int, error) {
+	return append([]int{}, inputShape...), nil
+}
+
+// applyInto is the ApplyE of the layers that implement elefas.IntoLayer, which allocates their output.
+func applyInto[T elefas.SizedNumber](layer elefas.IntoLayer[T], input elefas.DataFrame[T]) (elefas.DataFrame[T],
+	error) {
+
+	outputDims, err := layer.OutputShape(input.Dims)
+	if err != nil {
+		return elefas.DataFrame[T]{}, err
+	}
+	output := elefas.MakeDataFrame[T](outputDims)
+	if err := layer.ApplyInto(output, input); err != nil {
+		return elefas.DataFrame[T]{}, err
+	}
+	return output, nil
+}
+
+// applyMultiInto is like applyInto, for the layers that implement elefas.MultiInputIntoLayer.
+func applyMultiInto[T elefas.SizedNumber](layer elefas.MultiInputIntoLayer[T], inputs []elefas.DataFrame[T]) (
+	elefas.DataFrame[T], error) {
+
+	inputShapes := make([][]int, len(inputs))
+	for i, input := range inputs {
+		inputShapes[i] = input.Dims
+	}
+	outputDims, err := layer.OutputShapeMulti(inputShapes)
+	if err != nil {
+		return elefas.DataFrame[T]{}, err
+	}
+	output := elefas.MakeDataFrame[T](outputDims)
+	if err := layer.ApplyMultiInto(output, inputs); err != nil {
+		return elefas.DataFrame[T]{}, err
+	}
+	return output, nil
+}
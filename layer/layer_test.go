@@ -0,0 +1,246 @@
+package layer_test
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/YohayAiTe/elefas"
+	"github.com/YohayAiTe/elefas/layer"
+)
+
+func TestOutputShape(t *testing.T) {
+	dense := layer.NewDense(elefas.MakeDataFrame[float32]([]int{3, 4}), elefas.MakeDataFrame[float32]([]int{4}))
+	conv := func(options layer.ConvOptions) layer.Conv2D[float32] {
+		kernel, bias := elefas.MakeDataFrame[float32]([]int{3, 3, 2, 4}), elefas.MakeDataFrame[float32]([]int{4})
+		return layer.NewConv2D(kernel, bias, options)
+	}
+	conv1D := func(options layer.ConvOptions) layer.Conv1D[float32] {
+		kernel, bias := elefas.MakeDataFrame[float32]([]int{3, 2, 4}), elefas.MakeDataFrame[float32]([]int{4})
+		return layer.NewConv1D(kernel, bias, options)
+	}
+	conv3D := layer.NewConv3D(elefas.MakeDataFrame[float32]([]int{2, 2, 2, 2, 4}),
+		elefas.MakeDataFrame[float32]([]int{4}), layer.ConvOptions{})
+	depthwise := layer.NewDepthwiseConv2D(elefas.MakeDataFrame[float32]([]int{3, 3, 2, 3}),
+		elefas.MakeDataFrame[float32]([]int{6}), layer.ConvOptions{Strides: []int{2, 2}})
+	separable := layer.NewSeparableConv2D(elefas.MakeDataFrame[float32]([]int{3, 3, 2, 3}),
+		elefas.MakeDataFrame[float32]([]int{1, 1, 6, 4}), elefas.MakeDataFrame[float32]([]int{4}),
+		layer.ConvOptions{Padding: layer.PaddingSame})
+	transpose := func(options layer.ConvOptions) layer.Conv2DTranspose[float32] {
+		kernel, bias := elefas.MakeDataFrame[float32]([]int{3, 3, 4, 2}), elefas.MakeDataFrame[float32]([]int{4})
+		return layer.NewConv2DTranspose(kernel, bias, options)
+	}
+	tests := []struct {
+		name     string
+		layer    elefas.ShapeLayer
+		input    []int
+		expected []int
+	}{
+		{"dense", dense, []int{-1, 3}, []int{-1, 4}},
+		{"dense 3d", dense, []int{2, 5, 3}, []int{2, 5, 4}},
+		{"dense unknown units", dense, []int{-1, -1}, []int{-1, 4}},
+		{"dense mismatch", dense, []int{-1, 2}, nil},
+		{"dense no dims", dense, []int{}, nil},
+		{"flatten", layer.Flatten[float32]{}, []int{-1, 2, 3}, []int{-1, 6}},
+		{"flatten 1d", layer.Flatten[float32]{}, []int{5}, []int{5, 1}},
+		{"flatten unknown", layer.Flatten[float32]{}, []int{-1, 2, -1}, []int{-1, -1}},
+		{"softmax", &layer.SoftmaxActivation[float32]{Axis: -1}, []int{-1, 3}, []int{-1, 3}},
+		{"softmax axis", &layer.SoftmaxActivation[float32]{Axis: 2}, []int{-1, 3}, nil},
+		{"relu", layer.NewReLUActivation[float32](), []int{-1, 3, 2}, []int{-1, 3, 2}},
+		{"conv2d", conv(layer.ConvOptions{}), []int{-1, 8, 7, 2}, []int{-1, 6, 5, 4}},
+		{"conv2d unknown", conv(layer.ConvOptions{}), []int{-1, -1, 7, -1}, []int{-1, -1, 5, 4}},
+		{"conv2d same", conv(layer.ConvOptions{Padding: layer.PaddingSame, Strides: []int{2, 3}}), []int{-1, 8, 7, 2},
+			[]int{-1, 4, 3, 4}},
+		{"conv2d dilated", conv(layer.ConvOptions{DilationRate: []int{2, 3}}), []int{-1, 8, 7, 2},
+			[]int{-1, 4, 1, 4}},
+		{"conv2d groups", conv(layer.ConvOptions{Groups: 2}), []int{-1, 8, 7, 4}, []int{-1, 6, 5, 4}},
+		{"conv2d channels first", conv(layer.ConvOptions{DataFormat: layer.ChannelsFirst}), []int{-1, 2, 8, 7},
+			[]int{-1, 4, 6, 5}},
+		{"conv2d channels", conv(layer.ConvOptions{}), []int{-1, 8, 7, 3}, nil},
+		{"conv2d rank", conv(layer.ConvOptions{}), []int{-1, 8, 2}, nil},
+		{"conv2d small", conv(layer.ConvOptions{DilationRate: []int{4, 1}}), []int{-1, 8, 7, 2}, nil},
+		{"conv1d causal", conv1D(layer.ConvOptions{Padding: layer.PaddingCausal, Strides: []int{2}}), []int{-1, 7, 2},
+			[]int{-1, 4, 4}},
+		{"conv3d", conv3D, []int{-1, 4, 5, 6, 2}, []int{-1, 3, 4, 5, 4}},
+		{"depthwise", depthwise, []int{-1, 8, 7, 2}, []int{-1, 3, 3, 6}},
+		{"depthwise channels", depthwise, []int{-1, 8, 7, 6}, nil},
+		{"separable", separable, []int{-1, 8, 7, 2}, []int{-1, 8, 7, 4}},
+		{"separable channels", separable, []int{-1, 8, 7, 3}, nil},
+		{"conv2d transpose", transpose(layer.ConvOptions{Strides: []int{2, 3}}), []int{-1, 4, 5, 2},
+			[]int{-1, 9, 15, 4}},
+		{"conv2d transpose same", transpose(layer.ConvOptions{Padding: layer.PaddingSame, Strides: []int{2, 3}}),
+			[]int{-1, 4, -1, 2}, []int{-1, 8, -1, 4}},
+		{"conv2d transpose output padding", transpose(layer.ConvOptions{Padding: layer.PaddingSame,
+			Strides: []int{2, 2}, OutputPadding: []int{1, 0}}), []int{-1, 4, 4, 2}, []int{-1, 8, 7, 4}},
+		{"conv2d transpose channels", transpose(layer.ConvOptions{}), []int{-1, 4, 4, 4}, nil},
+		{"up sampling 1d", layer.NewUpSampling1D[float32](nil), []int{-1, 3, 2}, []int{-1, 6, 2}},
+		{"up sampling 2d", layer.NewUpSampling2D[float32]([]int{2, 3}, layer.InterpolationNearest, layer.ChannelsLast),
+			[]int{-1, 3, -1, 2}, []int{-1, 6, -1, 2}},
+		{"up sampling 3d", layer.NewUpSampling3D[float32](nil, layer.ChannelsFirst), []int{-1, 2, 1, 2, 3},
+			[]int{-1, 2, 2, 4, 6}},
+		{"up sampling rank", layer.NewUpSampling2D[float32](nil, layer.InterpolationNearest, layer.ChannelsLast),
+			[]int{-1, 3, 2}, nil},
+		{"max pooling 1d", layer.NewMaxPooling1D[float32](layer.PoolingOptions{}), []int{-1, 7, 3}, []int{-1, 3, 3}},
+		{"max pooling 2d same", layer.NewMaxPooling2D[float32](layer.PoolingOptions{PoolSize: []int{3, 3},
+			Strides: []int{2, 1}, Padding: layer.PaddingSame}), []int{-1, 7, 5, 3}, []int{-1, 4, 5, 3}},
+		{"average pooling 3d", layer.NewAveragePooling3D[float32](layer.PoolingOptions{
+			DataFormat: layer.ChannelsFirst}), []int{-1, 3, 4, -1, 5}, []int{-1, 3, 2, -1, 2}},
+		{"pooling small", layer.NewMaxPooling2D[float32](layer.PoolingOptions{PoolSize: []int{3, 3}}),
+			[]int{-1, 2, 5, 3}, nil},
+		{"global pooling", layer.GlobalMaxPooling[float32]{}, []int{-1, 4, 5, 3}, []int{-1, 3}},
+		{"global pooling keepdims", layer.GlobalAveragePooling[float32]{DataFormat: layer.ChannelsFirst,
+			KeepDims: true}, []int{-1, 3, 4, 5}, []int{-1, 3, 1, 1}},
+		{"global pooling rank", layer.GlobalMaxPooling[float32]{}, []int{-1, 3}, nil},
+		{"global pooling empty", layer.GlobalAveragePooling[float32]{}, []int{1, 0, 2}, nil},
+		{"global pooling empty channels first", layer.GlobalMaxPooling[float32]{DataFormat: layer.ChannelsFirst},
+			[]int{-1, 3, 4, 0}, nil},
+		{"global pooling no channels", layer.GlobalMaxPooling[float32]{}, []int{-1, 4, 0}, []int{-1, 0}},
+	}
+	for _, test := range tests {
+		shape, err := test.layer.OutputShape(test.input)
+		if test.expected == nil {
+			if !errors.Is(err, elefas.ErrShapeMismatch) {
+				t.Errorf("%s: OutputShape(%v) returned %v, %v, expected a shape mismatch", test.name, test.input,
+					shape, err)
+			}
+		} else if err != nil || !reflect.DeepEqual(shape, test.expected) {
+			t.Errorf("%s: OutputShape(%v) returned %v, %v, expected %v", test.name, test.input, shape, err,
+				test.expected)
+		}
+	}
+
+	// there is nothing to average over empty spatial dimensions
+	empty := elefas.DataFrame[float32]{Dims: []int{1, 0, 2}, Data: []float32{}}
+	if _, err := (layer.GlobalAveragePooling[float32]{}).ApplyE(empty); !errors.Is(err, elefas.ErrShapeMismatch) {
+		t.Errorf("global average pooling of %v returned %v, expected a shape mismatch", empty.Dims, err)
+	}
+}
+
+func TestOutputShapeMulti(t *testing.T) {
+	tests := []struct {
+		name     string
+		layer    elefas.MultiInputShapeLayer
+		inputs   [][]int
+		expected []int
+	}{
+		{"add", layer.Add[float32]{}, [][]int{{-1, 3}, {-1, 3}}, []int{-1, 3}},
+		{"add broadcast", layer.Add[float32]{}, [][]int{{-1, 2, 3}, {-1, 3}, {-1, 2, 1}}, []int{-1, 2, 3}},
+		{"add unknown", layer.Add[float32]{}, [][]int{{-1, -1}, {-1, 3}}, []int{-1, 3}},
+		{"add mismatch", layer.Add[float32]{}, [][]int{{-1, 2}, {-1, 3}}, nil},
+		{"concatenate", layer.Concatenate[float32]{Axis: -1}, [][]int{{-1, 2, 3}, {-1, 2, 4}}, []int{-1, 2, 7}},
+		{"concatenate unknown", layer.Concatenate[float32]{Axis: 1}, [][]int{{-1, 2}, {-1, -1}}, []int{-1, -1}},
+		{"concatenate mismatch", layer.Concatenate[float32]{Axis: 1}, [][]int{{-1, 2, 3}, {-1, 2, 4}}, nil},
+		{"dot", layer.Dot[float32]{Axes: [2]int{1, 1}}, [][]int{{-1, 3}, {-1, 3}}, []int{-1, 1}},
+		{"dot 3d", layer.Dot[float32]{Axes: [2]int{2, 1}}, [][]int{{-1, 2, 3}, {-1, 3, 4}}, []int{-1, 2, 4}},
+		{"dot mismatch", layer.Dot[float32]{Axes: [2]int{1, 1}}, [][]int{{-1, 3}, {-1, 4}}, nil},
+	}
+	for _, test := range tests {
+		shape, err := test.layer.OutputShapeMulti(test.inputs)
+		if test.expected == nil {
+			if !errors.Is(err, elefas.ErrShapeMismatch) {
+				t.Errorf("%s: OutputShapeMulti(%v) returned %v, %v, expected a shape mismatch", test.name,
+					test.inputs, shape, err)
+			}
+		} else if err != nil || !reflect.DeepEqual(shape, test.expected) {
+			t.Errorf("%s: OutputShapeMulti(%v) returned %v, %v, expected %v", test.name, test.inputs, shape, err,
+				test.expected)
+		}
+	}
+}
+
+// TestApplyInto checks that the layers set all the elements of the DataFrame they write into, by writing into one
+// filled with garbage, and that the layers that support it can run in place.
+func TestApplyInto(t *testing.T) {
+	x, y := elefas.MakeDataFrame[float32]([]int{2, 3, 4}), elefas.MakeDataFrame[float32]([]int{2, 3, 4})
+	for i := range x.Data {
+		x.Data[i], y.Data[i] = float32(i%7)-3, float32(i%5)/4
+	}
+	image := elefas.MakeDataFrame[float32]([]int{2, 3, 2, 4})
+	for i := range image.Data {
+		image.Data[i] = float32(i%7) - 3
+	}
+	dirty := func(dims []int) elefas.DataFrame[float32] {
+		dst := elefas.MakeDataFrame[float32](dims)
+		for i := range dst.Data {
+			dst.Data[i] = 1234
+		}
+		return dst
+	}
+
+	layers := map[string]elefas.IntoLayer[float32]{
+		"dense": layer.NewDense(
+			elefas.MakeDataFrame[float32]([]int{4, 2}), elefas.MakeDataFrame[float32]([]int{2})),
+		"flatten":     layer.Flatten[float32]{},
+		"relu":        layer.NewReLUActivation[float32](),
+		"sigmoid":     &layer.SigmoidActivation[float32]{},
+		"softmax":     &layer.SoftmaxActivation[float32]{Axis: 1},
+		"softplus":    &layer.SoftplusActivation[float32]{},
+		"softsign":    &layer.SoftsignActivation[float32]{},
+		"tanh":        &layer.TanhActivation[float32]{},
+		"selu":        &layer.SeluActivation[float32]{},
+		"elu":         &layer.EluActivation[float32]{Alpha: 1},
+		"exponential": &layer.ExponentialActivation[float32]{},
+	}
+	// the convolution, up-sampling and pooling layers take images, of shape (batch, height, width, channels)
+	imageLayers := map[string]elefas.IntoLayer[float32]{
+		"conv2d": layer.NewConv2D(elefas.MakeDataFrame[float32]([]int{3, 2, 2, 2}),
+			elefas.MakeDataFrame[float32]([]int{2}), layer.ConvOptions{Padding: layer.PaddingSame, Groups: 2}),
+		"depthwise": layer.NewDepthwiseConv2D(elefas.MakeDataFrame[float32]([]int{2, 2, 4, 2}),
+			elefas.MakeDataFrame[float32]([]int{8}), layer.ConvOptions{Padding: layer.PaddingSame}),
+		"separable": layer.NewSeparableConv2D(elefas.MakeDataFrame[float32]([]int{3, 2, 4, 1}),
+			elefas.MakeDataFrame[float32]([]int{1, 1, 4, 3}), elefas.MakeDataFrame[float32]([]int{3}),
+			layer.ConvOptions{Padding: layer.PaddingSame}),
+		"conv2d transpose": layer.NewConv2DTranspose(elefas.MakeDataFrame[float32]([]int{3, 2, 3, 4}),
+			elefas.MakeDataFrame[float32]([]int{3}), layer.ConvOptions{Strides: []int{2, 2}}),
+		"up sampling": layer.NewUpSampling2D[float32]([]int{2, 3}, layer.InterpolationBilinear, layer.ChannelsLast),
+		"max pooling": layer.NewMaxPooling2D[float32](layer.PoolingOptions{Padding: layer.PaddingSame}),
+		"average pooling": layer.NewAveragePooling2D[float32](layer.PoolingOptions{PoolSize: []int{2, 2},
+			Strides: []int{1, 1}, Padding: layer.PaddingSame}),
+		"global pooling": layer.GlobalAveragePooling[float32]{KeepDims: true},
+	}
+	for _, test := range []struct {
+		input  elefas.DataFrame[float32]
+		layers map[string]elefas.IntoLayer[float32]
+	}{{x, layers}, {image, imageLayers}} {
+		x := test.input
+		for name, l := range test.layers {
+			dims, err := l.OutputShape(x.Dims)
+			if err != nil {
+				t.Fatalf("%s: %v", name, err)
+			}
+			dst := dirty(dims)
+			if err := l.ApplyInto(dst, x); err != nil || !reflect.DeepEqual(dst, l.Apply(x)) {
+				t.Errorf("%s: ApplyInto returned %v, %v, expected %v", name, dst, err, l.Apply(x))
+			}
+			if l, ok := l.(elefas.InPlaceLayer[float32]); ok && l.SupportsInPlace() {
+				dst := elefas.DataFrame[float32]{Dims: dims, Data: append([]float32{}, x.Data...)}
+				if err := l.ApplyInto(dst, elefas.DataFrame[float32]{Dims: x.Dims, Data: dst.Data}); err != nil ||
+					!reflect.DeepEqual(dst, l.Apply(x)) {
+					t.Errorf("%s: ApplyInto in place returned %v, %v, expected %v", name, dst, err, l.Apply(x))
+				}
+			}
+		}
+	}
+
+	multiLayers := map[string]elefas.MultiInputIntoLayer[float32]{
+		"add":         layer.Add[float32]{},
+		"subtract":    layer.Subtract[float32]{},
+		"multiply":    layer.Multiply[float32]{},
+		"average":     layer.Average[float32]{},
+		"maximum":     layer.Maximum[float32]{},
+		"minimum":     layer.Minimum[float32]{},
+		"concatenate": layer.Concatenate[float32]{Axis: 1},
+		"dot":         layer.Dot[float32]{Axes: [2]int{2, 2}, Normalize: true},
+	}
+	inputs := []elefas.DataFrame[float32]{x, y}
+	for name, l := range multiLayers {
+		dims, err := l.OutputShapeMulti([][]int{x.Dims, y.Dims})
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		dst := dirty(dims)
+		if err := l.ApplyMultiInto(dst, inputs); err != nil || !reflect.DeepEqual(dst, l.ApplyMulti(inputs)) {
+			t.Errorf("%s: ApplyMultiInto returned %v, %v, expected %v", name, dst, err, l.ApplyMulti(inputs))
+		}
+	}
+}
@@ -0,0 +1,456 @@
+package layer
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/YohayAiTe/elefas"
+)
+
+type Add[T elefas.SizedNumber] struct{}
+
+func (a Add[T]) ApplyMulti(inputs []elefas.DataFrame[T]) elefas.DataFrame[T] {
+	return must(a.ApplyMultiE(inputs))
+}
+
+func (a Add[T]) OutputShapeMulti(inputShapes [][]int) ([]int, error) {
+	return elementwiseMergeShape(inputShapes)
+}
+
+func (a Add[T]) ApplyMultiE(inputs []elefas.DataFrame[T]) (elefas.DataFrame[T], error) {
+	return applyMultiInto[T](a, inputs)
+}
+
+func (a Add[T]) ApplyMultiInto(dst elefas.DataFrame[T], inputs []elefas.DataFrame[T]) error {
+	return elementwiseMerge(dst, inputs, func(a, b T) T { return a + b })
+}
+
+type Subtract[T elefas.SizedNumber] struct{}
+
+func (s Subtract[T]) ApplyMulti(inputs []elefas.DataFrame[T]) elefas.DataFrame[T] {
+	return must(s.ApplyMultiE(inputs))
+}
+
+func (s Subtract[T]) OutputShapeMulti(inputShapes [][]int) ([]int, error) {
+	if len(inputShapes) != 2 {
+		return nil, fmt.Errorf("%w: subtract takes 2 inputs, got %d", elefas.ErrInvalidInput, len(inputShapes))
+	}
+	return elementwiseMergeShape(inputShapes)
+}
+
+func (s Subtract[T]) ApplyMultiE(inputs []elefas.DataFrame[T]) (elefas.DataFrame[T], error) {
+	return applyMultiInto[T](s, inputs)
+}
+
+func (s Subtract[T]) ApplyMultiInto(dst elefas.DataFrame[T], inputs []elefas.DataFrame[T]) error {
+	return elementwiseMerge(dst, inputs, func(a, b T) T { return a - b })
+}
+
+type Multiply[T elefas.SizedNumber] struct{}
+
+func (m Multiply[T]) ApplyMulti(inputs []elefas.DataFrame[T]) elefas.DataFrame[T] {
+	return must(m.ApplyMultiE(inputs))
+}
+
+func (m Multiply[T]) OutputShapeMulti(inputShapes [][]int) ([]int, error) {
+	return elementwiseMergeShape(inputShapes)
+}
+
+func (m Multiply[T]) ApplyMultiE(inputs []elefas.DataFrame[T]) (elefas.DataFrame[T], error) {
+	return applyMultiInto[T](m, inputs)
+}
+
+func (m Multiply[T]) ApplyMultiInto(dst elefas.DataFrame[T], inputs []elefas.DataFrame[T]) error {
+	return elementwiseMerge(dst, inputs, func(a, b T) T { return a * b })
+}
+
+type Average[T elefas.SizedNumber] struct{}
+
+func (a Average[T]) ApplyMulti(inputs []elefas.DataFrame[T]) elefas.DataFrame[T] {
+	return must(a.ApplyMultiE(inputs))
+}
+
+func (a Average[T]) OutputShapeMulti(inputShapes [][]int) ([]int, error) {
+	return elementwiseMergeShape(inputShapes)
+}
+
+func (a Average[T]) ApplyMultiE(inputs []elefas.DataFrame[T]) (elefas.DataFrame[T], error) {
+	return applyMultiInto[T](a, inputs)
+}
+
+func (a Average[T]) ApplyMultiInto(dst elefas.DataFrame[T], inputs []elefas.DataFrame[T]) error {
+	if err := elementwiseMerge(dst, inputs, func(a, b T) T { return a + b }); err != nil {
+		return err
+	}
+	for i := range dst.Data {
+		dst.Data[i] /= T(len(inputs))
+	}
+	return nil
+}
+
+type Maximum[T elefas.SizedNumber] struct{}
+
+func (m Maximum[T]) ApplyMulti(inputs []elefas.DataFrame[T]) elefas.DataFrame[T] {
+	return must(m.ApplyMultiE(inputs))
+}
+
+func (m Maximum[T]) OutputShapeMulti(inputShapes [][]int) ([]int, error) {
+	return elementwiseMergeShape(inputShapes)
+}
+
+func (m Maximum[T]) ApplyMultiE(inputs []elefas.DataFrame[T]) (elefas.DataFrame[T], error) {
+	return applyMultiInto[T](m, inputs)
+}
+
+func (m Maximum[T]) ApplyMultiInto(dst elefas.DataFrame[T], inputs []elefas.DataFrame[T]) error {
+	return elementwiseMerge(dst, inputs, func(a, b T) T {
+		if b > a {
+			return b
+		}
+		return a
+	})
+}
+
+type Minimum[T elefas.SizedNumber] struct{}
+
+func (m Minimum[T]) ApplyMulti(inputs []elefas.DataFrame[T]) elefas.DataFrame[T] {
+	return must(m.ApplyMultiE(inputs))
+}
+
+func (m Minimum[T]) OutputShapeMulti(inputShapes [][]int) ([]int, error) {
+	return elementwiseMergeShape(inputShapes)
+}
+
+func (m Minimum[T]) ApplyMultiE(inputs []elefas.DataFrame[T]) (elefas.DataFrame[T], error) {
+	return applyMultiInto[T](m, inputs)
+}
+
+func (m Minimum[T]) ApplyMultiInto(dst elefas.DataFrame[T], inputs []elefas.DataFrame[T]) error {
+	return elementwiseMerge(dst, inputs, func(a, b T) T {
+		if b < a {
+			return b
+		}
+		return a
+	})
+}
+
+// elementwiseMerge folds the inputs into output with combine element by element. Like Keras, inputs with fewer
+// dimensions get dimensions of size 1 inserted after the batch dimension, and dimensions of size 1 are broadcast.
+func elementwiseMerge[T elefas.SizedNumber](output elefas.DataFrame[T], inputs []elefas.DataFrame[T],
+	combine func(a, b T) T) error {
+
+	inputShapes := make([][]int, len(inputs))
+	for i, input := range inputs {
+		inputShapes[i] = input.Dims
+	}
+	shapes, dims, err := broadcastShapes(inputShapes)
+	if err != nil {
+		return err
+	}
+	rank := len(dims)
+
+	for k, input := range inputs {
+		strides := make([]int, rank)
+		stride := 1
+		for i := rank - 1; i >= 0; i-- {
+			if shapes[k][i] != 1 {
+				strides[i] = stride
+			}
+			stride *= shapes[k][i]
+		}
+
+		index := make([]int, rank)
+		offset := 0
+		for i := range output.Data {
+			if k == 0 {
+				output.Data[i] = input.Data[offset]
+			} else {
+				output.Data[i] = combine(output.Data[i], input.Data[offset])
+			}
+			for j := rank - 1; j >= 0; j-- {
+				index[j]++
+				offset += strides[j]
+				if index[j] < dims[j] {
+					break
+				}
+				index[j] = 0
+				offset -= strides[j] * dims[j]
+			}
+		}
+	}
+	return nil
+}
+
+type Concatenate[T elefas.SizedNumber] struct {
+	Axis int
+}
+
+func (c Concatenate[T]) ApplyMulti(inputs []elefas.DataFrame[T]) elefas.DataFrame[T] {
+	return must(c.ApplyMultiE(inputs))
+}
+
+func (c Concatenate[T]) OutputShapeMulti(inputShapes [][]int) ([]int, error) {
+	_, dims, err := c.shape(inputShapes)
+	return dims, err
+}
+
+// shape returns the axis of the inputs to concatenate on, and the shape of the output.
+func (c Concatenate[T]) shape(inputShapes [][]int) (axis int, dims []int, err error) {
+	if len(inputShapes) == 0 {
+		return 0, nil, fmt.Errorf("%w: concatenate applied to no inputs", elefas.ErrInvalidInput)
+	}
+	rank := len(inputShapes[0])
+	axis = c.Axis
+	if axis < 0 {
+		axis += rank
+	}
+	if axis < 0 || axis >= rank {
+		return 0, nil, &elefas.ShapeError{Actual: inputShapes[0],
+			Reason: fmt.Sprintf("concatenate axis %d is out of range", c.Axis)}
+	}
+
+	dims = append([]int{}, inputShapes[0]...)
+	dims[axis] = 0
+	for _, shape := range inputShapes {
+		mismatch := len(shape) != rank
+		for i := 0; !mismatch && i < rank; i++ {
+			mismatch = i != axis && !dimsMatch(shape[i], dims[i])
+		}
+		if mismatch {
+			expected := append([]int{}, inputShapes[0]...)
+			expected[axis] = -1
+			return 0, nil, &elefas.ShapeError{Expected: expected, Actual: shape,
+				Reason: fmt.Sprintf("cannot concatenate inputs on axis %d", axis)}
+		}
+		for i, d := range shape {
+			if i != axis && dims[i] == -1 {
+				dims[i] = d
+			}
+		}
+		if dims[axis] == -1 || shape[axis] == -1 {
+			dims[axis] = -1
+		} else {
+			dims[axis] += shape[axis]
+		}
+	}
+	return axis, dims, nil
+}
+
+func (c Concatenate[T]) ApplyMultiE(inputs []elefas.DataFrame[T]) (elefas.DataFrame[T], error) {
+	return applyMultiInto[T](c, inputs)
+}
+
+func (c Concatenate[T]) ApplyMultiInto(dst elefas.DataFrame[T], inputs []elefas.DataFrame[T]) error {
+	inputShapes := make([][]int, len(inputs))
+	for i, input := range inputs {
+		inputShapes[i] = input.Dims
+	}
+	axis, dims, err := c.shape(inputShapes)
+	if err != nil {
+		return err
+	}
+
+	outer := 1
+	for _, d := range dims[:axis] {
+		outer *= d
+	}
+	offset := 0
+	for i := 0; i < outer; i++ {
+		for _, input := range inputs {
+			block := input.TotalSize() / outer
+			offset += copy(dst.Data[offset:], input.Data[i*block:(i+1)*block])
+		}
+	}
+	return nil
+}
+
+// Dot computes the dot product of two inputs over Axes[0] of the first and Axes[1] of the second, for each sample
+// in the batch, like Keras's Dot. If Normalize is set, the inputs are L2-normalized over those axes first, so the
+// result is their cosine similarity.
+type Dot[T elefas.SizedNumber] struct {
+	Axes      [2]int
+	Normalize bool
+}
+
+func (d Dot[T]) ApplyMulti(inputs []elefas.DataFrame[T]) elefas.DataFrame[T] {
+	return must(d.ApplyMultiE(inputs))
+}
+
+func (d Dot[T]) OutputShapeMulti(inputShapes [][]int) ([]int, error) {
+	if len(inputShapes) != 2 {
+		return nil, fmt.Errorf("%w: dot takes 2 inputs, got %d", elefas.ErrInvalidInput, len(inputShapes))
+	}
+	_, _, dims, err := d.shape(inputShapes[0], inputShapes[1])
+	return dims, err
+}
+
+// shape returns the axes of the inputs to dot over, and the shape of the output.
+func (d Dot[T]) shape(x, y []int) (xAxis, yAxis int, dims []int, err error) {
+	for _, shape := range [][]int{x, y} {
+		if len(shape) < 2 {
+			return 0, 0, nil, &elefas.ShapeError{Expected: []int{-1, -1}, Actual: shape,
+				Reason: "dot inputs must have at least 2 dimensions"}
+		}
+	}
+	if !dimsMatch(x[0], y[0]) {
+		expected := anyShape(len(y))
+		expected[0] = x[0]
+		return 0, 0, nil, &elefas.ShapeError{Expected: expected, Actual: y,
+			Reason: "dot inputs have different batch sizes"}
+	}
+	xAxis, yAxis = d.Axes[0], d.Axes[1]
+	if xAxis < 0 {
+		xAxis += len(x)
+	}
+	if yAxis < 0 {
+		yAxis += len(y)
+	}
+	if xAxis <= 0 || xAxis >= len(x) || yAxis <= 0 || yAxis >= len(y) {
+		return 0, 0, nil, &elefas.ShapeError{Actual: y,
+			Reason: fmt.Sprintf("dot axes %v are out of range for an input of shape %v", d.Axes, x)}
+	}
+	if !dimsMatch(x[xAxis], y[yAxis]) {
+		expected := anyShape(len(y))
+		expected[yAxis] = x[xAxis]
+		return 0, 0, nil, &elefas.ShapeError{Expected: expected, Actual: y,
+			Reason: fmt.Sprintf("cannot dot an input of shape %v over axes %d and %d", x, xAxis, yAxis)}
+	}
+
+	batch := x[0]
+	if batch == -1 {
+		batch = y[0]
+	}
+	dims = []int{batch}
+	dims = append(dims, x[1:xAxis]...)
+	dims = append(dims, x[xAxis+1:]...)
+	dims = append(dims, y[1:yAxis]...)
+	dims = append(dims, y[yAxis+1:]...)
+	if len(dims) == 1 {
+		dims = append(dims, 1)
+	}
+	return xAxis, yAxis, dims, nil
+}
+
+func (d Dot[T]) ApplyMultiE(inputs []elefas.DataFrame[T]) (elefas.DataFrame[T], error) {
+	return applyMultiInto[T](d, inputs)
+}
+
+func (d Dot[T]) ApplyMultiInto(dst elefas.DataFrame[T], inputs []elefas.DataFrame[T]) error {
+	if len(inputs) != 2 {
+		return fmt.Errorf("%w: dot takes 2 inputs, got %d", elefas.ErrInvalidInput, len(inputs))
+	}
+	x, y := inputs[0], inputs[1]
+	xAxis, yAxis, _, err := d.shape(x.Dims, y.Dims)
+	if err != nil {
+		return err
+	}
+	if d.Normalize {
+		x, y = l2Normalize(x, xAxis), l2Normalize(y, yAxis)
+	}
+
+	batch, length := x.Dim(0), x.Dim(xAxis)
+	xPre, xPost := dimsProduct(x.Dims[1:xAxis]), dimsProduct(x.Dims[xAxis+1:])
+	yPre, yPost := dimsProduct(y.Dims[1:yAxis]), dimsProduct(y.Dims[yAxis+1:])
+
+	xRest, yRest := xPre*xPost, yPre*yPost
+	i := 0
+	for b := 0; b < batch; b++ {
+		xBatch := x.Data[b*xRest*length : (b+1)*xRest*length]
+		yBatch := y.Data[b*yRest*length : (b+1)*yRest*length]
+		for xi := 0; xi < xRest; xi++ {
+			xStart := (xi/xPost)*length*xPost + xi%xPost
+			for yi := 0; yi < yRest; yi++ {
+				yStart := (yi/yPost)*length*yPost + yi%yPost
+				var sum T
+				for k := 0; k < length; k++ {
+					sum += xBatch[xStart+k*xPost] * yBatch[yStart+k*yPost]
+				}
+				dst.Data[i] = sum
+				i++
+			}
+		}
+	}
+	return nil
+}
+
+// elementwiseMergeShape is the OutputShapeMulti of the layers that use elementwiseMerge.
+func elementwiseMergeShape(inputShapes [][]int) ([]int, error) {
+	_, dims, err := broadcastShapes(inputShapes)
+	return dims, err
+}
+
+// broadcastShapes returns the shapes of the inputs of elementwiseMerge, with the dimensions of size 1 inserted, and
+// the shape of its output.
+func broadcastShapes(inputShapes [][]int) (shapes [][]int, dims []int, err error) {
+	if len(inputShapes) == 0 {
+		return nil, nil, fmt.Errorf("%w: merge layer applied to no inputs", elefas.ErrInvalidInput)
+	}
+	rank := 0
+	for _, shape := range inputShapes {
+		if len(shape) > rank {
+			rank = len(shape)
+		}
+	}
+	shapes = make([][]int, len(inputShapes))
+	for i, shape := range inputShapes {
+		if len(shape) == 0 {
+			return nil, nil, &elefas.ShapeError{Expected: []int{-1}, Actual: shape,
+				Reason: "merge layer's inputs must have a batch dimension"}
+		}
+		shapes[i] = make([]int, 0, rank)
+		shapes[i] = append(shapes[i], shape[0])
+		for j := len(shape); j < rank; j++ {
+			shapes[i] = append(shapes[i], 1)
+		}
+		shapes[i] = append(shapes[i], shape[1:]...)
+	}
+	dims = make([]int, rank)
+	for i := range dims {
+		dims[i] = 1
+		for _, shape := range shapes {
+			switch {
+			case shape[i] == 1:
+			case shape[i] == -1:
+				if dims[i] == 1 {
+					dims[i] = -1
+				}
+			case dims[i] == 1 || dims[i] == -1:
+				dims[i] = shape[i]
+			case dims[i] != shape[i]:
+				return nil, nil, &elefas.ShapeError{Actual: shape,
+					Reason: fmt.Sprintf("cannot merge inputs of shapes %v", shapes)}
+			}
+		}
+	}
+	return shapes, dims, nil
+}
+
+func dimsProduct(dims []int) int {
+	product := 1
+	for _, d := range dims {
+		product *= d
+	}
+	return product
+}
+
+// l2Normalize divides input by its L2 norm over axis, the way Keras's Dot does.
+func l2Normalize[T elefas.SizedNumber](input elefas.DataFrame[T], axis int) elefas.DataFrame[T] {
+	const epsilon = 1e-7
+	pre, length, post := dimsProduct(input.Dims[:axis]), input.Dim(axis), dimsProduct(input.Dims[axis+1:])
+	output := elefas.MakeDataFrame[T](input.Dims)
+	for p := 0; p < pre; p++ {
+		for q := 0; q < post; q++ {
+			start := p*length*post + q
+			var sum float64
+			for k := 0; k < length; k++ {
+				v := float64(input.Data[start+k*post])
+				sum += v * v
+			}
+			norm := math.Sqrt(math.Max(sum, epsilon))
+			for k := 0; k < length; k++ {
+				output.Data[start+k*post] = T(float64(input.Data[start+k*post]) / norm)
+			}
+		}
+	}
+	return output
+}
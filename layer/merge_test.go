@@ -0,0 +1,183 @@
+package layer_test
+
+import (
+	"math/rand"
+	"strings"
+	"testing"
+
+	"github.com/YohayAiTe/elefas"
+	"github.com/YohayAiTe/elefas/layer"
+	"github.com/YohayAiTe/elefas/layer/internal/testutils"
+)
+
+func mergeTestName(dims [][]int) string {
+	names := make([]string, len(dims))
+	for i, d := range dims {
+		names[i] = testutils.DimString(d)
+	}
+	return strings.Join(names, ",")
+}
+
+func mergeTestFunc[T elefas.SizedNumber](t *testing.T, r *rand.Rand, name string, layer elefas.MultiInputLayer[T],
+	weights []elefas.DataFrame[T], testCases [][][]int, epsilon T) {
+
+	for _, testCase := range testCases {
+		t.Run(mergeTestName(testCase), func(t *testing.T) {
+			inputs := make([]elefas.DataFrame[T], len(testCase))
+			for i, dims := range testCase {
+				inputs[i] = testutils.RandomDataFrame[T](r, dims)
+			}
+			testutils.TestMultiInputLayerByPython(t, testutils.PythonLayerData[T]{
+				Name:    name,
+				Weights: weights,
+			}, layer, inputs, epsilon)
+		})
+	}
+}
+
+var elementwiseMergeTestCases = [][][]int{
+	{{1, 5}, {1, 5}},
+	{{2, 3}, {2, 3}, {2, 3}},
+	{{4, 2, 3}, {4, 2, 3}},
+	{{3, 2, 4}, {3, 1, 4}},
+	{{3, 2, 4}, {3, 4}},
+}
+
+func TestAdd(t *testing.T) {
+	t.Parallel()
+	r := rand.New(rand.NewSource(0))
+	t.Run("float32", func(t *testing.T) {
+		mergeTestFunc[float32](t, r, "add", layer.Add[float32]{}, nil, elementwiseMergeTestCases, 1e-5)
+	})
+	t.Run("float64", func(t *testing.T) {
+		mergeTestFunc[float64](t, r, "add", layer.Add[float64]{}, nil, elementwiseMergeTestCases, 1e-5)
+	})
+}
+
+func TestSubtract(t *testing.T) {
+	t.Parallel()
+	r := rand.New(rand.NewSource(0))
+	testCases := [][][]int{{{1, 5}, {1, 5}}, {{4, 2, 3}, {4, 2, 3}}, {{3, 2, 4}, {3, 1, 4}}}
+	t.Run("float32", func(t *testing.T) {
+		mergeTestFunc[float32](t, r, "subtract", layer.Subtract[float32]{}, nil, testCases, 1e-5)
+	})
+	t.Run("float64", func(t *testing.T) {
+		mergeTestFunc[float64](t, r, "subtract", layer.Subtract[float64]{}, nil, testCases, 1e-5)
+	})
+}
+
+func TestMultiply(t *testing.T) {
+	t.Parallel()
+	r := rand.New(rand.NewSource(0))
+	t.Run("float32", func(t *testing.T) {
+		mergeTestFunc[float32](t, r, "multiply", layer.Multiply[float32]{}, nil, elementwiseMergeTestCases, 1e-5)
+	})
+	t.Run("float64", func(t *testing.T) {
+		mergeTestFunc[float64](t, r, "multiply", layer.Multiply[float64]{}, nil, elementwiseMergeTestCases, 1e-5)
+	})
+}
+
+func TestAverage(t *testing.T) {
+	t.Parallel()
+	r := rand.New(rand.NewSource(0))
+	t.Run("float32", func(t *testing.T) {
+		mergeTestFunc[float32](t, r, "average", layer.Average[float32]{}, nil, elementwiseMergeTestCases, 1e-5)
+	})
+	t.Run("float64", func(t *testing.T) {
+		mergeTestFunc[float64](t, r, "average", layer.Average[float64]{}, nil, elementwiseMergeTestCases, 1e-5)
+	})
+}
+
+func TestMaximum(t *testing.T) {
+	t.Parallel()
+	r := rand.New(rand.NewSource(0))
+	t.Run("float32", func(t *testing.T) {
+		mergeTestFunc[float32](t, r, "maximum", layer.Maximum[float32]{}, nil, elementwiseMergeTestCases, 1e-5)
+	})
+	t.Run("float64", func(t *testing.T) {
+		mergeTestFunc[float64](t, r, "maximum", layer.Maximum[float64]{}, nil, elementwiseMergeTestCases, 1e-5)
+	})
+}
+
+func TestMinimum(t *testing.T) {
+	t.Parallel()
+	r := rand.New(rand.NewSource(0))
+	t.Run("float32", func(t *testing.T) {
+		mergeTestFunc[float32](t, r, "minimum", layer.Minimum[float32]{}, nil, elementwiseMergeTestCases, 1e-5)
+	})
+	t.Run("float64", func(t *testing.T) {
+		mergeTestFunc[float64](t, r, "minimum", layer.Minimum[float64]{}, nil, elementwiseMergeTestCases, 1e-5)
+	})
+}
+
+type concatenateTestCase struct {
+	axis int
+	dims [][]int
+}
+
+func concatenateTestFunc[T elefas.SizedNumber](t *testing.T, r *rand.Rand, testCases []concatenateTestCase,
+	epsilon T) {
+
+	for _, testCase := range testCases {
+		axis := elefas.DataFrame[T]{Dims: []int{1}, Data: []T{T(testCase.axis)}}
+		mergeTestFunc[T](t, r, "concatenate", layer.Concatenate[T]{Axis: testCase.axis}, []elefas.DataFrame[T]{axis},
+			[][][]int{testCase.dims}, epsilon)
+	}
+}
+
+func TestConcatenate(t *testing.T) {
+	t.Parallel()
+	r := rand.New(rand.NewSource(0))
+	testCases := []concatenateTestCase{
+		{-1, [][]int{{2, 3}, {2, 4}}},
+		{1, [][]int{{2, 3}, {2, 1}, {2, 5}}},
+		{1, [][]int{{2, 3, 4}, {2, 1, 4}}},
+		{2, [][]int{{2, 3, 4}, {2, 3, 2}}},
+		{-2, [][]int{{3, 2, 4, 2}, {3, 5, 4, 2}}},
+	}
+	t.Run("float32", func(t *testing.T) {
+		concatenateTestFunc[float32](t, r, testCases, 1e-5)
+	})
+	t.Run("float64", func(t *testing.T) {
+		concatenateTestFunc[float64](t, r, testCases, 1e-5)
+	})
+}
+
+type dotTestCase struct {
+	axes      [2]int
+	normalize bool
+	dims      [][]int
+}
+
+func dotTestFunc[T elefas.SizedNumber](t *testing.T, r *rand.Rand, testCases []dotTestCase, epsilon T) {
+	for _, testCase := range testCases {
+		var normalize T
+		if testCase.normalize {
+			normalize = 1
+		}
+		config := elefas.DataFrame[T]{
+			Dims: []int{3},
+			Data: []T{T(testCase.axes[0]), T(testCase.axes[1]), normalize},
+		}
+		mergeTestFunc[T](t, r, "dot", layer.Dot[T]{Axes: testCase.axes, Normalize: testCase.normalize},
+			[]elefas.DataFrame[T]{config}, [][][]int{testCase.dims}, epsilon)
+	}
+}
+
+func TestDot(t *testing.T) {
+	t.Parallel()
+	r := rand.New(rand.NewSource(0))
+	testCases := []dotTestCase{
+		{[2]int{1, 1}, false, [][]int{{2, 5}, {2, 5}}},
+		{[2]int{1, 1}, true, [][]int{{2, 5}, {2, 5}}},
+		{[2]int{-1, -1}, false, [][]int{{3, 4, 5}, {3, 2, 5}}},
+		{[2]int{1, 2}, false, [][]int{{2, 20, 1}, {2, 30, 20}}},
+		{[2]int{2, 1}, true, [][]int{{2, 3, 4}, {2, 4, 6}}},
+	}
+	t.Run("float32", func(t *testing.T) {
+		dotTestFunc[float32](t, r, testCases, 1e-4)
+	})
+	t.Run("float64", func(t *testing.T) {
+		dotTestFunc[float64](t, r, testCases, 1e-5)
+	})
+}
@@ -0,0 +1,385 @@
+package layer
+
+import (
+	"fmt"
+
+	"github.com/YohayAiTe/elefas"
+)
+
+// PoolingOptions are the options of the max and average pooling layers. PoolSize and Strides have a size for every
+// spatial dimension, so a single one for the 1D layers, where Keras takes an int. A nil PoolSize is Keras's default of
+// 2 in every spatial dimension, and nil Strides are the pool size.
+type PoolingOptions struct {
+	PoolSize, Strides []int
+	Padding           Padding
+	DataFormat        DataFormat
+}
+
+// pooling is the core of the pooling layers, for any number of spatial dimensions, which they embed. It is validated
+// once, when the layer is made.
+type pooling[T elefas.SizedNumber] struct {
+	poolSize, strides      []int
+	padding                Padding
+	average, channelsFirst bool
+}
+
+// newPooling returns the core of a pooling layer of the given rank.
+func newPooling[T elefas.SizedNumber](rank int, options PoolingOptions, average bool) (pooling[T], error) {
+	// the layer keeps its own copies, which the caller cannot change
+	poolSize, strides := append([]int(nil), options.PoolSize...), append([]int(nil), options.Strides...)
+	p := pooling[T]{poolSize: poolSize, strides: strides, padding: options.Padding, average: average}
+	if p.poolSize == nil {
+		p.poolSize = make([]int, rank)
+		for i := range p.poolSize {
+			p.poolSize[i] = 2
+		}
+	}
+	if p.strides == nil {
+		p.strides = p.poolSize
+	}
+	for _, v := range [][]int{p.poolSize, p.strides} {
+		if len(v) != rank {
+			return pooling[T]{}, fmt.Errorf("%dD pooling takes %d pool sizes and strides, got %v and %v", rank, rank,
+				poolSize, strides)
+		}
+		for _, n := range v {
+			if n < 1 {
+				return pooling[T]{}, fmt.Errorf("pool size and strides must be positive, got %v and %v", poolSize,
+					strides)
+			}
+		}
+	}
+	switch p.padding {
+	case "":
+		p.padding = PaddingValid
+	case PaddingValid, PaddingSame:
+	default:
+		return pooling[T]{}, fmt.Errorf("pooling cannot take padding %q", p.padding)
+	}
+	var err error
+	if p.channelsFirst, err = isChannelsFirst(options.DataFormat); err != nil {
+		return pooling[T]{}, err
+	}
+	return p, nil
+}
+
+func (p pooling[T]) Apply(input elefas.DataFrame[T]) elefas.DataFrame[T] {
+	return must(p.ApplyE(input))
+}
+
+func (p pooling[T]) ApplyE(input elefas.DataFrame[T]) (elefas.DataFrame[T], error) {
+	return applyInto[T](p, input)
+}
+
+func (p pooling[T]) OutputShape(inputShape []int) ([]int, error) {
+	rank := len(p.poolSize)
+	if len(inputShape) != rank+2 {
+		return nil, &elefas.ShapeError{Expected: anyShape(rank + 2), Actual: inputShape,
+			Reason: fmt.Sprintf("%dD pooling's input must have %d dimensions", rank, rank+2)}
+	}
+	spatialAxis := 1
+	if p.channelsFirst {
+		spatialAxis = 2
+	}
+	outputShape := append([]int{}, inputShape...)
+	for i := 0; i < rank; i++ {
+		size := inputShape[spatialAxis+i]
+		if size == -1 {
+			continue
+		}
+		if p.padding == PaddingValid && size < p.poolSize[i] {
+			return nil, &elefas.ShapeError{Actual: inputShape,
+				Reason: fmt.Sprintf("%dD pooling's input is smaller than its pool size %v", rank, p.poolSize)}
+		}
+		outputShape[spatialAxis+i] = p.outputSize(i, size)
+	}
+	return outputShape, nil
+}
+
+// outputSize returns the size of the output in the i-th spatial dimension, for an input of the given size.
+func (p pooling[T]) outputSize(i, size int) int {
+	if p.padding == PaddingSame {
+		return (size + p.strides[i] - 1) / p.strides[i]
+	}
+	return (size-p.poolSize[i])/p.strides[i] + 1
+}
+
+// padBefore returns the padding before the input in the i-th spatial dimension, like convolution's.
+func (p pooling[T]) padBefore(i, inputSize, outputSize int) int {
+	if p.padding == PaddingValid {
+		return 0
+	}
+	total := (outputSize-1)*p.strides[i] + p.poolSize[i] - inputSize
+	if total < 0 {
+		return 0
+	}
+	return total / 2
+}
+
+// ApplyInto leaves the padding out of the pools: max pooling never picks it, and average pooling divides by the number
+// of elements of the input in the pool.
+func (p pooling[T]) ApplyInto(dst, input elefas.DataFrame[T]) error {
+	rank := len(p.poolSize)
+	in, out := newSpatialLayout(input.Dims, p.channelsFirst), newSpatialLayout(dst.Dims, p.channelsFirst)
+	channels := input.Dim(rank + 1)
+	if p.channelsFirst {
+		channels = input.Dim(1)
+	}
+	// start and end are the bounds of the current pool in the input, cut to the input
+	start, end := make([]int, rank), make([]int, rank)
+	pad := make([]int, rank)
+	for i := range pad {
+		pad[i] = p.padBefore(i, in.spatial[i], out.spatial[i])
+	}
+
+	outPositions := dimsProduct(out.spatial)
+	index, poolIndex, poolDims := make([]int, rank), make([]int, rank), make([]int, rank)
+	for b := 0; b < input.Dim(0); b++ {
+		for o := 0; o < outPositions; o++ {
+			outBase, count := b*out.batchStride, 1
+			for i := range index {
+				outBase += index[i] * out.strides[i]
+				start[i] = index[i]*p.strides[i] - pad[i]
+				end[i] = start[i] + p.poolSize[i]
+				if start[i] < 0 {
+					start[i] = 0
+				}
+				if end[i] > in.spatial[i] {
+					end[i] = in.spatial[i]
+				}
+				poolDims[i] = end[i] - start[i]
+				count *= poolDims[i]
+			}
+
+			for c := 0; c < channels; c++ {
+				var value T
+				for k := 0; k < count; k++ {
+					offset := b*in.batchStride + c*in.channelStride
+					for i := range poolIndex {
+						offset += (start[i] + poolIndex[i]) * in.strides[i]
+					}
+					switch x := input.Data[offset]; {
+					case k == 0:
+						value = x
+					case p.average:
+						value += x
+					case x > value:
+						value = x
+					}
+					nextIndex(poolIndex, poolDims)
+				}
+				if p.average {
+					value /= T(count)
+				}
+				dst.Data[outBase+c*out.channelStride] = value
+			}
+			nextIndex(index, out.spatial)
+		}
+	}
+	return nil
+}
+
+// MaxPooling1D is Keras's MaxPooling1D layer. Its inputs have the shape (batch, steps, features), or (batch,
+// features, steps) with ChannelsFirst.
+type MaxPooling1D[T elefas.SizedNumber] struct {
+	pooling[T]
+}
+
+// NewMaxPooling1D returns a MaxPooling1D layer. It panics if the options are invalid.
+func NewMaxPooling1D[T elefas.SizedNumber](options PoolingOptions) MaxPooling1D[T] {
+	p, err := newPooling[T](1, options, false)
+	if err != nil {
+		panic(err)
+	}
+	return MaxPooling1D[T]{p}
+}
+
+// MaxPooling2D is Keras's MaxPooling2D layer. Its inputs have the shape (batch, height, width, channels), or (batch,
+// channels, height, width) with ChannelsFirst.
+type MaxPooling2D[T elefas.SizedNumber] struct {
+	pooling[T]
+}
+
+// NewMaxPooling2D returns a MaxPooling2D layer. It panics if the options are invalid.
+func NewMaxPooling2D[T elefas.SizedNumber](options PoolingOptions) MaxPooling2D[T] {
+	p, err := newPooling[T](2, options, false)
+	if err != nil {
+		panic(err)
+	}
+	return MaxPooling2D[T]{p}
+}
+
+// MaxPooling3D is Keras's MaxPooling3D layer. Its inputs have the shape (batch, depth, height, width, channels), or
+// (batch, channels, depth, height, width) with ChannelsFirst.
+type MaxPooling3D[T elefas.SizedNumber] struct {
+	pooling[T]
+}
+
+// NewMaxPooling3D returns a MaxPooling3D layer. It panics if the options are invalid.
+func NewMaxPooling3D[T elefas.SizedNumber](options PoolingOptions) MaxPooling3D[T] {
+	p, err := newPooling[T](3, options, false)
+	if err != nil {
+		panic(err)
+	}
+	return MaxPooling3D[T]{p}
+}
+
+// AveragePooling1D is Keras's AveragePooling1D layer, with the inputs of MaxPooling1D.
+type AveragePooling1D[T elefas.SizedNumber] struct {
+	pooling[T]
+}
+
+// NewAveragePooling1D returns an AveragePooling1D layer. It panics if the options are invalid.
+func NewAveragePooling1D[T elefas.SizedNumber](options PoolingOptions) AveragePooling1D[T] {
+	p, err := newPooling[T](1, options, true)
+	if err != nil {
+		panic(err)
+	}
+	return AveragePooling1D[T]{p}
+}
+
+// AveragePooling2D is Keras's AveragePooling2D layer, with the inputs of MaxPooling2D.
+type AveragePooling2D[T elefas.SizedNumber] struct {
+	pooling[T]
+}
+
+// NewAveragePooling2D returns an AveragePooling2D layer. It panics if the options are invalid.
+func NewAveragePooling2D[T elefas.SizedNumber](options PoolingOptions) AveragePooling2D[T] {
+	p, err := newPooling[T](2, options, true)
+	if err != nil {
+		panic(err)
+	}
+	return AveragePooling2D[T]{p}
+}
+
+// AveragePooling3D is Keras's AveragePooling3D layer, with the inputs of MaxPooling3D.
+type AveragePooling3D[T elefas.SizedNumber] struct {
+	pooling[T]
+}
+
+// NewAveragePooling3D returns an AveragePooling3D layer. It panics if the options are invalid.
+func NewAveragePooling3D[T elefas.SizedNumber](options PoolingOptions) AveragePooling3D[T] {
+	p, err := newPooling[T](3, options, true)
+	if err != nil {
+		panic(err)
+	}
+	return AveragePooling3D[T]{p}
+}
+
+// globalPoolingShape is the OutputShape of the global pooling layers.
+func globalPoolingShape(inputShape []int, dataFormat DataFormat, keepDims bool) ([]int, error) {
+	channelsFirst, err := isChannelsFirst(dataFormat)
+	if err != nil {
+		return nil, err
+	}
+	if len(inputShape) < 3 {
+		return nil, &elefas.ShapeError{Expected: anyShape(3), Actual: inputShape,
+			Reason: "global pooling's input must have spatial dimensions"}
+	}
+	channelAxis := len(inputShape) - 1
+	if channelsFirst {
+		channelAxis = 1
+	}
+	for i := 1; i < len(inputShape); i++ {
+		if i != channelAxis && inputShape[i] == 0 {
+			return nil, &elefas.ShapeError{Expected: anyShape(len(inputShape)), Actual: inputShape,
+				Reason: "global pooling's input must not have empty spatial dimensions"}
+		}
+	}
+	if !keepDims {
+		return []int{inputShape[0], inputShape[channelAxis]}, nil
+	}
+	outputShape := make([]int, len(inputShape))
+	for i := range outputShape {
+		outputShape[i] = 1
+	}
+	outputShape[0], outputShape[channelAxis] = inputShape[0], inputShape[channelAxis]
+	return outputShape, nil
+}
+
+// globalPool is the ApplyInto of the global pooling layers, whose output has the same elements with or without
+// keepDims.
+func globalPool[T elefas.SizedNumber](dst, input elefas.DataFrame[T], dataFormat DataFormat, average bool) error {
+	if _, err := globalPoolingShape(input.Dims, dataFormat, false); err != nil {
+		return err
+	}
+	channelsFirst, err := isChannelsFirst(dataFormat)
+	if err != nil {
+		return err
+	}
+	in := newSpatialLayout(input.Dims, channelsFirst)
+	channels := input.Dim(input.DimCount() - 1)
+	if channelsFirst {
+		channels = input.Dim(1)
+	}
+	// the positions of a channel are evenly spaced in both data formats
+	positions, positionStride := dimsProduct(in.spatial), channels
+	if channelsFirst {
+		positionStride = 1
+	}
+	for b := 0; b < input.Dim(0); b++ {
+		for c := 0; c < channels; c++ {
+			base := b*in.batchStride + c*in.channelStride
+			value := input.Data[base]
+			for p := 1; p < positions; p++ {
+				if x := input.Data[base+p*positionStride]; average {
+					value += x
+				} else if x > value {
+					value = x
+				}
+			}
+			if average {
+				value /= T(positions)
+			}
+			dst.Data[b*channels+c] = value
+		}
+	}
+	return nil
+}
+
+// GlobalMaxPooling is Keras's GlobalMaxPooling1D, GlobalMaxPooling2D and GlobalMaxPooling3D layers, which take the
+// maximum of every channel over all the spatial dimensions of their inputs. The output has the shape (batch,
+// channels), or keeps the spatial dimensions with a size of 1 if KeepDims is set.
+type GlobalMaxPooling[T elefas.SizedNumber] struct {
+	DataFormat DataFormat
+	KeepDims   bool
+}
+
+func (g GlobalMaxPooling[T]) Apply(input elefas.DataFrame[T]) elefas.DataFrame[T] {
+	return must(g.ApplyE(input))
+}
+
+func (g GlobalMaxPooling[T]) ApplyE(input elefas.DataFrame[T]) (elefas.DataFrame[T], error) {
+	return applyInto[T](g, input)
+}
+
+func (g GlobalMaxPooling[T]) OutputShape(inputShape []int) ([]int, error) {
+	return globalPoolingShape(inputShape, g.DataFormat, g.KeepDims)
+}
+
+func (g GlobalMaxPooling[T]) ApplyInto(dst, input elefas.DataFrame[T]) error {
+	return globalPool(dst, input, g.DataFormat, false)
+}
+
+// GlobalAveragePooling is Keras's GlobalAveragePooling1D, GlobalAveragePooling2D and GlobalAveragePooling3D layers,
+// which average every channel over all the spatial dimensions of their inputs, with the options of GlobalMaxPooling.
+type GlobalAveragePooling[T elefas.SizedNumber] struct {
+	DataFormat DataFormat
+	KeepDims   bool
+}
+
+func (g GlobalAveragePooling[T]) Apply(input elefas.DataFrame[T]) elefas.DataFrame[T] {
+	return must(g.ApplyE(input))
+}
+
+func (g GlobalAveragePooling[T]) ApplyE(input elefas.DataFrame[T]) (elefas.DataFrame[T], error) {
+	return applyInto[T](g, input)
+}
+
+func (g GlobalAveragePooling[T]) OutputShape(inputShape []int) ([]int, error) {
+	return globalPoolingShape(inputShape, g.DataFormat, g.KeepDims)
+}
+
+func (g GlobalAveragePooling[T]) ApplyInto(dst, input elefas.DataFrame[T]) error {
+	return globalPool(dst, input, g.DataFormat, true)
+}
@@ -0,0 +1,196 @@
+package layer_test
+
+import (
+	"fmt"
+	"math/rand"
+	"testing"
+
+	"github.com/YohayAiTe/elefas"
+	"github.com/YohayAiTe/elefas/layer"
+	"github.com/YohayAiTe/elefas/layer/internal/testutils"
+)
+
+type poolingTestCase struct {
+	input             []int
+	poolSize, strides []int
+	padding           layer.Padding
+	dataFormat        layer.DataFormat
+}
+
+func (c poolingTestCase) String() string {
+	return fmt.Sprintf("%s,p=%v,s=%v,%s,%s", testutils.DimString(c.input), c.poolSize, c.strides, c.padding,
+		c.dataFormat)
+}
+
+// poolingConfig saves the options of a pooling layer for keras_script.py.
+func poolingConfig[T elefas.SizedNumber](testCase poolingTestCase) elefas.DataFrame[T] {
+	rank := len(testCase.poolSize)
+	config := elefas.MakeDataFrame[T]([]int{2*rank + 2})
+	for i := 0; i < rank; i++ {
+		config.Data[i], config.Data[rank+i] = T(testCase.poolSize[i]), T(testCase.poolSize[i])
+		if testCase.strides != nil {
+			config.Data[rank+i] = T(testCase.strides[i])
+		}
+	}
+	if testCase.padding == layer.PaddingSame {
+		config.Data[2*rank] = 1
+	}
+	if testCase.dataFormat == layer.ChannelsFirst {
+		config.Data[2*rank+1] = 1
+	}
+	return config
+}
+
+func poolingTestFunc[T elefas.SizedNumber, L elefas.Layer[T]](t *testing.T, r *rand.Rand, name string,
+	newLayer func(poolingTestCase) L, testCases []poolingTestCase, epsilon T) {
+
+	for _, testCase := range testCases {
+		t.Run(testCase.String(), func(t *testing.T) {
+			input := testutils.RandomDataFrame[T](r, testCase.input)
+
+			testutils.TestLayerByPython[T](t, testutils.PythonLayerData[T]{
+				Name:    name,
+				Weights: []elefas.DataFrame[T]{poolingConfig[T](testCase)},
+			}, newLayer(testCase), input, epsilon)
+		})
+	}
+}
+
+func (c poolingTestCase) options() layer.PoolingOptions {
+	return layer.PoolingOptions{PoolSize: c.poolSize, Strides: c.strides, Padding: c.padding, DataFormat: c.dataFormat}
+}
+
+func TestPooling1D(t *testing.T) {
+	t.Parallel()
+	r := rand.New(rand.NewSource(0))
+	testCases := []poolingTestCase{
+		{input: []int{1, 4, 1}, poolSize: []int{2}},
+		{input: []int{2, 9, 3}, poolSize: []int{3}, strides: []int{2}},
+		{input: []int{2, 9, 3}, poolSize: []int{4}, strides: []int{3}, padding: layer.PaddingSame},
+		{input: []int{2, 7, 3}, poolSize: []int{3}, strides: []int{1}, padding: layer.PaddingSame},
+		{input: []int{2, 3, 8}, poolSize: []int{3}, padding: layer.PaddingSame, dataFormat: layer.ChannelsFirst},
+	}
+	t.Run("max", func(t *testing.T) {
+		newLayer := func(testCase poolingTestCase) layer.MaxPooling1D[float32] {
+			return layer.NewMaxPooling1D[float32](testCase.options())
+		}
+		poolingTestFunc(t, r, "max_pooling1d", newLayer, testCases, 1e-6)
+	})
+	t.Run("average", func(t *testing.T) {
+		newLayer := func(testCase poolingTestCase) layer.AveragePooling1D[float32] {
+			return layer.NewAveragePooling1D[float32](testCase.options())
+		}
+		poolingTestFunc(t, r, "average_pooling1d", newLayer, testCases, 1e-5)
+	})
+}
+
+func TestPooling2D(t *testing.T) {
+	t.Parallel()
+	r := rand.New(rand.NewSource(0))
+	testCases := []poolingTestCase{
+		{input: []int{1, 4, 4, 1}, poolSize: []int{2, 2}},
+		{input: []int{2, 7, 8, 3}, poolSize: []int{3, 2}},
+		{input: []int{2, 7, 8, 3}, poolSize: []int{3, 3}, strides: []int{2, 1}},
+		{input: []int{2, 7, 8, 3}, poolSize: []int{3, 2}, padding: layer.PaddingSame},
+		{input: []int{2, 9, 7, 2}, poolSize: []int{4, 3}, strides: []int{3, 2}, padding: layer.PaddingSame},
+		{input: []int{2, 3, 6, 7}, poolSize: []int{2, 3}, strides: []int{2, 2}, padding: layer.PaddingSame,
+			dataFormat: layer.ChannelsFirst},
+	}
+	t.Run("max", func(t *testing.T) {
+		newLayer := func(testCase poolingTestCase) layer.MaxPooling2D[float64] {
+			return layer.NewMaxPooling2D[float64](testCase.options())
+		}
+		poolingTestFunc(t, r, "max_pooling2d", newLayer, testCases, 1e-10)
+	})
+	t.Run("average", func(t *testing.T) {
+		newLayer := func(testCase poolingTestCase) layer.AveragePooling2D[float64] {
+			return layer.NewAveragePooling2D[float64](testCase.options())
+		}
+		poolingTestFunc(t, r, "average_pooling2d", newLayer, testCases, 1e-10)
+	})
+}
+
+func TestPooling3D(t *testing.T) {
+	t.Parallel()
+	r := rand.New(rand.NewSource(0))
+	testCases := []poolingTestCase{
+		{input: []int{1, 4, 4, 4, 1}, poolSize: []int{2, 2, 2}},
+		{input: []int{2, 5, 6, 7, 2}, poolSize: []int{2, 3, 2}, strides: []int{1, 2, 3}},
+		{input: []int{2, 5, 6, 7, 2}, poolSize: []int{3, 2, 3}, padding: layer.PaddingSame},
+		{input: []int{2, 3, 4, 5, 6}, poolSize: []int{2, 2, 3}, padding: layer.PaddingSame,
+			dataFormat: layer.ChannelsFirst},
+	}
+	t.Run("max", func(t *testing.T) {
+		newLayer := func(testCase poolingTestCase) layer.MaxPooling3D[float32] {
+			return layer.NewMaxPooling3D[float32](testCase.options())
+		}
+		poolingTestFunc(t, r, "max_pooling3d", newLayer, testCases, 1e-6)
+	})
+	t.Run("average", func(t *testing.T) {
+		newLayer := func(testCase poolingTestCase) layer.AveragePooling3D[float32] {
+			return layer.NewAveragePooling3D[float32](testCase.options())
+		}
+		poolingTestFunc(t, r, "average_pooling3d", newLayer, testCases, 1e-5)
+	})
+}
+
+type globalPoolingTestCase struct {
+	input      []int
+	keepDims   bool
+	dataFormat layer.DataFormat
+}
+
+func (c globalPoolingTestCase) String() string {
+	return fmt.Sprintf("%s,keepdims=%v,%s", testutils.DimString(c.input), c.keepDims, c.dataFormat)
+}
+
+func globalPoolingTestFunc[T elefas.SizedNumber](t *testing.T, r *rand.Rand, average bool,
+	testCases []globalPoolingTestCase, epsilon T) {
+
+	for _, testCase := range testCases {
+		t.Run(testCase.String(), func(t *testing.T) {
+			input := testutils.RandomDataFrame[T](r, testCase.input)
+			// the configuration is the rank, keepdims and whether it is channels first
+			config := elefas.MakeDataFrame[T]([]int{3})
+			config.Data[0] = T(len(testCase.input) - 2)
+			if testCase.keepDims {
+				config.Data[1] = 1
+			}
+			if testCase.dataFormat == layer.ChannelsFirst {
+				config.Data[2] = 1
+			}
+
+			var l elefas.Layer[T] = layer.GlobalMaxPooling[T]{DataFormat: testCase.dataFormat,
+				KeepDims: testCase.keepDims}
+			name := "global_max_pooling"
+			if average {
+				l = layer.GlobalAveragePooling[T]{DataFormat: testCase.dataFormat, KeepDims: testCase.keepDims}
+				name = "global_average_pooling"
+			}
+			testutils.TestLayerByPython[T](t, testutils.PythonLayerData[T]{
+				Name:    name,
+				Weights: []elefas.DataFrame[T]{config},
+			}, l, input, epsilon)
+		})
+	}
+}
+
+func TestGlobalPooling(t *testing.T) {
+	t.Parallel()
+	r := rand.New(rand.NewSource(0))
+	testCases := []globalPoolingTestCase{
+		{input: []int{2, 5, 3}},
+		{input: []int{2, 5, 3}, keepDims: true},
+		{input: []int{2, 4, 5, 3}},
+		{input: []int{2, 4, 5, 3}, keepDims: true},
+		{input: []int{2, 3, 4, 5}, dataFormat: layer.ChannelsFirst},
+		{input: []int{2, 3, 4, 5}, keepDims: true, dataFormat: layer.ChannelsFirst},
+		{input: []int{2, 3, 4, 2, 3}, keepDims: true},
+	}
+	t.Run("max", func(t *testing.T) {
+		globalPoolingTestFunc[float32](t, r, false, testCases, 1e-6)
+	})
+	t.Run("average", func(t *testing.T) {
+		globalPoolingTestFunc[float32](t, r, true, testCases, 1e-5)
+	})
+}
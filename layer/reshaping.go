@@ -5,14 +5,34 @@ import "github.com/YohayAiTe/elefas"
 type Flatten[T elefas.SizedNumber] struct{}
 
 func (f Flatten[T]) Apply(input elefas.DataFrame[T]) elefas.DataFrame[T] {
-	if input.DimCount() == 0 {
-		panic("cannot flatten dataframe with no dimensions")
-	} else if input.DimCount() == 1 {
-		output := elefas.MakeDataFrame[T]([]int{input.Dim(0), 1})
-		copy(output.Data, input.Data)
-		return output
+	return must(f.ApplyE(input))
+}
+
+func (f Flatten[T]) OutputShape(inputShape []int) ([]int, error) {
+	if len(inputShape) == 0 {
+		return nil, &elefas.ShapeError{Expected: []int{-1}, Actual: inputShape,
+			Reason: "cannot flatten dataframe with no dimensions"}
+	}
+	size := 1
+	for _, d := range inputShape[1:] {
+		if d == -1 {
+			size = -1
+			break
+		}
+		size *= d
 	}
-	output := elefas.MakeDataFrame[T]([]int{input.Dim(0), input.TotalSize() / input.Dim(0)})
-	copy(output.Data, input.Data)
-	return output
+	return []int{inputShape[0], size}, nil
+}
+
+func (f Flatten[T]) ApplyE(input elefas.DataFrame[T]) (elefas.DataFrame[T], error) {
+	return applyInto[T](f, input)
+}
+
+func (f Flatten[T]) ApplyInto(dst, input elefas.DataFrame[T]) error {
+	copy(dst.Data, input.Data)
+	return nil
+}
+
+func (f Flatten[T]) SupportsInPlace() bool {
+	return true
 }
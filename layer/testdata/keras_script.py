@@ -1,8 +1,126 @@
 from tensorflow.keras.layers import Input, Dense, ReLU, Activation, Softmax, ELU
-from tensorflow.keras.models import Sequential
+from tensorflow.keras.layers import Add, Subtract, Multiply, Average, Maximum, Minimum, Concatenate, Dot
+from tensorflow.keras.layers import Conv1D, Conv2D, Conv3D, DepthwiseConv2D, SeparableConv2D, Conv2DTranspose
+from tensorflow.keras.layers import UpSampling1D, UpSampling2D, UpSampling3D
+from tensorflow.keras.layers import MaxPooling1D, MaxPooling2D, MaxPooling3D
+from tensorflow.keras.layers import AveragePooling1D, AveragePooling2D, AveragePooling3D
+from tensorflow.keras.layers import GlobalMaxPooling1D, GlobalMaxPooling2D, GlobalMaxPooling3D
+from tensorflow.keras.layers import GlobalAveragePooling1D, GlobalAveragePooling2D, GlobalAveragePooling3D
+from tensorflow.keras.models import Sequential, Model
 import numpy as np
 import sys
 
+
+def merge_layer(layer_name, weights, dtype):
+    if layer_name == "add":
+        return Add(dtype=dtype)
+    elif layer_name == "subtract":
+        return Subtract(dtype=dtype)
+    elif layer_name == "multiply":
+        return Multiply(dtype=dtype)
+    elif layer_name == "average":
+        return Average(dtype=dtype)
+    elif layer_name == "maximum":
+        return Maximum(dtype=dtype)
+    elif layer_name == "minimum":
+        return Minimum(dtype=dtype)
+    elif layer_name == "concatenate":
+        return Concatenate(int(weights['arr_0'][0]), dtype=dtype)
+    elif layer_name == "dot":
+        axes = (int(weights['arr_0'][0]), int(weights['arr_0'][1]))
+        return Dot(axes, normalize=bool(weights['arr_0'][2]), dtype=dtype)
+    print("unknown layer name:", layer_name, file=sys.stderr)
+    exit(-1)
+
+# conv_options reads the options of a convolution layer, saved as its strides, dilation rate, groups, padding and
+# whether it is channels first
+def conv_options(config, rank):
+    strides = tuple(int(s) for s in config[:rank])
+    dilation_rate = tuple(int(d) for d in config[rank:2*rank])
+    groups = int(config[2*rank])
+    padding = ["valid", "same", "causal"][int(config[2*rank+1])]
+    channels_first = bool(config[2*rank+2])
+    return strides, dilation_rate, groups, padding, channels_first
+
+
+# batched_layer returns the layers whose inputs keep their batch dimension, with their weights and whether they are
+# channels first
+def batched_layer(layer_name, weights, dtype):
+    convolutions = {"conv1d": (Conv1D, 1), "conv2d": (Conv2D, 2), "conv3d": (Conv3D, 3)}
+    poolings = {"max_pooling1d": (MaxPooling1D, 1), "max_pooling2d": (MaxPooling2D, 2),
+                "max_pooling3d": (MaxPooling3D, 3), "average_pooling1d": (AveragePooling1D, 1),
+                "average_pooling2d": (AveragePooling2D, 2), "average_pooling3d": (AveragePooling3D, 3)}
+    global_poolings = {"global_max_pooling": [GlobalMaxPooling1D, GlobalMaxPooling2D, GlobalMaxPooling3D],
+                       "global_average_pooling": [GlobalAveragePooling1D, GlobalAveragePooling2D,
+                                                  GlobalAveragePooling3D]}
+    if layer_name in convolutions:
+        layer_class, rank = convolutions[layer_name]
+        kernel, bias = weights['arr_0'], weights['arr_1']
+        strides, dilation_rate, groups, padding, channels_first = conv_options(weights['arr_2'], rank)
+        layer = layer_class(kernel.shape[-1], kernel.shape[:-2], strides=strides, padding=padding,
+                            dilation_rate=dilation_rate, groups=groups, dtype=dtype)
+        return layer, [kernel, bias], channels_first
+    elif layer_name == "depthwise_conv2d":
+        kernel, bias = weights['arr_0'], weights['arr_1']
+        strides, dilation_rate, _, padding, channels_first = conv_options(weights['arr_2'], 2)
+        layer = DepthwiseConv2D(kernel.shape[:2], strides=strides, padding=padding, depth_multiplier=kernel.shape[3],
+                                dilation_rate=dilation_rate, dtype=dtype)
+        return layer, [kernel, bias], channels_first
+    elif layer_name == "separable_conv2d":
+        depthwise_kernel, pointwise_kernel, bias = weights['arr_0'], weights['arr_1'], weights['arr_2']
+        strides, dilation_rate, _, padding, channels_first = conv_options(weights['arr_3'], 2)
+        layer = SeparableConv2D(pointwise_kernel.shape[-1], depthwise_kernel.shape[:2], strides=strides,
+                                padding=padding, dilation_rate=dilation_rate,
+                                depth_multiplier=depthwise_kernel.shape[3], dtype=dtype)
+        return layer, [depthwise_kernel, pointwise_kernel, bias], channels_first
+    elif layer_name == "conv2d_transpose":
+        kernel, bias, config = weights['arr_0'], weights['arr_1'], weights['arr_2']
+        strides, dilation_rate, _, padding, channels_first = conv_options(config, 2)
+        output_padding = tuple(int(p) for p in config[8:10]) if config[7] else None
+        layer = Conv2DTranspose(kernel.shape[2], kernel.shape[:2], strides=strides, padding=padding,
+                                output_padding=output_padding, dilation_rate=dilation_rate, dtype=dtype)
+        return layer, [kernel, bias], channels_first
+    elif layer_name == "up_sampling1d":
+        return UpSampling1D(int(weights['arr_0'][0]), dtype=dtype), [], False
+    elif layer_name == "up_sampling2d":
+        config = weights['arr_0']
+        interpolation = "bilinear" if config[2] else "nearest"
+        layer = UpSampling2D(tuple(int(s) for s in config[:2]), interpolation=interpolation, dtype=dtype)
+        return layer, [], bool(config[3])
+    elif layer_name == "up_sampling3d":
+        config = weights['arr_0']
+        return UpSampling3D(tuple(int(s) for s in config[:3]), dtype=dtype), [], bool(config[4])
+    elif layer_name in poolings:
+        # the configuration of pooling layers is their pool size, strides, padding and whether they are channels first
+        layer_class, rank = poolings[layer_name]
+        config = weights['arr_0']
+        pool_size = tuple(int(p) for p in config[:rank])
+        strides = tuple(int(s) for s in config[rank:2*rank])
+        padding = ["valid", "same"][int(config[2*rank])]
+        return layer_class(pool_size, strides=strides, padding=padding, dtype=dtype), [], bool(config[2*rank+1])
+    elif layer_name in global_poolings:
+        # the configuration of global pooling layers is their rank, keepdims and whether they are channels first
+        config = weights['arr_0']
+        layer_class = global_poolings[layer_name][int(config[0]) - 1]
+        return layer_class(keepdims=bool(config[1]), dtype=dtype), [], bool(config[2])
+    return None, None, False
+
+
+def run_batched(layer, layer_weights, channels_first, input):
+    # TensorFlow only runs channels first convolutions on GPUs, so they, and the layers tested like them, run channels
+    # last on transposed inputs
+    if channels_first:
+        input = np.moveaxis(input, 1, -1)
+    model = Sequential()
+    model.add(Input(shape=input.shape[1:], batch_size=input.shape[0], dtype=input.dtype))
+    model.add(layer)
+    layer.set_weights(layer_weights)
+    output = model.predict(input)
+    if channels_first:
+        output = np.moveaxis(output, -1, 1)
+    return output
+
+
 if __name__ == "__main__":
     layer_name = sys.argv[1]
     layer_weights_file = sys.argv[2]
@@ -11,8 +129,24 @@ if __name__ == "__main__":
     print(layer_name, layer_weights_file, input_file, output_file)
 
     weights = np.load(layer_weights_file)
+
+    if input_file.endswith(".npz"):
+        # multi-input layers get their inputs batched as they are
+        inputs_data = np.load(input_file)
+        inputs = [inputs_data['arr_%d' % i] for i in range(len(inputs_data.files))]
+        keras_inputs = [Input(shape=x.shape[1:], batch_size=x.shape[0], dtype=x.dtype) for x in inputs]
+        layer = merge_layer(layer_name, weights, inputs[0].dtype)
+        model = Model(keras_inputs, layer(keras_inputs))
+        np.save(output_file, model.predict(inputs))
+        exit(0)
+
     input = np.load(input_file)
 
+    layer, layer_weights, channels_first = batched_layer(layer_name, weights, input.dtype)
+    if layer is not None:
+        np.save(output_file, run_batched(layer, layer_weights, channels_first, input))
+        exit(0)
+
     model = Sequential()
     model.add(Input(shape=input.shape, dtype=input.dtype))
 
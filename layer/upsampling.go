@@ -0,0 +1,250 @@
+package layer
+
+import (
+	"fmt"
+	"math"
+	"sync"
+
+	"github.com/YohayAiTe/elefas"
+)
+
+// Interpolation is how UpSampling2D fills in its output, named like in Keras.
+type Interpolation string
+
+const (
+	// InterpolationNearest repeats every element of the input.
+	InterpolationNearest Interpolation = "nearest"
+	// InterpolationBilinear interpolates between the nearest elements of the input, with the centers of the pixels
+	// aligned like TensorFlow's image resizing.
+	InterpolationBilinear Interpolation = "bilinear"
+)
+
+// upSampling is the core of the up-sampling layers, for any number of spatial dimensions, which they embed. It is
+// validated once, when the layer is made.
+type upSampling[T elefas.SizedNumber] struct {
+	size                    []int
+	bilinear, channelsFirst bool
+	// tables caches the index tables of the inputs' shapes, and is shared by the copies of the layer
+	tables *upSamplingCache
+}
+
+// upSamplingTables are the offsets in the input of the sides of the cell that every output position is in, in each
+// spatial dimension, and how close the position is to the upper side. Without bilinear interpolation, only lower is
+// used.
+type upSamplingTables struct {
+	lower, upper [][]int
+	weights      [][]float64
+}
+
+// maxUpSamplingTables bounds the number of input shapes whose tables an up-sampling layer keeps.
+const maxUpSamplingTables = 16
+
+type upSamplingCache struct {
+	mu sync.Mutex
+	// byShape maps the dimensions of the inputs, but the batch, to their tables
+	byShape map[[4]int]*upSamplingTables
+}
+
+// newUpSampling returns the core of an up-sampling layer, whose size is 2 in every spatial dimension if nil.
+func newUpSampling[T elefas.SizedNumber](rank int, size []int, interpolation Interpolation, dataFormat DataFormat) (
+	upSampling[T], error) {
+
+	u := upSampling[T]{size: append([]int(nil), size...), tables: &upSamplingCache{}}
+	if size == nil {
+		u.size = make([]int, rank)
+		for i := range u.size {
+			u.size[i] = 2
+		}
+	}
+	if len(u.size) != rank {
+		return upSampling[T]{}, fmt.Errorf("%dD up-sampling takes %d sizes, got %v", rank, rank, size)
+	}
+	for _, n := range u.size {
+		if n < 1 {
+			return upSampling[T]{}, fmt.Errorf("up-sampling size must be positive, got %v", size)
+		}
+	}
+	switch interpolation {
+	case "", InterpolationNearest:
+	case InterpolationBilinear:
+		u.bilinear = true
+	default:
+		return upSampling[T]{}, fmt.Errorf("unknown interpolation %q", interpolation)
+	}
+	var err error
+	if u.channelsFirst, err = isChannelsFirst(dataFormat); err != nil {
+		return upSampling[T]{}, err
+	}
+	return u, nil
+}
+
+func (u upSampling[T]) Apply(input elefas.DataFrame[T]) elefas.DataFrame[T] {
+	return must(u.ApplyE(input))
+}
+
+func (u upSampling[T]) ApplyE(input elefas.DataFrame[T]) (elefas.DataFrame[T], error) {
+	return applyInto[T](u, input)
+}
+
+func (u upSampling[T]) OutputShape(inputShape []int) ([]int, error) {
+	rank := len(u.size)
+	if len(inputShape) != rank+2 {
+		return nil, &elefas.ShapeError{Expected: anyShape(rank + 2), Actual: inputShape,
+			Reason: fmt.Sprintf("%dD up-sampling's input must have %d dimensions", rank, rank+2)}
+	}
+	spatialAxis := 1
+	if u.channelsFirst {
+		spatialAxis = 2
+	}
+	outputShape := append([]int{}, inputShape...)
+	for i, n := range u.size {
+		if outputShape[spatialAxis+i] != -1 {
+			outputShape[spatialAxis+i] *= n
+		}
+	}
+	return outputShape, nil
+}
+
+// indexTables returns the tables of inputs of the given layout, from the cache if the layer has one.
+func (u upSampling[T]) indexTables(inputDims []int, in, out spatialLayout) *upSamplingTables {
+	var key [4]int
+	copy(key[:], inputDims[1:])
+	if u.tables != nil {
+		u.tables.mu.Lock()
+		defer u.tables.mu.Unlock()
+		if t, ok := u.tables.byShape[key]; ok {
+			return t
+		}
+	}
+
+	rank := len(u.size)
+	t := &upSamplingTables{lower: make([][]int, rank), upper: make([][]int, rank), weights: make([][]float64, rank)}
+	for i, n := range u.size {
+		t.lower[i], t.upper[i] = make([]int, out.spatial[i]), make([]int, out.spatial[i])
+		t.weights[i] = make([]float64, out.spatial[i])
+		for o := range t.lower[i] {
+			if !u.bilinear {
+				t.lower[i][o] = o / n * in.strides[i]
+				continue
+			}
+			position := (float64(o)+0.5)/float64(n) - 0.5
+			floor := math.Floor(position)
+			t.lower[i][o] = int(math.Max(floor, 0)) * in.strides[i]
+			t.upper[i][o] = int(math.Min(math.Ceil(position), float64(in.spatial[i]-1))) * in.strides[i]
+			t.weights[i][o] = position - floor
+		}
+	}
+
+	if u.tables != nil {
+		if u.tables.byShape == nil || len(u.tables.byShape) >= maxUpSamplingTables {
+			u.tables.byShape = make(map[[4]int]*upSamplingTables)
+		}
+		u.tables.byShape[key] = t
+	}
+	return t
+}
+
+// ApplyInto takes every element of the output from the element of the input at the same position, divided by the
+// size, or with bilinear interpolation from the corners of the cell of the input it is in.
+func (u upSampling[T]) ApplyInto(dst, input elefas.DataFrame[T]) error {
+	in, out := newSpatialLayout(input.Dims, u.channelsFirst), newSpatialLayout(dst.Dims, u.channelsFirst)
+	rank := len(u.size)
+	channels := input.Dim(rank + 1)
+	if u.channelsFirst {
+		channels = input.Dim(1)
+	}
+	t := u.indexTables(input.Dims, in, out)
+	lower, upper, weights := t.lower, t.upper, t.weights
+
+	outPositions := dimsProduct(out.spatial)
+	index := make([]int, rank)
+	for b := 0; b < input.Dim(0); b++ {
+		for o := 0; o < outPositions; o++ {
+			inBase, outBase := b*in.batchStride, b*out.batchStride
+			for i := range index {
+				outBase += index[i] * out.strides[i]
+			}
+			if !u.bilinear {
+				for i := range index {
+					inBase += lower[i][index[i]]
+				}
+				for c := 0; c < channels; c++ {
+					dst.Data[outBase+c*out.channelStride] = input.Data[inBase+c*in.channelStride]
+				}
+				nextIndex(index, out.spatial)
+				continue
+			}
+
+			for c := 0; c < channels; c++ {
+				var value float64
+				// each bit of corner picks the upper side of the cell in a dimension
+				for corner := 0; corner < 1<<rank; corner++ {
+					offset, weight := inBase+c*in.channelStride, 1.0
+					for i := range index {
+						if corner&(1<<i) != 0 {
+							offset += upper[i][index[i]]
+							weight *= weights[i][index[i]]
+						} else {
+							offset += lower[i][index[i]]
+							weight *= 1 - weights[i][index[i]]
+						}
+					}
+					value += weight * float64(input.Data[offset])
+				}
+				dst.Data[outBase+c*out.channelStride] = T(value)
+			}
+			nextIndex(index, out.spatial)
+		}
+	}
+	return nil
+}
+
+// UpSampling1D is Keras's UpSampling1D layer, which repeats every step of its inputs, of shape (batch, steps,
+// features), a number of times.
+type UpSampling1D[T elefas.SizedNumber] struct {
+	upSampling[T]
+}
+
+// NewUpSampling1D returns an UpSampling1D layer, whose size has a single number, like the sizes of the other
+// up-sampling layers, where Keras takes an int. A nil size is Keras's default of 2. It panics if the size is invalid.
+func NewUpSampling1D[T elefas.SizedNumber](size []int) UpSampling1D[T] {
+	u, err := newUpSampling[T](1, size, InterpolationNearest, ChannelsLast)
+	if err != nil {
+		panic(err)
+	}
+	return UpSampling1D[T]{u}
+}
+
+// UpSampling2D is Keras's UpSampling2D layer, which scales the height and the width of its inputs, of shape (batch,
+// height, width, channels), or (batch, channels, height, width) with ChannelsFirst.
+type UpSampling2D[T elefas.SizedNumber] struct {
+	upSampling[T]
+}
+
+// NewUpSampling2D returns an UpSampling2D layer, whose size is Keras's default of 2 in both dimensions if nil. It
+// panics if the options are invalid.
+func NewUpSampling2D[T elefas.SizedNumber](size []int, interpolation Interpolation,
+	dataFormat DataFormat) UpSampling2D[T] {
+
+	u, err := newUpSampling[T](2, size, interpolation, dataFormat)
+	if err != nil {
+		panic(err)
+	}
+	return UpSampling2D[T]{u}
+}
+
+// UpSampling3D is Keras's UpSampling3D layer, which repeats the elements of its inputs, of shape (batch, depth,
+// height, width, channels), or (batch, channels, depth, height, width) with ChannelsFirst, in each spatial dimension.
+type UpSampling3D[T elefas.SizedNumber] struct {
+	upSampling[T]
+}
+
+// NewUpSampling3D returns an UpSampling3D layer, whose size is Keras's default of 2 in all dimensions if nil. It
+// panics if the options are invalid.
+func NewUpSampling3D[T elefas.SizedNumber](size []int, dataFormat DataFormat) UpSampling3D[T] {
+	u, err := newUpSampling[T](3, size, InterpolationNearest, dataFormat)
+	if err != nil {
+		panic(err)
+	}
+	return UpSampling3D[T]{u}
+}
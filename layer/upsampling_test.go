@@ -0,0 +1,129 @@
+package layer_test
+
+import (
+	"fmt"
+	"math/rand"
+	"testing"
+
+	"github.com/YohayAiTe/elefas"
+	"github.com/YohayAiTe/elefas/layer"
+	"github.com/YohayAiTe/elefas/layer/internal/testutils"
+)
+
+type upSamplingTestCase struct {
+	input         []int
+	size          []int
+	interpolation layer.Interpolation
+	dataFormat    layer.DataFormat
+}
+
+func (c upSamplingTestCase) String() string {
+	return fmt.Sprintf("%s,s=%v,%s,%s", testutils.DimString(c.input), c.size, c.interpolation, c.dataFormat)
+}
+
+// upSamplingConfig saves the size of an up-sampling layer for keras_script.py, then whether it is bilinear and
+// whether it is channels first.
+func upSamplingConfig[T elefas.SizedNumber](testCase upSamplingTestCase) elefas.DataFrame[T] {
+	config := elefas.MakeDataFrame[T]([]int{len(testCase.size) + 2})
+	for i, n := range testCase.size {
+		config.Data[i] = T(n)
+	}
+	if testCase.interpolation == layer.InterpolationBilinear {
+		config.Data[len(testCase.size)] = 1
+	}
+	if testCase.dataFormat == layer.ChannelsFirst {
+		config.Data[len(testCase.size)+1] = 1
+	}
+	return config
+}
+
+func upSamplingTestFunc[T elefas.SizedNumber, L elefas.Layer[T]](t *testing.T, r *rand.Rand, name string,
+	newLayer func(upSamplingTestCase) L, testCases []upSamplingTestCase, epsilon T) {
+
+	for _, testCase := range testCases {
+		t.Run(testCase.String(), func(t *testing.T) {
+			input := testutils.RandomDataFrame[T](r, testCase.input)
+
+			testutils.TestLayerByPython[T](t, testutils.PythonLayerData[T]{
+				Name:    name,
+				Weights: []elefas.DataFrame[T]{upSamplingConfig[T](testCase)},
+			}, newLayer(testCase), input, epsilon)
+		})
+	}
+}
+
+func TestUpSampling1D(t *testing.T) {
+	t.Parallel()
+	r := rand.New(rand.NewSource(0))
+	testCases := []upSamplingTestCase{
+		{input: []int{1, 4, 1}, size: []int{2}},
+		{input: []int{2, 5, 3}, size: []int{3}},
+		{input: []int{2, 3, 4}, size: []int{1}},
+	}
+	newLayer := func(testCase upSamplingTestCase) layer.UpSampling1D[float32] {
+		return layer.NewUpSampling1D[float32](testCase.size)
+	}
+	upSamplingTestFunc(t, r, "up_sampling1d", newLayer, testCases, 1e-6)
+}
+
+func TestUpSampling2D(t *testing.T) {
+	t.Parallel()
+	r := rand.New(rand.NewSource(0))
+	testCases := []upSamplingTestCase{
+		{input: []int{1, 3, 3, 1}, size: []int{2, 2}},
+		{input: []int{2, 4, 5, 3}, size: []int{3, 2}},
+		{input: []int{2, 3, 4, 5}, size: []int{2, 3}, dataFormat: layer.ChannelsFirst},
+		{input: []int{1, 3, 3, 1}, size: []int{2, 2}, interpolation: layer.InterpolationBilinear},
+		{input: []int{2, 4, 5, 3}, size: []int{3, 2}, interpolation: layer.InterpolationBilinear},
+		{input: []int{2, 5, 4, 3}, size: []int{1, 4}, interpolation: layer.InterpolationBilinear},
+		{input: []int{2, 3, 4, 5}, size: []int{2, 3}, interpolation: layer.InterpolationBilinear,
+			dataFormat: layer.ChannelsFirst},
+	}
+	t.Run("float32", func(t *testing.T) {
+		newLayer := func(testCase upSamplingTestCase) layer.UpSampling2D[float32] {
+			return layer.NewUpSampling2D[float32](testCase.size, testCase.interpolation, testCase.dataFormat)
+		}
+		upSamplingTestFunc(t, r, "up_sampling2d", newLayer, testCases, 1e-5)
+	})
+	t.Run("float64", func(t *testing.T) {
+		newLayer := func(testCase upSamplingTestCase) layer.UpSampling2D[float64] {
+			return layer.NewUpSampling2D[float64](testCase.size, testCase.interpolation, testCase.dataFormat)
+		}
+		// TensorFlow resizes bilinearly in float32
+		upSamplingTestFunc(t, r, "up_sampling2d", newLayer, testCases, 1e-5)
+	})
+}
+
+func TestUpSampling3D(t *testing.T) {
+	t.Parallel()
+	r := rand.New(rand.NewSource(0))
+	testCases := []upSamplingTestCase{
+		{input: []int{1, 2, 2, 2, 1}, size: []int{2, 2, 2}},
+		{input: []int{2, 3, 2, 4, 3}, size: []int{1, 3, 2}},
+		{input: []int{2, 3, 2, 3, 4}, size: []int{2, 1, 3}, dataFormat: layer.ChannelsFirst},
+	}
+	newLayer := func(testCase upSamplingTestCase) layer.UpSampling3D[float64] {
+		return layer.NewUpSampling3D[float64](testCase.size, testCase.dataFormat)
+	}
+	upSamplingTestFunc(t, r, "up_sampling3d", newLayer, testCases, 1e-10)
+}
+
+// TestUpSamplingShapes applies a layer to inputs of alternating shapes, whose index tables it caches, and compares
+// its outputs to those of new layers.
+func TestUpSamplingShapes(t *testing.T) {
+	t.Parallel()
+	r := rand.New(rand.NewSource(0))
+	newLayer := func() layer.UpSampling2D[float64] {
+		return layer.NewUpSampling2D[float64]([]int{2, 3}, layer.InterpolationBilinear, layer.ChannelsLast)
+	}
+	l := newLayer()
+	for i, dims := range [][]int{{1, 3, 4, 2}, {2, 5, 2, 2}, {1, 3, 4, 2}, {1, 3, 4, 3}, {2, 5, 2, 2}} {
+		input := testutils.RandomDataFrame[float64](r, dims)
+		output, expected := l.Apply(input), newLayer().Apply(input)
+		for j := range expected.Data {
+			if output.Data[j] != expected.Data[j] {
+				t.Fatalf("input %d %v: output[%d] is %v, expected %v", i, dims, j, output.Data[j], expected.Data[j])
+			}
+		}
+	}
+}
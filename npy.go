@@ -0,0 +1,483 @@
+package elefas
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"unsafe"
+)
+
+const numpyChunkSize = 1 << 16
+
+// NumpyFile is a memory-mapped .npy file or .npz archive. DataFrames loaded from it may share its memory, so they
+// must not be used after it is closed.
+type NumpyFile struct {
+	data  []byte
+	names []string
+	files map[string]*zip.File // nil for .npy files
+}
+
+// OpenNumpyFile memory-maps a .npy file, or a .npz archive saved by np.savez or np.savez_compressed.
+func OpenNumpyFile(path string) (*NumpyFile, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	info, err := file.Stat()
+	if err != nil {
+		return nil, err
+	}
+	if info.Size() == 0 {
+		return nil, fmt.Errorf("%w: empty file", ErrInvalidNumpyFile)
+	}
+	data, err := mmapFile(file, int(info.Size()))
+	if err != nil {
+		return nil, err
+	}
+
+	f := &NumpyFile{data: data}
+	if bytes.HasPrefix(data, numpyMagic) {
+		f.names = []string{""}
+		return f, nil
+	}
+	zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		munmapFile(data)
+		return nil, err
+	}
+	f.files = make(map[string]*zip.File, len(zr.File))
+	for _, zf := range zr.File {
+		name := strings.TrimSuffix(zf.Name, ".npy")
+		f.names = append(f.names, name)
+		f.files[name] = zf
+	}
+	return f, nil
+}
+
+// Names returns the names of the arrays in f, in archive order. A .npy file has a single array named "".
+func (f *NumpyFile) Names() []string {
+	return f.names
+}
+
+func (f *NumpyFile) Close() error {
+	if f.data == nil {
+		return os.ErrClosed
+	}
+	data := f.data
+	f.data = nil
+	return munmapFile(data)
+}
+
+// member returns the .npy file called name, directly if it is stored uncompressed and as a reader otherwise.
+func (f *NumpyFile) member(name string) ([]byte, io.ReadCloser, error) {
+	if f.data == nil {
+		return nil, nil, os.ErrClosed
+	}
+	if f.files == nil {
+		if name != "" {
+			return nil, nil, fmt.Errorf("%w: %q", ErrArrayNotFound, name)
+		}
+		return f.data, nil, nil
+	}
+	zf, ok := f.files[name]
+	if !ok {
+		return nil, nil, fmt.Errorf("%w: %q", ErrArrayNotFound, name)
+	}
+	if zf.Method == zip.Store {
+		offset, err := zf.DataOffset()
+		if err != nil {
+			return nil, nil, err
+		}
+		end := offset + int64(zf.CompressedSize64)
+		if end > int64(len(f.data)) {
+			return nil, nil, fmt.Errorf("%w: %q is truncated", ErrInvalidNumpyFile, name)
+		}
+		return f.data[offset:end], nil, nil
+	}
+	rc, err := zf.Open()
+	return nil, rc, err
+}
+
+// MmapNumpyDataFrame loads the array called name from f. If the array is stored uncompressed with dtype T and
+// native endianness, the DataFrame's Data points straight into the mapped file, which is mapped copy-on-write so
+// modifying it does not change the file. Otherwise, the array is read in chunks and converted to T.
+func MmapNumpyDataFrame[T SizedNumber](f *NumpyFile, name string) (DataFrame[T], error) {
+	raw, rc, err := f.member(name)
+	if err != nil {
+		return DataFrame[T]{}, err
+	}
+	var r io.Reader = rc
+	if rc != nil {
+		defer rc.Close()
+	} else {
+		r = bytes.NewReader(raw)
+	}
+
+	h, err := readNumpyHeader(r)
+	if err != nil {
+		return DataFrame[T]{}, err
+	}
+	if raw != nil && h.dtype == sizedNumberToDtype[T]() && h.native() && h.rowMajor() {
+		raw = raw[h.dataOffset:]
+		var zero T
+		if h.count() > len(raw)/h.itemSize() {
+			return DataFrame[T]{}, io.ErrUnexpectedEOF
+		}
+		if uintptr(unsafe.Pointer(unsafe.SliceData(raw)))%unsafe.Alignof(zero) == 0 {
+			data := unsafe.Slice((*T)(unsafe.Pointer(unsafe.SliceData(raw))), h.count())
+			return DataFrame[T]{Dims: h.dims(), Data: data}, nil
+		}
+	}
+	data, err := readNumpyData[T](r, h)
+	if err != nil {
+		return DataFrame[T]{}, err
+	}
+	return DataFrame[T]{Dims: h.dims(), Data: data}, nil
+}
+
+var (
+	numpyMagic = []byte("\x93NUMPY")
+
+	numpyDescrRegexp        = regexp.MustCompile(`'descr'\s*:\s*'([^']*)'`)
+	numpyFortranOrderRegexp = regexp.MustCompile(`'fortran_order'\s*:\s*(True|False)`)
+	numpyShapeRegexp        = regexp.MustCompile(`'shape'\s*:\s*\(([^)]*)\)`)
+)
+
+var nativeEndian binary.ByteOrder = func() binary.ByteOrder {
+	x := uint16(1)
+	if *(*byte)(unsafe.Pointer(&x)) == 1 {
+		return binary.LittleEndian
+	}
+	return binary.BigEndian
+}()
+
+type numpyHeader struct {
+	dtype        string // without the byte order, e.g. "f4"
+	order        binary.ByteOrder
+	fortranOrder bool
+	shape        []int
+	dataOffset   int
+}
+
+func (h numpyHeader) itemSize() int {
+	size, _ := strconv.Atoi(h.dtype[1:])
+	return size
+}
+
+func (h numpyHeader) count() int {
+	count := 1
+	for _, d := range h.shape {
+		count *= d
+	}
+	return count
+}
+
+// dims returns the shape as DataFrame dimensions, where scalars have a single dimension.
+func (h numpyHeader) dims() []int {
+	if len(h.shape) == 0 {
+		return []int{1}
+	}
+	return h.shape
+}
+
+// native reports whether the data can be used as is by a []T of the same dtype.
+func (h numpyHeader) native() bool {
+	return h.itemSize() == 1 || h.order == nativeEndian
+}
+
+func readNumpyHeader(r io.Reader) (numpyHeader, error) {
+	prefix := make([]byte, len(numpyMagic)+2)
+	if _, err := io.ReadFull(r, prefix); err != nil {
+		return numpyHeader{}, err
+	}
+	if !bytes.Equal(prefix[:len(numpyMagic)], numpyMagic) {
+		return numpyHeader{}, fmt.Errorf("%w: bad magic", ErrInvalidNumpyFile)
+	}
+
+	// version 1 stores the header length in 2 bytes, later versions in 4
+	lengthSize := 4
+	switch major := prefix[len(numpyMagic)]; major {
+	case 1:
+		lengthSize = 2
+	case 2, 3:
+	default:
+		return numpyHeader{}, fmt.Errorf("%w: version %d", ErrInvalidNumpyFile, major)
+	}
+	b := make([]byte, 4)
+	if _, err := io.ReadFull(r, b[:lengthSize]); err != nil {
+		return numpyHeader{}, err
+	}
+	header := make([]byte, binary.LittleEndian.Uint32(b))
+	if _, err := io.ReadFull(r, header); err != nil {
+		return numpyHeader{}, err
+	}
+	h, err := parseNumpyHeader(string(header))
+	if err != nil {
+		return numpyHeader{}, err
+	}
+	h.dataOffset = len(prefix) + lengthSize + len(header)
+	return h, nil
+}
+
+func parseNumpyHeader(header string) (numpyHeader, error) {
+	var h numpyHeader
+
+	descr := numpyDescrRegexp.FindStringSubmatch(header)
+	if descr == nil {
+		if strings.Contains(header, "'descr': [") {
+			return h, fmt.Errorf("%w: structured numpy dtypes", ErrUnsupportedType)
+		}
+		return h, fmt.Errorf("%w: missing descr", ErrInvalidNumpyFile)
+	}
+	if len(descr[1]) < 3 {
+		return h, unsupportedNumpyDtype(descr[1])
+	}
+	switch descr[1][0] {
+	case '<':
+		h.order = binary.LittleEndian
+	case '>':
+		h.order = binary.BigEndian
+	case '=', '|':
+		h.order = nativeEndian
+	default:
+		return h, unsupportedNumpyDtype(descr[1])
+	}
+	h.dtype = descr[1][1:]
+	switch h.dtype {
+	case "i1", "i2", "i4", "i8", "u1", "u2", "u4", "u8", "f4", "f8":
+	case "b1":
+		h.dtype = "u1"
+	default:
+		return h, unsupportedNumpyDtype(descr[1])
+	}
+
+	fortranOrder := numpyFortranOrderRegexp.FindStringSubmatch(header)
+	if fortranOrder == nil {
+		return h, fmt.Errorf("%w: missing fortran_order", ErrInvalidNumpyFile)
+	}
+	h.fortranOrder = fortranOrder[1] == "True"
+
+	shape := numpyShapeRegexp.FindStringSubmatch(header)
+	if shape == nil {
+		return h, fmt.Errorf("%w: missing shape", ErrInvalidNumpyFile)
+	}
+	h.shape = []int{}
+	for _, d := range strings.Split(shape[1], ",") {
+		d = strings.TrimSpace(d)
+		if d == "" {
+			continue
+		}
+		n, err := strconv.Atoi(strings.TrimSuffix(d, "L"))
+		if err != nil || n < 0 {
+			return h, fmt.Errorf("%w: shape %q", ErrInvalidNumpyFile, shape[1])
+		}
+		h.shape = append(h.shape, n)
+	}
+	count := 1
+	for _, d := range h.shape {
+		if d != 0 && count > math.MaxInt/d {
+			return h, fmt.Errorf("%w: shape %q is too large", ErrInvalidNumpyFile, shape[1])
+		}
+		count *= d
+	}
+	if count > math.MaxInt/h.itemSize() {
+		return h, fmt.Errorf("%w: shape %q is too large", ErrInvalidNumpyFile, shape[1])
+	}
+	return h, nil
+}
+
+var numpyKindNames = map[byte]string{
+	'b': "bool",
+	'i': "signed integer",
+	'u': "unsigned integer",
+	'f': "floating point",
+	'c': "complex",
+	'm': "timedelta",
+	'M': "datetime",
+	'O': "object",
+	'S': "byte string",
+	'a': "byte string",
+	'U': "unicode string",
+	'V': "void",
+}
+
+func unsupportedNumpyDtype(descr string) error {
+	if len(descr) >= 2 {
+		if kind, ok := numpyKindNames[descr[1]]; ok {
+			return fmt.Errorf("%w: numpy dtype %q (%s)", ErrUnsupportedType, descr, kind)
+		}
+	}
+	return fmt.Errorf("%w: numpy dtype %q", ErrUnsupportedType, descr)
+}
+
+// rowMajor reports whether the data is laid out as DataFrame expects.
+func (h numpyHeader) rowMajor() bool {
+	return !h.fortranOrder || len(h.shape) <= 1
+}
+
+// readNumpyData reads the data described by h from r, converting it to T. Data that needs no conversion is read
+// directly into the result; anything else is decoded in chunks. The result grows as the data is read, so that a
+// header claiming more data than r has cannot make it allocate more than r holds.
+func readNumpyData[T SizedNumber](r io.Reader, h numpyHeader) ([]T, error) {
+	var zero T
+	count := h.count()
+	if count > math.MaxInt/int(unsafe.Sizeof(zero)) {
+		return nil, fmt.Errorf("%w: shape %v is too large", ErrInvalidNumpyFile, h.shape)
+	}
+	direct := h.dtype == sizedNumberToDtype[T]() && h.native()
+	size := h.itemSize()
+	var buf []byte
+	if !direct {
+		buf = make([]byte, numpyChunkSize-numpyChunkSize%size)
+	}
+
+	capacity := numpyChunkSize
+	if capacity > count {
+		capacity = count
+	}
+	data := make([]T, 0, capacity)
+	for len(data) < count {
+		n := numpyChunkSize / size
+		if n > count-len(data) {
+			n = count - len(data)
+		}
+		if cap(data)-len(data) < n {
+			grown := 2 * cap(data)
+			if grown > count {
+				grown = count
+			}
+			data = append(make([]T, 0, grown), data...)
+		}
+		chunk := data[len(data) : len(data)+n]
+		if direct {
+			if _, err := io.ReadFull(r, dataBytes(chunk)); err != nil {
+				return nil, err
+			}
+		} else {
+			if _, err := io.ReadFull(r, buf[:n*size]); err != nil {
+				return nil, err
+			}
+			decodeNumpyData(chunk, buf[:n*size], h)
+		}
+		data = data[:len(data)+n]
+	}
+	return toRowMajor(data, h), nil
+}
+
+func readNumpyAnyData(r io.Reader, h numpyHeader) (any, error) {
+	switch h.dtype {
+	case "i1":
+		return readNumpyData[int8](r, h)
+	case "i2":
+		return readNumpyData[int16](r, h)
+	case "i4":
+		return readNumpyData[int32](r, h)
+	case "i8":
+		return readNumpyData[int64](r, h)
+	case "u1":
+		return readNumpyData[uint8](r, h)
+	case "u2":
+		return readNumpyData[uint16](r, h)
+	case "u4":
+		return readNumpyData[uint32](r, h)
+	case "u8":
+		return readNumpyData[uint64](r, h)
+	case "f4":
+		return readNumpyData[float32](r, h)
+	case "f8":
+		return readNumpyData[float64](r, h)
+	default:
+		return nil, ErrUnsupportedType
+	}
+}
+
+// toRowMajor reorders data stored in Fortran (column-major) order into the row-major order DataFrame uses.
+func toRowMajor[T SizedNumber](data []T, h numpyHeader) []T {
+	if h.rowMajor() {
+		return data
+	}
+	strides := make([]int, len(h.shape))
+	stride := 1
+	for i, d := range h.shape {
+		strides[i] = stride
+		stride *= d
+	}
+	res := make([]T, len(data))
+	index := make([]int, len(h.shape))
+	src := 0
+	for i := range res {
+		res[i] = data[src]
+		for j := len(index) - 1; j >= 0; j-- {
+			index[j]++
+			src += strides[j]
+			if index[j] < h.shape[j] {
+				break
+			}
+			index[j] = 0
+			src -= strides[j] * h.shape[j]
+		}
+	}
+	return res
+}
+
+func decodeNumpyData[T SizedNumber](dst []T, src []byte, h numpyHeader) {
+	switch h.dtype {
+	case "i1":
+		for i := range dst {
+			dst[i] = T(int8(src[i]))
+		}
+	case "i2":
+		for i := range dst {
+			dst[i] = T(int16(h.order.Uint16(src[2*i:])))
+		}
+	case "i4":
+		for i := range dst {
+			dst[i] = T(int32(h.order.Uint32(src[4*i:])))
+		}
+	case "i8":
+		for i := range dst {
+			dst[i] = T(int64(h.order.Uint64(src[8*i:])))
+		}
+	case "u1":
+		for i := range dst {
+			dst[i] = T(src[i])
+		}
+	case "u2":
+		for i := range dst {
+			dst[i] = T(h.order.Uint16(src[2*i:]))
+		}
+	case "u4":
+		for i := range dst {
+			dst[i] = T(h.order.Uint32(src[4*i:]))
+		}
+	case "u8":
+		for i := range dst {
+			dst[i] = T(h.order.Uint64(src[8*i:]))
+		}
+	case "f4":
+		for i := range dst {
+			dst[i] = T(math.Float32frombits(h.order.Uint32(src[4*i:])))
+		}
+	case "f8":
+		for i := range dst {
+			dst[i] = T(math.Float64frombits(h.order.Uint64(src[8*i:])))
+		}
+	}
+}
+
+// dataBytes returns the memory of data as a byte slice.
+func dataBytes[T SizedNumber](data []T) []byte {
+	if len(data) == 0 {
+		return nil
+	}
+	var zero T
+	return unsafe.Slice((*byte)(unsafe.Pointer(unsafe.SliceData(data))), len(data)*int(unsafe.Sizeof(zero)))
+}
@@ -0,0 +1,491 @@
+package elefas
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+type (
+	// plan is the order in which a model's layers run, compiled once from its graph. The values flowing between
+	// layers are kept in slots: the model's inputs come first, followed by one slot per step.
+	plan[T SizedNumber] struct {
+		slots   int
+		steps   []step[T]
+		outputs []int
+
+		// consumers counts the steps using each slot, for releasing slots in parallel runs, where steps can finish
+		// in any order. The model's outputs have no count, so they are never released.
+		consumers []int
+		// recyclable tells which slots' buffers can be reused once they are released: those written by an IntoLayer
+		// or a MultiInputIntoLayer and only read by such layers, which do not keep their inputs
+		recyclable []bool
+	}
+
+	step[T SizedNumber] struct {
+		node   *LayerData[T]
+		index  int // the node's index in the model's layersData
+		inputs []int
+		output int
+		// release are the slots this step is the last consumer of
+		release []int
+
+		// dependencies counts the distinct steps this step takes inputs from, and dependents are the steps that take
+		// this step's output as input
+		dependencies int
+		dependents   []int
+
+		// inPlace is set if the step's layer can run in place, taking over the buffer of its input
+		inPlace bool
+	}
+)
+
+func compilePlan[T SizedNumber](m *Model[T]) (*plan[T], error) {
+	indices := make(map[*LayerData[T]]int, len(m.layersData))
+	for i, node := range m.layersData {
+		indices[node] = i
+	}
+	slots := make(map[*LayerData[T]]int, len(m.inputs)+len(m.layersData))
+	for i, input := range m.inputs {
+		slots[input] = i
+	}
+
+	// find the layers the outputs depend on
+	needed := make([]bool, len(m.layersData))
+	var stack []*LayerData[T]
+	for i, output := range m.outputs {
+		if output == nil {
+			return nil, fmt.Errorf("%w: output %d is not set", ErrInvalidModel, i)
+		}
+		stack = append(stack, output)
+	}
+	for len(stack) > 0 {
+		node := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		if _, ok := slots[node]; ok {
+			continue
+		}
+		i, ok := indices[node]
+		if !ok || node.model != m {
+			return nil, fmt.Errorf("%w: layer %s of another model is used", ErrInvalidModel, node.name)
+		}
+		if needed[i] {
+			continue
+		}
+		needed[i] = true
+		stack = append(stack, node.inputs...)
+	}
+	for i, n := range needed {
+		if !n {
+			return nil, fmt.Errorf("%w: layer %s does not lead to any output", ErrInvalidModel,
+				m.layersData[i].name)
+		}
+	}
+
+	// sort the layers topologically, keeping the order they were added in where possible
+	pending := make([]int, len(m.layersData))
+	consumers := make([][]int, len(m.layersData))
+	for i, node := range m.layersData {
+		for j, input := range node.inputs {
+			if _, ok := slots[input]; ok || containsNode(node.inputs[:j], input) {
+				continue
+			}
+			pending[i]++
+			consumers[indices[input]] = append(consumers[indices[input]], i)
+		}
+	}
+	var ready []int
+	for i := range m.layersData {
+		if pending[i] == 0 {
+			ready = append(ready, i)
+		}
+	}
+	p := &plan[T]{slots: len(m.inputs)}
+	for len(ready) > 0 {
+		i := ready[0]
+		ready = ready[1:]
+		node := m.layersData[i]
+		s := step[T]{node: node, index: i, output: p.slots}
+		for _, input := range node.inputs {
+			s.inputs = append(s.inputs, slots[input])
+		}
+		slots[node] = p.slots
+		p.slots++
+		p.steps = append(p.steps, s)
+		for _, consumer := range consumers[i] {
+			if pending[consumer]--; pending[consumer] == 0 {
+				ready = append(ready, consumer)
+			}
+		}
+	}
+	if len(p.steps) < len(m.layersData) {
+		return nil, fmt.Errorf("%w: the layers form a cycle", ErrInvalidModel)
+	}
+
+	for _, output := range m.outputs {
+		p.outputs = append(p.outputs, slots[output])
+	}
+	lastUse := make([]int, p.slots)
+	for i := range lastUse {
+		lastUse[i] = -1
+	}
+	for i, s := range p.steps {
+		for _, input := range s.inputs {
+			lastUse[input] = i
+		}
+	}
+	for _, output := range p.outputs {
+		lastUse[output] = -1
+	}
+	for slot, i := range lastUse {
+		if i >= 0 {
+			p.steps[i].release = append(p.steps[i].release, slot)
+		}
+	}
+
+	p.recyclable = make([]bool, p.slots)
+	for _, s := range p.steps {
+		p.recyclable[s.output] = s.node.writesInto()
+		for _, input := range s.inputs {
+			p.recyclable[input] = p.recyclable[input] && s.node.writesInto()
+		}
+	}
+
+	p.consumers = make([]int, p.slots)
+	for i := range p.steps {
+		s := &p.steps[i]
+		for j, input := range s.inputs {
+			if containsSlot(s.inputs[:j], input) {
+				continue
+			}
+			if lastUse[input] >= 0 {
+				p.consumers[input]++
+			}
+			if input >= len(m.inputs) {
+				s.dependencies++
+				dependency := &p.steps[input-len(m.inputs)]
+				dependency.dependents = append(dependency.dependents, i)
+			}
+		}
+	}
+
+	// a recyclable slot with a single consumer is written by a layer that took it from the pool, and is read by no
+	// other layer, so it can be overwritten
+	for i := range p.steps {
+		s := &p.steps[i]
+		layer, ok := s.node.layer.(InPlaceLayer[T])
+		s.inPlace = ok && layer.SupportsInPlace() && p.recyclable[s.inputs[0]] && p.consumers[s.inputs[0]] == 1
+	}
+	return p, nil
+}
+
+func containsSlot(slots []int, slot int) bool {
+	for _, s := range slots {
+		if s == slot {
+			return true
+		}
+	}
+	return false
+}
+
+func containsNode[T SizedNumber](nodes []*LayerData[T], node *LayerData[T]) bool {
+	for _, n := range nodes {
+		if n == node {
+			return true
+		}
+	}
+	return false
+}
+
+// runOptions are how a plan is run.
+type runOptions[T SizedNumber] struct {
+	// ctx is checked between layers, and passed to the layers that implement ContextLayer
+	ctx context.Context
+	// keep keeps the values of all slots, instead of releasing the slots that are not outputs as soon as they are
+	// no longer needed
+	keep  bool
+	hooks []Hook[T]
+	// pool provides the outputs of the layers that implement IntoLayer or MultiInputIntoLayer, and takes back the
+	// recyclable slots as they are released
+	pool *bufferPool[T]
+}
+
+// run runs the plan on inputs, and returns the values of its slots. Unless o.keep is set, the released slots are
+// left empty.
+func (p *plan[T]) run(inputs []DataFrame[T], o runOptions[T]) ([]DataFrame[T], error) {
+	values := make([]DataFrame[T], p.slots)
+	buffers := make([]*[]T, p.slots)
+	copy(values, inputs)
+	for _, s := range p.steps {
+		if err := o.ctx.Err(); err != nil {
+			return nil, err
+		}
+		var err error
+		if values[s.output], buffers[s.output], err = s.apply(values, buffers, o); err != nil {
+			return nil, err
+		}
+		if o.keep {
+			continue
+		}
+		for _, slot := range s.release {
+			p.release(slot, values, buffers, o.pool)
+		}
+	}
+	return values, nil
+}
+
+// release empties slot, putting its buffer back in pool if it is recyclable.
+func (p *plan[T]) release(slot int, values []DataFrame[T], buffers []*[]T, pool *bufferPool[T]) {
+	values[slot] = DataFrame[T]{}
+	if p.recyclable[slot] && buffers[slot] != nil {
+		pool.put(buffers[slot])
+	}
+	buffers[slot] = nil
+}
+
+func (p *plan[T]) outputValues(values []DataFrame[T]) []DataFrame[T] {
+	outputs := make([]DataFrame[T], len(p.outputs))
+	for i, slot := range p.outputs {
+		outputs[i] = values[slot]
+	}
+	return outputs
+}
+
+// runParallel runs the plan like run, but runs steps whose inputs are ready concurrently, on up to workers
+// goroutines. An error or a panic in a layer stops the run, and is passed on to the caller once the running steps
+// are done.
+func (p *plan[T]) runParallel(inputs []DataFrame[T], workers int, o runOptions[T]) ([]DataFrame[T], error) {
+	type result struct {
+		step     int
+		err      error
+		panicked any
+	}
+	values := make([]DataFrame[T], p.slots)
+	buffers := make([]*[]T, p.slots)
+	copy(values, inputs)
+	consumers := append([]int{}, p.consumers...)
+	dependencies := make([]int, len(p.steps))
+	var ready []int
+	for i, s := range p.steps {
+		dependencies[i] = s.dependencies
+		if s.dependencies == 0 {
+			ready = append(ready, i)
+		}
+	}
+
+	work := make(chan int)
+	done := make(chan result)
+	if workers > len(p.steps) {
+		workers = len(p.steps)
+	}
+	for w := 0; w < workers; w++ {
+		go func() {
+			for i := range work {
+				func() {
+					var err error
+					defer func() {
+						done <- result{step: i, err: err, panicked: recover()}
+					}()
+					s := &p.steps[i]
+					values[s.output], buffers[s.output], err = s.apply(values, buffers, o)
+				}()
+			}
+		}()
+	}
+
+	var err error
+	var panicked any
+	running := 0
+	for finished := 0; finished < len(p.steps); {
+		// hand out the next ready step to an idle worker, or handle a finished one; after an error or a panic, only
+		// wait for the running steps
+		if err == nil {
+			err = o.ctx.Err()
+		}
+		var next chan<- int
+		var i int
+		if len(ready) > 0 && err == nil && panicked == nil {
+			next, i = work, ready[0]
+		} else if running == 0 {
+			break
+		}
+		var r result
+		select {
+		case next <- i:
+			ready = ready[1:]
+			running++
+			continue
+		case r = <-done:
+		}
+		running--
+		finished++
+		if r.panicked != nil && panicked == nil {
+			panicked = r.panicked
+		}
+		if r.err != nil && err == nil {
+			err = r.err
+		}
+		s := &p.steps[r.step]
+		for j, input := range s.inputs {
+			if o.keep || containsSlot(s.inputs[:j], input) || consumers[input] == 0 {
+				continue
+			}
+			if consumers[input]--; consumers[input] == 0 {
+				p.release(input, values, buffers, o.pool)
+			}
+		}
+		for _, dependent := range s.dependents {
+			if dependencies[dependent]--; dependencies[dependent] == 0 {
+				ready = append(ready, dependent)
+			}
+		}
+	}
+	close(work)
+	if panicked != nil {
+		panic(panicked)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return values, nil
+}
+
+// apply runs the step's layer on values, calling the hooks around it. It also returns the buffer of the output, if
+// it was taken from o.pool, or from the step's input when the layer runs in place; the input then no longer owns it.
+func (s *step[T]) apply(values []DataFrame[T], buffers []*[]T, o runOptions[T]) (DataFrame[T], *[]T, error) {
+	var reuse *[]T
+	if s.inPlace && !o.keep {
+		reuse = buffers[s.inputs[0]]
+	}
+	output, buffer, err := s.applyHooked(values, reuse, o)
+	if err == nil && buffer != nil && buffer == reuse {
+		buffers[s.inputs[0]] = nil
+	}
+	return output, buffer, err
+}
+
+func (s *step[T]) applyHooked(values []DataFrame[T], reuse *[]T, o runOptions[T]) (DataFrame[T], *[]T, error) {
+	if len(o.hooks) == 0 {
+		return s.applyLayer(o.ctx, values, o.pool, reuse)
+	}
+	event := LayerEvent[T]{Node: s.node, InputShapes: make([][]int, len(s.inputs))}
+	for i, slot := range s.inputs {
+		event.InputShapes[i] = values[slot].Dims
+	}
+	for _, hook := range o.hooks {
+		hook.BeforeLayer(event)
+	}
+	allocated := heapAllocatedBytes()
+	start := time.Now()
+	output, buffer, err := s.applyLayer(o.ctx, values, o.pool, reuse)
+	event.Elapsed = time.Since(start)
+	event.AllocatedBytes = heapAllocatedBytes() - allocated
+	event.OutputShape, event.Err = output.Dims, err
+	for _, hook := range o.hooks {
+		hook.AfterLayer(event)
+	}
+	return output, buffer, err
+}
+
+// applyLayer runs the step's layer on values. IntoLayers write into reuse if it is not nil and has the size of their
+// output, or else into a buffer taken from pool.
+func (s *step[T]) applyLayer(ctx context.Context, values []DataFrame[T], pool *bufferPool[T], reuse *[]T) (
+	output DataFrame[T], buffer *[]T, err error) {
+
+	var dims []int
+	if s.node.multiLayer == nil {
+		input := values[s.inputs[0]]
+		switch layer := s.node.layer.(type) {
+		case IntoLayer[T]:
+			if dims, err = layer.OutputShape(input.Dims); err != nil {
+				break
+			}
+			if reuse != nil && len(*reuse) == dimsSize(dims) {
+				output, buffer = DataFrame[T]{Dims: dims, Data: *reuse}, reuse
+			} else {
+				output, buffer = pool.get(dims)
+			}
+			if contextLayer, ok := layer.(ContextIntoLayer[T]); ok {
+				err = contextLayer.ApplyIntoContext(ctx, output, input)
+			} else {
+				err = layer.ApplyInto(output, input)
+			}
+		case ContextLayer[T]:
+			output, err = layer.ApplyContext(ctx, input)
+		case LayerE[T]:
+			output, err = layer.ApplyE(input)
+		default:
+			output = layer.Apply(input)
+		}
+	} else {
+		inputs := make([]DataFrame[T], len(s.inputs))
+		for i, slot := range s.inputs {
+			inputs[i] = values[slot]
+		}
+		switch layer := s.node.multiLayer.(type) {
+		case MultiInputIntoLayer[T]:
+			inputShapes := make([][]int, len(inputs))
+			for i, input := range inputs {
+				inputShapes[i] = input.Dims
+			}
+			if dims, err = layer.OutputShapeMulti(inputShapes); err != nil {
+				break
+			}
+			output, buffer = pool.get(dims)
+			err = layer.ApplyMultiInto(output, inputs)
+		case MultiInputLayerE[T]:
+			output, err = layer.ApplyMultiE(inputs)
+		default:
+			output = layer.ApplyMulti(inputs)
+		}
+	}
+	if err != nil && buffer != nil && buffer != reuse {
+		pool.put(buffer)
+	}
+	if ctxErr := ctx.Err(); ctxErr != nil && err == ctxErr {
+		return DataFrame[T]{}, nil, err
+	} else if err != nil {
+		return DataFrame[T]{}, nil, &LayerError{Index: s.index, Name: s.node.name, Err: err}
+	}
+	return output, buffer, nil
+}
+
+// writesInto reports whether the node's layer implements IntoLayer or MultiInputIntoLayer.
+func (d *LayerData[T]) writesInto() bool {
+	if d.multiLayer != nil {
+		_, ok := d.multiLayer.(MultiInputIntoLayer[T])
+		return ok
+	}
+	_, ok := d.layer.(IntoLayer[T])
+	return ok
+}
+
+// outputShape infers the shape of the step's output from the shapes of the slots. It returns nil if a shape is unknown.
+func (s *step[T]) outputShape(shapes [][]int) ([]int, error) {
+	inputShapes := make([][]int, len(s.inputs))
+	for i, slot := range s.inputs {
+		if shapes[slot] == nil {
+			return nil, nil
+		}
+		inputShapes[i] = shapes[slot]
+	}
+	var shape []int
+	var err error
+	if s.node.multiLayer == nil {
+		layer, ok := s.node.layer.(ShapeLayer)
+		if !ok {
+			return nil, nil
+		}
+		shape, err = layer.OutputShape(inputShapes[0])
+	} else {
+		layer, ok := s.node.multiLayer.(MultiInputShapeLayer)
+		if !ok {
+			return nil, nil
+		}
+		shape, err = layer.OutputShapeMulti(inputShapes)
+	}
+	if err != nil {
+		return nil, &LayerError{Index: s.index, Name: s.node.name, Err: err}
+	}
+	return shape, nil
+}
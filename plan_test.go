@@ -0,0 +1,111 @@
+package elefas
+
+import (
+	"context"
+	"reflect"
+	"testing"
+)
+
+type identityLayer struct{}
+
+func (identityLayer) Apply(input DataFrame[float32]) DataFrame[float32] { return input }
+
+type concatLayer struct{}
+
+func (concatLayer) ApplyMulti(inputs []DataFrame[float32]) DataFrame[float32] {
+	var output DataFrame[float32]
+	for _, input := range inputs {
+		output.Data = append(output.Data, input.Data...)
+	}
+	output.Dims = []int{len(output.Data)}
+	return output
+}
+
+func TestPlanRelease(t *testing.T) {
+	m := NewModel[float32](1, 2)
+	a := m.AddLayer(identityLayer{}, nil)
+	b := a.AddLayer(identityLayer{})
+	c := m.AddMultiInputLayer(concatLayer{}, a, b)
+	d := c.AddLayer(identityLayer{})
+	m.SetOutput(d, 0)
+	m.SetOutput(a, 1)
+
+	p, err := compilePlan(m)
+	if err != nil {
+		t.Fatalf("error compiling: %v", err)
+	}
+	// slots: 0 is the input, then a=1, b=2, c=3, d=4; a is an output, so it is never released
+	expected := [][]int{{0}, nil, {2}, {3}}
+	for i, s := range p.steps {
+		if !reflect.DeepEqual(s.release, expected[i]) {
+			t.Errorf("step %d releases %v, expected %v", i, s.release, expected[i])
+		}
+	}
+
+	input := DataFrame[float32]{Dims: []int{1}, Data: []float32{1}}
+	values, err := p.run([]DataFrame[float32]{input}, runOptions[float32]{ctx: context.Background()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	outputs := p.outputValues(values)
+	if !reflect.DeepEqual(outputs[0].Data, []float32{1, 1}) || !reflect.DeepEqual(outputs[1].Data, []float32{1}) {
+		t.Errorf("predicted %v", outputs)
+	}
+}
+
+type negateLayer struct{}
+
+func (l negateLayer) Apply(input DataFrame[float32]) DataFrame[float32] {
+	output := MakeDataFrame[float32](input.Dims)
+	_ = l.ApplyInto(output, input)
+	return output
+}
+
+func (negateLayer) OutputShape(inputShape []int) ([]int, error) { return inputShape, nil }
+
+func (negateLayer) ApplyInto(dst, input DataFrame[float32]) error {
+	for i, v := range input.Data {
+		dst.Data[i] = -v
+	}
+	return nil
+}
+
+func (negateLayer) SupportsInPlace() bool { return true }
+
+func TestPlanInPlace(t *testing.T) {
+	m := NewModel[float32](1, 2)
+	a := m.AddLayer(negateLayer{}, nil)
+	b := a.AddLayer(negateLayer{})
+	c := b.AddLayer(negateLayer{})
+	d := b.AddLayer(identityLayer{})
+	e := c.AddLayer(negateLayer{})
+	m.SetOutput(e, 0)
+	m.SetOutput(d, 1)
+
+	p, err := compilePlan(m)
+	if err != nil {
+		t.Fatalf("error compiling: %v", err)
+	}
+	// a takes the model's input, and the output of b is also taken by d, which may keep it
+	expected := []bool{false, true, false, false, true}
+	for i, s := range p.steps {
+		if s.inPlace != expected[i] {
+			t.Errorf("step %d runs in place: %v, expected %v", i, s.inPlace, expected[i])
+		}
+	}
+
+	var pool bufferPool[float32]
+	input := DataFrame[float32]{Dims: []int{2}, Data: []float32{1, 2}}
+	for call := 0; call < 3; call++ {
+		o := runOptions[float32]{ctx: context.Background(), pool: &pool}
+		values, err := p.run([]DataFrame[float32]{input}, o)
+		if err != nil {
+			t.Fatal(err)
+		}
+		outputs := p.outputValues(values)
+		if !reflect.DeepEqual(outputs[0].Data, input.Data) || !reflect.DeepEqual(outputs[1].Data, input.Data) ||
+			!reflect.DeepEqual(input.Data, []float32{1, 2}) {
+			t.Errorf("call %d: predicted %v from %v", call, outputs, input)
+		}
+	}
+}
@@ -0,0 +1,8 @@
+//go:build race
+
+package elefas_test
+
+func init() {
+	// the race detector makes sync.Pool drop buffers at random
+	raceEnabled = true
+}
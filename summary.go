@@ -0,0 +1,113 @@
+package elefas
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"unsafe"
+)
+
+// ParamLayer is a layer that has weights, such as Dense. Model.Summary reports ParamCount for the layers that
+// implement it, and no parameters for the others.
+type ParamLayer interface {
+	ParamCount() int
+}
+
+// Summary writes a table of the model's nodes to w, like Keras's model.summary(): the type of every layer, its
+// output shape as inferred by the last Build, its number of parameters, and the nodes it takes its inputs from. It
+// ends with the total number of parameters and the memory they take.
+func (m *Model[T]) Summary(w io.Writer) {
+	var table strings.Builder
+	tw := tabwriter.NewWriter(&table, 0, 0, 3, ' ', 0)
+	fmt.Fprintln(tw, "Layer (type)\tOutput Shape\tParam #\tConnected to")
+	for _, input := range m.inputs {
+		fmt.Fprintf(tw, "%s (Input)\t%s\t0\t\n", input.name, formatShape(input.shape))
+	}
+	total := 0
+	for _, node := range m.layersData {
+		params := node.paramCount()
+		total += params
+		inputs := make([]string, len(node.inputs))
+		for i, input := range node.inputs {
+			inputs[i] = input.name
+		}
+		fmt.Fprintf(tw, "%s (%s)\t%s\t%s\t%s\n", node.name, node.typeName(), formatShape(node.shape),
+			formatCount(params), strings.Join(inputs, ", "))
+	}
+	tw.Flush()
+
+	lines := strings.Split(strings.TrimSuffix(table.String(), "\n"), "\n")
+	width := 0
+	for i, line := range lines {
+		lines[i] = strings.TrimRight(line, " ")
+		if len(lines[i]) > width {
+			width = len(lines[i])
+		}
+	}
+	rule := strings.Repeat("=", width)
+	fmt.Fprintln(w, rule)
+	fmt.Fprintln(w, lines[0])
+	fmt.Fprintln(w, rule)
+	for _, line := range lines[1:] {
+		fmt.Fprintln(w, line)
+	}
+	fmt.Fprintln(w, rule)
+
+	size := total * int(unsafe.Sizeof(T(0)))
+	fmt.Fprintf(w, "Total params: %s (%s)\n", formatCount(total), formatBytes(size))
+	fmt.Fprintf(w, "Memory by dtype: %T: %s\n", T(0), formatBytes(size))
+}
+
+func (d *LayerData[T]) paramCount() int {
+	var layer any = d.layer
+	if d.multiLayer != nil {
+		layer = d.multiLayer
+	}
+	if l, ok := layer.(ParamLayer); ok {
+		return l.ParamCount()
+	}
+	return 0
+}
+
+// formatShape formats shape like Python does, with None for unknown dimensions.
+func formatShape(shape []int) string {
+	if shape == nil {
+		return "?"
+	}
+	dims := make([]string, len(shape))
+	for i, d := range shape {
+		if d == -1 {
+			dims[i] = "None"
+		} else {
+			dims[i] = strconv.Itoa(d)
+		}
+	}
+	if len(dims) == 1 {
+		return "(" + dims[0] + ",)"
+	}
+	return "(" + strings.Join(dims, ", ") + ")"
+}
+
+// formatCount formats n with thousands separators.
+func formatCount(n int) string {
+	s := strconv.Itoa(n)
+	for i := len(s) - 3; i > 0; i -= 3 {
+		s = s[:i] + "," + s[i:]
+	}
+	return s
+}
+
+func formatBytes(n int) string {
+	if n < 1024 {
+		return strconv.Itoa(n) + " B"
+	}
+	size := float64(n)
+	unit := 0
+	units := []string{"KiB", "MiB", "GiB", "TiB"}
+	for size /= 1024; size >= 1024 && unit < len(units)-1; size /= 1024 {
+		unit++
+	}
+	return fmt.Sprintf("%.2f %s", size, units[unit])
+}
@@ -0,0 +1,485 @@
+# Writes the HDF5 files of hdf5_test.go. The files are written byte by byte, without h5py or the HDF5 library, so that
+# each of them has exactly the structures it is meant to test. They follow the layouts h5py writes: v0.h5 those of
+# the default file format, which is what the weight files of Keras use, and v2.h5 and v3.h5 those of
+# libver="latest". Run it from this directory.
+import struct
+import zlib
+
+UNDEFINED = 2**64 - 1
+
+
+def pad8(b):
+    return b + b"\0" * (-len(b) % 8)
+
+
+def rot(x, k):
+    return ((x << k) | (x >> (32 - k))) & 0xffffffff
+
+
+# lookup3 is Bob Jenkins' hashlittle, which HDF5 uses for the checksums of the structures of its newer formats
+def lookup3(data, initval=0):
+    m = 0xffffffff
+    a = b = c = (0xdeadbeef + len(data) + initval) & m
+    if not data:
+        return c
+    data = data + b"\0" * (-len(data) % 12)
+    words = struct.unpack("<%dI" % (len(data) // 4), data)
+    for i in range(0, len(words) - 3, 3):
+        a, b, c = (a + words[i]) & m, (b + words[i + 1]) & m, (c + words[i + 2]) & m
+        a = (a - c) & m; a ^= rot(c, 4); c = (c + b) & m
+        b = (b - a) & m; b ^= rot(a, 6); a = (a + c) & m
+        c = (c - b) & m; c ^= rot(b, 8); b = (b + a) & m
+        a = (a - c) & m; a ^= rot(c, 16); c = (c + b) & m
+        b = (b - a) & m; b ^= rot(a, 19); a = (a + c) & m
+        c = (c - b) & m; c ^= rot(b, 4); b = (b + a) & m
+    a, b, c = (a + words[-3]) & m, (b + words[-2]) & m, (c + words[-1]) & m
+    c ^= b; c = (c - rot(b, 14)) & m
+    a ^= c; a = (a - rot(c, 11)) & m
+    b ^= a; b = (b - rot(a, 25)) & m
+    c ^= b; c = (c - rot(b, 16)) & m
+    a ^= c; a = (a - rot(c, 4)) & m
+    b ^= a; b = (b - rot(a, 14)) & m
+    c ^= b; c = (c - rot(b, 24)) & m
+    return c
+
+
+def fletcher32(data):
+    if len(data) % 2:
+        data += b"\0"
+    sum1 = sum2 = 0
+    for i in range(0, len(data), 2):
+        sum1 = (sum1 + (data[i] << 8 | data[i + 1])) % 0xffff
+        sum2 = (sum2 + sum1) % 0xffff
+    return struct.pack("<I", sum2 << 16 | sum1)
+
+
+class File:
+    def __init__(self):
+        self.buf = bytearray()
+
+    def alloc(self, size):
+        self.buf += b"\0" * (-len(self.buf) % 8)
+        addr = len(self.buf)
+        self.buf += b"\0" * size
+        return addr
+
+    def write(self, data):
+        addr = self.alloc(len(data))
+        self.put(addr, data)
+        return addr
+
+    def put(self, addr, data):
+        self.buf[addr:addr + len(data)] = data
+
+
+# datatypes
+
+def integer(size, signed=True, big_endian=False):
+    flags = (0x08 if signed else 0) | (0x01 if big_endian else 0)
+    return bytes([0x10, flags, 0, 0]) + struct.pack("<IHH", size, 0, size * 8)
+
+
+def floating(size):
+    if size == 4:
+        return bytes([0x11, 0x20, 31, 0]) + struct.pack("<IHHBBBBI", 4, 0, 32, 23, 8, 0, 23, 127)
+    return bytes([0x11, 0x20, 63, 0]) + struct.pack("<IHHBBBBI", 8, 0, 64, 52, 11, 0, 52, 1023)
+
+
+# padding is 0 for null terminated strings, 1 for null padded ones and 2 for space padded ones
+def fixed_string(size, padding=1):
+    return bytes([0x13, padding, 0, 0]) + struct.pack("<I", size)
+
+
+def variable_string():
+    return bytes([0x19, 0x01, 0x01, 0]) + struct.pack("<I", 16) + integer(1, signed=False)
+
+
+# dataspaces, where a shape of None is a scalar
+
+def dataspace_v1(shape, max_shape=None):
+    shape = shape or []
+    flags = 1 if max_shape else 0
+    b = struct.pack("<BBBB4x", 1, len(shape), flags, 0) + b"".join(struct.pack("<Q", d) for d in shape)
+    return b + b"".join(struct.pack("<Q", d) for d in max_shape or [])
+
+
+def dataspace_v2(shape):
+    if shape is None:
+        return struct.pack("<BBBB", 2, 0, 0, 0)
+    return struct.pack("<BBBB", 2, len(shape), 0, 1) + b"".join(struct.pack("<Q", d) for d in shape)
+
+
+# object headers, which take their messages as (type, data) pairs
+
+def object_header_v1(f, messages, continued=()):
+    def encode(messages):
+        return b"".join(struct.pack("<HHB3x", typ, len(pad8(data)), 0) + pad8(data) for typ, data in messages)
+
+    if continued:
+        block = encode(continued)
+        addr = f.write(block)
+        messages = list(messages) + [(0x10, struct.pack("<QQ", addr, len(block)))]
+    body = encode(messages)
+    count = len(messages) + len(continued)
+    return f.write(struct.pack("<BBHII4x", 1, 0, count, 1, len(body)) + body)
+
+
+def object_header_v2(f, messages, continued=()):
+    def encode(messages):
+        return b"".join(struct.pack("<BHB", typ, len(data), 0) + data for typ, data in messages)
+
+    if continued:
+        block = b"OCHK" + encode(continued)
+        block += struct.pack("<I", lookup3(block))
+        addr = f.write(block)
+        messages = list(messages) + [(0x10, struct.pack("<QQ", addr, len(block)))]
+    body = encode(messages)
+    header = b"OHDR" + bytes([2, 0x02]) + struct.pack("<I", len(body)) + body
+    return f.write(header + struct.pack("<I", lookup3(header)))
+
+
+def attribute_v1(name, datatype, dataspace, data):
+    name = name.encode() + b"\0"
+    return struct.pack("<BBHHH", 1, 0, len(name), len(datatype), len(dataspace)) + pad8(name) + pad8(datatype) + \
+        pad8(dataspace) + data
+
+
+def attribute_v3(name, datatype, dataspace, data):
+    name = name.encode() + b"\0"
+    return struct.pack("<BBHHHB", 3, 0, len(name), len(datatype), len(dataspace), 0) + name + datatype + \
+        dataspace + data
+
+
+# global_heap stores strings in a new global heap collection, and returns the data of a variable-length string
+# attribute or dataset holding them
+def global_heap(f, strings):
+    objects = b""
+    for i, s in enumerate(strings, 1):
+        objects += struct.pack("<HH4xQ", i, 1, len(s)) + pad8(s)
+    size = max(4096, 16 + len(objects) + 16)
+    collection = b"GCOL" + bytes([1, 0, 0, 0]) + struct.pack("<Q", size) + objects
+    collection += struct.pack("<HH4xQ", 0, 0, size - len(collection))
+    addr = f.write(collection + b"\0" * (size - len(collection)))
+    return b"".join(struct.pack("<IQI", len(s), addr, i) for i, s in enumerate(strings, 1))
+
+
+# groups, which take their members as a dict of names to object header addresses
+
+def symbol_table_group(f, members, messages=(), continued=()):
+    names = sorted(members)
+    heap = bytearray(8)
+    offsets = []
+    for name in names:
+        offsets.append(len(heap))
+        heap += pad8(name.encode() + b"\0")
+    heap_data = f.write(bytes(heap))
+    heap_addr = f.write(b"HEAP" + bytes(4) + struct.pack("<QQQ", len(heap), UNDEFINED, heap_data))
+
+    node = b"SNOD" + struct.pack("<BBH", 1, 0, len(names))
+    for name, offset in zip(names, offsets):
+        node += struct.pack("<QQII16x", offset, members[name], 0, 0)
+    node_addr = f.write(node)
+    last_key = offsets[-1] if offsets else 0
+    tree = b"TREE" + struct.pack("<BBHQQ", 0, 0, 1, UNDEFINED, UNDEFINED) + struct.pack("<QQQ", 0, node_addr, last_key)
+    tree_addr = f.write(tree)
+
+    messages = [(0x11, struct.pack("<QQ", tree_addr, heap_addr))] + list(messages)
+    return object_header_v1(f, messages, continued), tree_addr, heap_addr
+
+
+def link(name, addr):
+    name = name.encode()
+    return bytes([1, 0, len(name)]) + name + struct.pack("<Q", addr)
+
+
+def link_group(f, members, messages=(), continued=()):
+    messages = [(0x02, bytes([0, 0]) + struct.pack("<QQ", UNDEFINED, UNDEFINED)), (0x0a, bytes([0, 0]))] + \
+        [(0x06, link(name, addr)) for name, addr in members.items()] + list(messages)
+    return object_header_v2(f, messages, continued)
+
+
+# datasets, with the messages of the default file format, or of libver="latest" if latest is set
+
+def dataset_messages(datatype, shape, layout, latest, allocation_time=2):
+    if latest:
+        return [(0x01, dataspace_v2(shape)), (0x03, datatype), (0x05, bytes([3, allocation_time | 2 << 2])),
+                (0x08, layout)]
+    return [(0x01, dataspace_v1(shape)), (0x03, datatype), (0x05, bytes([2, allocation_time, 2, 0])),
+            (0x08, layout)]
+
+
+def contiguous_dataset(f, datatype, shape, data, attributes=(), latest=False, size=None):
+    addr = f.write(data)
+    layout = struct.pack("<BBQQ", 3, 1, addr, len(data) if size is None else size)
+    header = object_header_v2 if latest else object_header_v1
+    return header(f, dataset_messages(datatype, shape, layout, latest) + list(attributes))
+
+
+def compact_dataset(f, datatype, shape, data, attributes=(), latest=False):
+    layout = struct.pack("<BBH", 3, 0, len(data)) + data
+    header = object_header_v2 if latest else object_header_v1
+    return header(f, dataset_messages(datatype, shape, layout, latest, allocation_time=1) + list(attributes))
+
+
+# chunks splits the elements of a row-major array into chunks, padded with zeros at the edges, and returns their
+# offsets with their data
+def chunks(fmt, shape, chunk_shape, elements):
+    counts = [(s + c - 1) // c for s, c in zip(shape, chunk_shape)]
+    result = []
+    for index in product(counts):
+        offsets = [i * c for i, c in zip(index, chunk_shape)]
+        values = []
+        for local in product(chunk_shape):
+            position = [o + l for o, l in zip(offsets, local)]
+            if all(p < s for p, s in zip(position, shape)):
+                flat = 0
+                for p, s in zip(position, shape):
+                    flat = flat * s + p
+                values.append(elements[flat])
+            else:
+                values.append(0)
+        result.append((offsets, struct.pack("<%d%s" % (len(values), fmt), *values)))
+    return result
+
+
+def product(counts):
+    if not counts:
+        yield []
+        return
+    for i in range(counts[0]):
+        for rest in product(counts[1:]):
+            yield [i] + rest
+
+
+def shuffle(data, size):
+    count = len(data) // size
+    return bytes(data[i % count * size + i // count] for i in range(len(data)))
+
+
+# filter_chunk applies the filters of a pipeline to a chunk, in order, where the filters are "shuffle", "deflate" and
+# "fletcher32"
+def filter_chunk(data, filters, size):
+    for name in filters:
+        if name == "shuffle":
+            data = shuffle(data, size)
+        elif name == "deflate":
+            data = zlib.compress(data, 4)
+        else:
+            data += fletcher32(data)
+    return data
+
+
+def filter_pipeline(version, filters, size):
+    ids = {"deflate": 1, "shuffle": 2, "fletcher32": 3}
+    values = {"deflate": [4], "shuffle": [size], "fletcher32": []}
+    b = bytes([version, len(filters)]) + (bytes(6) if version == 1 else b"")
+    for name in filters:
+        if version == 1:
+            encoded = pad8(name.encode() + b"\0")
+            b += struct.pack("<HHHH", ids[name], len(encoded), 0, len(values[name])) + encoded
+        else:
+            b += struct.pack("<HHH", ids[name], 0, len(values[name]))
+        b += b"".join(struct.pack("<I", v) for v in values[name])
+        if version == 1 and len(values[name]) % 2:
+            b += bytes(4)
+    return b
+
+
+# btree_chunked_dataset writes a chunked dataset indexed by a version 1 B-tree, with a version 3 layout message,
+# which is how h5py writes them by default
+def btree_chunked_dataset(f, datatype, fmt, shape, chunk_shape, elements, filters, latest=False):
+    size = struct.calcsize(fmt)
+    entries = []
+    for offsets, data in chunks(fmt, shape, chunk_shape, elements):
+        data = filter_chunk(data, filters, size)
+        entries.append((offsets, f.write(data), len(data)))
+    return btree_chunked_layout(f, datatype, size, shape, chunk_shape, chunk_btree(f, shape, entries), filters,
+                                latest)
+
+
+# chunk_btree writes a B-tree leaf indexing chunks, given as (offsets, address, stored size) entries
+def chunk_btree(f, shape, entries):
+    node = b"TREE" + struct.pack("<BBHQQ", 1, 0, len(entries), UNDEFINED, UNDEFINED)
+    for offsets, addr, length in entries:
+        node += struct.pack("<II", length, 0) + b"".join(struct.pack("<Q", o) for o in offsets + [0])
+        node += struct.pack("<Q", addr)
+    node += struct.pack("<II", 0, 0) + b"".join(struct.pack("<Q", s) for s in shape + [0])
+    return f.write(node)
+
+
+def btree_chunked_layout(f, datatype, size, shape, chunk_shape, tree_addr, filters, latest=False):
+    layout = struct.pack("<BBBQ", 3, 2, len(shape) + 1, tree_addr)
+    layout += b"".join(struct.pack("<I", c) for c in chunk_shape + [size])
+    messages = dataset_messages(datatype, shape, layout, latest, allocation_time=3)
+    if not latest:
+        messages[0] = (0x01, dataspace_v1(shape, shape))
+    if filters:
+        messages.append((0x0b, filter_pipeline(2 if latest else 1, filters, size)))
+    return (object_header_v2 if latest else object_header_v1)(f, messages)
+
+
+# single_chunk_dataset writes a dataset stored in a single chunk, with a version 4 layout message
+def single_chunk_dataset(f, datatype, fmt, shape, elements, filters):
+    size = struct.calcsize(fmt)
+    data = filter_chunk(struct.pack("<%d%s" % (len(elements), fmt), *elements), filters, size)
+    addr = f.write(data)
+    layout = struct.pack("<BBBBB", 4, 2, 0x02 if filters else 0, len(shape) + 1, 4)
+    layout += b"".join(struct.pack("<I", d) for d in shape + [size]) + bytes([1])
+    if filters:
+        layout += struct.pack("<QI", len(data), 0)
+    layout += struct.pack("<Q", addr)
+    messages = dataset_messages(datatype, shape, layout, True, allocation_time=1)
+    if filters:
+        messages.append((0x0b, filter_pipeline(2, filters, size)))
+    return object_header_v2(f, messages)
+
+
+# implicit_chunked_dataset writes a dataset whose chunks are all allocated one after the other, with a version 4
+# layout message
+def implicit_chunked_dataset(f, datatype, fmt, shape, chunk_shape, elements):
+    data = b"".join(chunk for _, chunk in chunks(fmt, shape, chunk_shape, elements))
+    addr = f.write(data)
+    layout = struct.pack("<BBBBB", 4, 2, 0, len(shape) + 1, 4)
+    layout += b"".join(struct.pack("<I", d) for d in chunk_shape + [struct.calcsize(fmt)]) + bytes([2])
+    layout += struct.pack("<Q", addr)
+    return object_header_v2(f, dataset_messages(datatype, shape, layout, True, allocation_time=1))
+
+
+def superblock_v0(f, root, tree_addr, heap_addr):
+    b = b"\x89HDF\r\n\x1a\n" + bytes([0, 0, 0, 0, 0, 8, 8, 0]) + struct.pack("<HHI", 4, 16, 0)
+    b += struct.pack("<QQQQ", 0, UNDEFINED, len(f.buf), UNDEFINED)
+    b += struct.pack("<QQII", 0, root, 1, 0) + struct.pack("<QQ", tree_addr, heap_addr)
+    f.put(0, b)
+
+
+def superblock_v2(f, version, root):
+    b = b"\x89HDF\r\n\x1a\n" + bytes([version, 8, 8, 0]) + struct.pack("<QQQQ", 0, UNDEFINED, len(f.buf), root)
+    f.put(0, b + struct.pack("<I", lookup3(b)))
+
+
+DENSE_KERNEL = [1, 2, 3, 4, 5, 6]
+DENSE_BIAS = [0.5, -0.25, 0.125]
+COMPACT = [-1, 2, -3, 4]
+CHUNKED = [i / 2 for i in range(5 * 7)]
+BIG_ENDIAN = [1, -2, 300]
+SINGLE = [i - 5 for i in range(3 * 4)]
+IMPLICIT = [1, 2, 3, 4, 5]
+
+
+def f4(values):
+    return struct.pack("<%df" % len(values), *values)
+
+
+def dense_group(f, latest):
+    kernel = contiguous_dataset(f, floating(4), [2, 3], f4(DENSE_KERNEL), latest=latest)
+    bias = contiguous_dataset(f, floating(4), [3], f4(DENSE_BIAS), latest=latest)
+    group = link_group if latest else lambda f, members: symbol_table_group(f, members)[0]
+    return group(f, {"dense": group(f, {"vars": group(f, {"0": kernel, "1": bias})})})
+
+
+def write_v0(path):
+    f = File()
+    f.alloc(96)
+    layers = dense_group(f, False)
+    unit = attribute_v1("unit", fixed_string(4, padding=2), dataspace_v1(None), b"m   ")
+    compact = compact_dataset(f, integer(4), [2, 2], struct.pack("<4i", *COMPACT), [(0x0c, unit)])
+    chunked = btree_chunked_dataset(f, floating(8), "d", [5, 7], [2, 3], CHUNKED, ["shuffle", "deflate", "fletcher32"])
+    big_endian = contiguous_dataset(f, integer(2, big_endian=True), [3], struct.pack(">3h", *BIG_ENDIAN))
+    strings = contiguous_dataset(f, fixed_string(8), [2], b"first\0\0\0second\0\0")
+    names = global_heap(f, [b"dense", b"dense_1"])
+    root, tree_addr, heap_addr = symbol_table_group(
+        f, {"layers": layers, "compact": compact, "chunked": chunked, "big_endian": big_endian, "strings": strings},
+        messages=[(0x0c, attribute_v1("version", integer(4), dataspace_v1(None), struct.pack("<i", 3)))],
+        continued=[(0x0c, attribute_v1("model_config", fixed_string(16), dataspace_v1(None), b'{"name": "v0"}\0\0')),
+                   (0x0c, attribute_v1("names", variable_string(), dataspace_v1([2]), names))])
+    superblock_v0(f, root, tree_addr, heap_addr)
+    open(path, "wb").write(f.buf)
+
+
+def write_v2(path, version):
+    f = File()
+    f.alloc(48)
+    layers = dense_group(f, True)
+    unit = attribute_v3("unit", fixed_string(4, padding=2), dataspace_v2(None), b"m   ")
+    compact = compact_dataset(f, integer(4), [2, 2], struct.pack("<4i", *COMPACT), [(0x0c, unit)], latest=True)
+    chunked = btree_chunked_dataset(f, floating(8), "d", [5, 7], [2, 3], CHUNKED, ["shuffle", "deflate", "fletcher32"],
+                                    latest=True)
+    single = single_chunk_dataset(f, floating(4), "f", [3, 4], SINGLE, ["deflate", "fletcher32"])
+    implicit = implicit_chunked_dataset(f, integer(1, signed=False), "B", [5], [2], IMPLICIT)
+    names = global_heap(f, [b"dense", b"dense_1"])
+    root = link_group(
+        f, {"layers": layers, "compact": compact},
+        messages=[(0x0c, attribute_v3("version", integer(4), dataspace_v2(None), struct.pack("<i", 3)))],
+        continued=[(0x06, link("chunked", chunked)), (0x06, link("single", single)),
+                   (0x06, link("implicit", implicit)),
+                   (0x0c, attribute_v3("model_config", fixed_string(15, padding=0), dataspace_v2(None),
+                                       b'{"name": "v2"}\0')),
+                   (0x0c, attribute_v3("names", variable_string(), dataspace_v2([2]), names))])
+    superblock_v2(f, version, root)
+    open(path, "wb").write(f.buf)
+
+
+# write_loop writes a file whose root group has an object header continuation that continues into itself
+def write_loop(path):
+    f = File()
+    f.alloc(96)
+    block = f.alloc(24)
+    f.put(block, struct.pack("<HHB3xQQ", 0x10, 16, 0, block, 24))
+    root, tree_addr, heap_addr = symbol_table_group(f, {}, messages=[(0x10, struct.pack("<QQ", block, 24))])
+    superblock_v0(f, root, tree_addr, heap_addr)
+    open(path, "wb").write(f.buf)
+
+
+# write_huge writes a file with a dataset that claims to be much larger than the file
+def write_huge(path):
+    f = File()
+    f.alloc(96)
+    huge = contiguous_dataset(f, floating(4), [2**37], f4([0]), size=2**39)
+    root, tree_addr, heap_addr = symbol_table_group(f, {"huge": huge})
+    superblock_v0(f, root, tree_addr, heap_addr)
+    open(path, "wb").write(f.buf)
+
+
+# write_zero_chunk writes a file with a dataset whose chunks, indexed implicitly, have a size of 0
+def write_zero_chunk(path):
+    f = File()
+    f.alloc(48)
+    addr = f.write(bytes(5))
+    layout = struct.pack("<BBBBB", 4, 2, 0, 2, 4) + struct.pack("<II", 0, 1) + bytes([2]) + struct.pack("<Q", addr)
+    empty = object_header_v2(f, dataset_messages(integer(1, signed=False), [5], layout, True, allocation_time=1))
+    superblock_v2(f, 2, link_group(f, {"empty": empty}))
+    open(path, "wb").write(f.buf)
+
+
+# write_huge_chunked writes a file with a chunked dataset that claims to be much larger than the file, whose chunks
+# were never allocated
+def write_huge_chunked(path):
+    f = File()
+    f.alloc(96)
+    huge = btree_chunked_layout(f, integer(1, signed=False), 1, [2**46], [2**20], UNDEFINED, [])
+    root, tree_addr, heap_addr = symbol_table_group(f, {"huge": huge})
+    superblock_v0(f, root, tree_addr, heap_addr)
+    open(path, "wb").write(f.buf)
+
+
+# write_bomb writes a file with a chunk of 16 bytes whose stored data decompresses to a megabyte
+def write_bomb(path):
+    f = File()
+    f.alloc(96)
+    data = zlib.compress(bytes(2**20), 9)
+    tree_addr = chunk_btree(f, [4], [([0], f.write(data), len(data))])
+    bomb = btree_chunked_layout(f, floating(4), 4, [4], [4], tree_addr, ["deflate"])
+    root, tree_addr, heap_addr = symbol_table_group(f, {"bomb": bomb})
+    superblock_v0(f, root, tree_addr, heap_addr)
+    open(path, "wb").write(f.buf)
+
+
+if __name__ == "__main__":
+    write_v0("hdf5/v0.h5")
+    write_v2("hdf5/v2.h5", 2)
+    write_v2("hdf5/v3.h5", 3)
+    write_loop("hdf5/loop.h5")
+    write_huge("hdf5/huge.h5")
+    write_zero_chunk("hdf5/zero_chunk.h5")
+    write_huge_chunked("hdf5/huge_chunked.h5")
+    write_bomb("hdf5/bomb.h5")
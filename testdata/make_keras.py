@@ -0,0 +1,237 @@
+# Writes the .keras models of keras_test.go, with the inputs they are tested on and the outputs they give. The
+# archives have the config.json and model.weights.h5 Keras 3 saves, but are written without Keras, with the HDF5 writer
+# of make_hdf5.py, and the outputs are computed here the way Keras' layers compute them. Run it from this directory.
+import json
+import math
+import random
+import struct
+import zipfile
+
+from make_hdf5 import File, contiguous_dataset, fixed_string, floating, superblock_v0, symbol_table_group
+
+BATCH = 3
+
+
+def float32(x):
+    return struct.unpack("<f", struct.pack("<f", x))[0]
+
+
+# arrays are (shape, row-major values) pairs, whose values are rounded to float32
+
+def random_array(r, shape):
+    return shape, [float32(r.uniform(-1, 1)) for _ in range(math.prod(shape))]
+
+
+def rows(array):
+    shape, values = array
+    width = math.prod(shape[1:])
+    return [values[i * width:(i + 1) * width] for i in range(shape[0])]
+
+
+def from_rows(rows):
+    return [len(rows), len(rows[0])], [v for row in rows for v in row]
+
+
+def dense(x, kernel, bias=None):
+    (inputs, units), k = kernel
+    out = []
+    for row in rows(x):
+        out.append([sum(row[i] * k[i * units + j] for i in range(inputs)) + (bias[1][j] if bias else 0)
+                    for j in range(units)])
+    return from_rows(out)
+
+
+def elementwise(fn, x):
+    return x[0], [fn(v) for v in x[1]]
+
+
+def relu(x):
+    return elementwise(lambda v: max(v, 0), x)
+
+
+def sigmoid(x):
+    return elementwise(lambda v: 1 / (1 + math.exp(-v)), x)
+
+
+def softmax(x):
+    out = []
+    for row in rows(x):
+        exp = [math.exp(v - max(row)) for v in row]
+        out.append([v / sum(exp) for v in exp])
+    return from_rows(out)
+
+
+def flatten(x):
+    return from_rows(rows(x))
+
+
+def add(x, y):
+    return x[0], [a + b for a, b in zip(x[1], y[1])]
+
+
+def concatenate(x, y):
+    return from_rows([a + b for a, b in zip(rows(x), rows(y))])
+
+
+def npy(array):
+    shape, values = array
+    header = "{'descr': '<f4', 'fortran_order': False, 'shape': (%s), }" % "".join("%d, " % d for d in shape)
+    header += " " * (-(10 + len(header) + 1) % 64) + "\n"
+    return b"\x93NUMPY\x01\x00" + struct.pack("<H", len(header)) + header.encode() + \
+        struct.pack("<%df" % len(values), *values)
+
+
+# write_zip writes an archive of the given files, with fixed dates so that it is the same every time
+def write_zip(path, files):
+    with zipfile.ZipFile(path, "w") as z:
+        for name, data in files.items():
+            z.writestr(zipfile.ZipInfo(name, (2024, 5, 1, 12, 0, 0)), data)
+
+
+def write_npz(path, arrays):
+    write_zip(path, {name + ".npy": npy(array) for name, array in arrays.items()})
+
+
+# weights_file returns a model.weights.h5 with the weights of each of the model's layers, given as a list of (group,
+# weights) pairs in the order of the model's layers. Weights whose values are bytes are stored as strings.
+def weights_file(layers):
+    f = File()
+    f.alloc(96)
+    groups = {}
+    for group, weights in layers:
+        datasets = {}
+        for i, (shape, values) in enumerate(weights):
+            if isinstance(values, bytes):
+                datatype, data = fixed_string(len(values) // math.prod(shape)), values
+            else:
+                datatype, data = floating(4), struct.pack("<%df" % len(values), *values)
+            datasets[str(i)] = contiguous_dataset(f, datatype, shape, data)
+        variables, _, _ = symbol_table_group(f, datasets)
+        groups[group], _, _ = symbol_table_group(f, {"vars": variables})
+    layers, _, _ = symbol_table_group(f, groups)
+    variables, _, _ = symbol_table_group(f, {})
+    root, tree_addr, heap_addr = symbol_table_group(f, {"layers": layers, "vars": variables})
+    superblock_v0(f, root, tree_addr, heap_addr)
+    return bytes(f.buf)
+
+
+def write_keras(path, config, weights):
+    write_zip(path, {
+        "metadata.json": json.dumps({"keras_version": "3.3.3", "date_saved": "2024-05-01@12:00:00"}),
+        "config.json": json.dumps(config),
+        "model.weights.h5": weights,
+    })
+
+
+def dtype_policy():
+    return {"module": "keras", "class_name": "DTypePolicy", "config": {"name": "float32"}, "registered_name": None}
+
+
+def layer_config(class_name, name, **config):
+    return {"module": "keras.layers", "class_name": class_name,
+            "config": dict({"name": name, "trainable": True, "dtype": dtype_policy()}, **config),
+            "registered_name": None}
+
+
+def dense_config(name, units, activation, use_bias=True):
+    return layer_config("Dense", name, units=units, activation=activation, use_bias=use_bias,
+                        kernel_initializer={"module": "keras.initializers", "class_name": "GlorotUniform",
+                                            "config": {"seed": None}, "registered_name": None},
+                        bias_initializer={"module": "keras.initializers", "class_name": "Zeros", "config": {},
+                                          "registered_name": None},
+                        kernel_regularizer=None, bias_regularizer=None, kernel_constraint=None, bias_constraint=None)
+
+
+def input_config(name, shape):
+    return {"module": "keras.layers", "class_name": "InputLayer",
+            "config": {"batch_shape": [None] + shape, "dtype": "float32", "sparse": False, "name": name},
+            "registered_name": None}
+
+
+def write_sequential(r):
+    kernel, bias = random_array(r, [6, 4]), random_array(r, [4])
+    kernel_1 = random_array(r, [4, 3])
+    x = random_array(r, [BATCH, 2, 3])
+    y = softmax(dense(relu(dense(flatten(x), kernel, bias)), kernel_1))
+
+    config = {"module": "keras", "class_name": "Sequential", "config": {
+        "name": "sequential", "trainable": True, "dtype": dtype_policy(), "layers": [
+            input_config("input_layer", [2, 3]),
+            layer_config("Flatten", "flatten", data_format="channels_last"),
+            dense_config("dense", 4, "relu"),
+            layer_config("Dropout", "dropout", rate=0.5, seed=None, noise_shape=None),
+            dense_config("dense_1", 3, "softmax", use_bias=False),
+        ], "build_input_shape": [None, 2, 3]}, "registered_name": None, "compile_config": None}
+    weights = weights_file([("flatten", []), ("dense", [kernel, bias]), ("dropout", []), ("dense_1", [kernel_1])])
+    write_keras("keras/sequential.keras", config, weights)
+    write_npz("keras/sequential.npz", {"input_0": x, "output_0": y})
+
+
+def tensor(layer, shape):
+    return {"class_name": "__keras_tensor__",
+            "config": {"shape": [None] + shape, "dtype": "float32", "keras_history": [layer, 0, 0]}}
+
+
+def call(layer, config, *args):
+    return dict(config, name=layer, inbound_nodes=[{"args": list(args), "kwargs": {}}])
+
+
+# write_functional writes a model with two inputs and two outputs, one of them a merge layer. The layers have their
+# own names, while the weights are named after the layers' classes.
+def write_functional(r):
+    kernel_a, bias_a = random_array(r, [4, 5]), random_array(r, [5])
+    kernel_b, bias_b = random_array(r, [3, 5]), random_array(r, [5])
+    kernel_head, bias_head = random_array(r, [9, 2]), random_array(r, [2])
+    a, b = random_array(r, [BATCH, 4]), random_array(r, [BATCH, 3])
+    encoded_a = elementwise(math.tanh, dense(a, kernel_a, bias_a))
+    total = add(encoded_a, dense(b, kernel_b, bias_b))
+    head = sigmoid(dense(concatenate(total, a), kernel_head, bias_head))
+
+    layers = [
+        dict(input_config("input_a", [4]), name="input_a", inbound_nodes=[]),
+        dict(input_config("input_b", [3]), name="input_b", inbound_nodes=[]),
+        call("encoder_a", dense_config("encoder_a", 5, "tanh"), tensor("input_a", [4])),
+        call("encoder_b", dense_config("encoder_b", 5, "linear"), tensor("input_b", [3])),
+        call("sum", layer_config("Add", "sum"), [tensor("encoder_a", [5]), tensor("encoder_b", [5])]),
+        call("joined", layer_config("Concatenate", "joined", axis=-1),
+             [tensor("sum", [5]), tensor("input_a", [4])]),
+        call("head", dense_config("head", 2, "sigmoid"), tensor("joined", [9])),
+    ]
+    config = {"module": "keras.src.models.functional", "class_name": "Functional", "config": {
+        "name": "functional", "trainable": True, "layers": layers,
+        "input_layers": [["input_a", 0, 0], ["input_b", 0, 0]],
+        "output_layers": [["head", 0, 0], ["sum", 0, 0]]}, "registered_name": "Functional", "compile_config": None}
+    weights = weights_file([("input_layer", []), ("input_layer_1", []), ("dense", [kernel_a, bias_a]),
+                            ("dense_1", [kernel_b, bias_b]), ("add", []), ("concatenate", []),
+                            ("dense_2", [kernel_head, bias_head])])
+    write_keras("keras/functional.keras", config, weights)
+    write_npz("keras/functional.npz", {"input_0": a, "input_1": b, "output_0": head, "output_1": total})
+
+
+# write_string_weights writes a model whose kernel is made of strings
+def write_string_weights():
+    config = {"module": "keras", "class_name": "Sequential", "config": {
+        "name": "sequential", "trainable": True, "dtype": dtype_policy(), "layers": [
+            input_config("input_layer", [2]),
+            dense_config("dense", 1, "linear", use_bias=False),
+        ], "build_input_shape": [None, 2]}, "registered_name": None, "compile_config": None}
+    weights = weights_file([("dense", [([2, 1], b"0.5\0\x001.5\0")])])
+    write_keras("keras/string_weights.keras", config, weights)
+
+
+# write_mismatched_weights writes a model whose kernel takes 3 features, while its input has 2
+def write_mismatched_weights():
+    config = {"module": "keras", "class_name": "Sequential", "config": {
+        "name": "sequential", "trainable": True, "dtype": dtype_policy(), "layers": [
+            input_config("input_layer", [2]),
+            dense_config("dense", 1, "linear", use_bias=False),
+        ], "build_input_shape": [None, 2]}, "registered_name": None, "compile_config": None}
+    weights = weights_file([("dense", [([3, 1], [1, 2, 3])])])
+    write_keras("keras/mismatched_weights.keras", config, weights)
+
+
+if __name__ == "__main__":
+    write_sequential(random.Random(0))
+    write_functional(random.Random(1))
+    write_string_weights()
+    write_mismatched_weights()